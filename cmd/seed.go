@@ -1,13 +1,16 @@
 package cmd
 
 import (
+	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/jmehdipour/sms-gateway/internal/config"
 	"github.com/jmehdipour/sms-gateway/internal/db"
 	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/util"
 	"github.com/jmoiron/sqlx"
 	"github.com/spf13/cobra"
 )
@@ -53,53 +56,38 @@ func init() {
 	rootCmd.AddCommand(seedCmd)
 }
 
-// seedCustomers inserts 5 deterministic demo customers (idempotent).
+// demoCustomer is a seeded customer with a fixed, well-known API key.
+type demoCustomer struct {
+	model.Customer
+	APIKey string
+}
+
+// seedCustomers inserts 5 deterministic demo customers, each with one all-scope API key
+// (idempotent: customers are matched by their key's prefix).
 func seedCustomers(dbx *sqlx.DB) error {
-	customers := []model.Customer{
+	customers := []demoCustomer{
 		{
-			Name:         "Acme Corp",
-			APIKey:       "11111111111111111111111111111111",
-			Status:       "active",
-			RateLimitRPS: intptr(20),
+			Customer: model.Customer{Name: "Acme Corp", Status: "active", RateLimitRPS: intptr(20)},
+			APIKey:   "sk_demo00000001.11111111111111111111111111111111",
 		},
 		{
-			Name:         "Foobar LLC",
-			APIKey:       "22222222222222222222222222222222",
-			Status:       "active",
-			RateLimitRPS: intptr(50),
+			Customer: model.Customer{Name: "Foobar LLC", Status: "active", RateLimitRPS: intptr(50)},
+			APIKey:   "sk_demo00000002.22222222222222222222222222222222",
 		},
 		{
-			Name:         "Beta Testers",
-			APIKey:       "33333333333333333333333333333333",
-			Status:       "active",
-			RateLimitRPS: intptr(5),
+			Customer: model.Customer{Name: "Beta Testers", Status: "active", RateLimitRPS: intptr(5)},
+			APIKey:   "sk_demo00000003.33333333333333333333333333333333",
 		},
 		{
-			Name:         "Suspended Inc",
-			APIKey:       "44444444444444444444444444444444",
-			Status:       "suspended",
-			RateLimitRPS: nil,
+			Customer: model.Customer{Name: "Suspended Inc", Status: "suspended", RateLimitRPS: nil},
+			APIKey:   "sk_demo00000004.44444444444444444444444444444444",
 		},
 		{
-			Name:         "Express Partner",
-			APIKey:       "55555555555555555555555555555555",
-			Status:       "active",
-			RateLimitRPS: intptr(100),
+			Customer: model.Customer{Name: "Express Partner", Status: "active", RateLimitRPS: intptr(100)},
+			APIKey:   "sk_demo00000005.55555555555555555555555555555555",
 		},
 	}
 
-	// idempotent upsert based on api_key (UNIQUE)
-	const q = `
-INSERT INTO customers
-    (name, api_key, status, rate_limit_rps, created_at, updated_at)
-VALUES
-    (?, ?, ?, ?, ?, ?)
-ON DUPLICATE KEY UPDATE
-    name        = VALUES(name),
-    status      = VALUES(status),
-    rate_limit_rps = VALUES(rate_limit_rps),
-    updated_at  = VALUES(updated_at)
-`
 	tx, err := dbx.Beginx()
 	if err != nil {
 		return fmt.Errorf("begin tx: %w", err)
@@ -109,9 +97,43 @@ ON DUPLICATE KEY UPDATE
 	}()
 
 	now := time.Now()
+	scopes := strings.Join(model.AllScopes, ",")
 	for _, c := range customers {
-		if _, err := tx.Exec(q, c.Name, c.APIKey, c.Status, c.RateLimitRPS, now, now); err != nil {
-			return fmt.Errorf("insert customer %q: %w", c.Name, err)
+		prefix, ok := util.APIKeyPrefix(c.APIKey)
+		if !ok {
+			return fmt.Errorf("demo key of %q is malformed", c.Name)
+		}
+
+		var id int64
+		err := tx.Get(&id, `SELECT customer_id FROM api_keys WHERE prefix = ?`, prefix)
+		switch {
+		case err == sql.ErrNoRows:
+			res, err := tx.Exec(`
+INSERT INTO customers (name, status, rate_limit_rps, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?)
+`, c.Name, c.Status, c.RateLimitRPS, now, now)
+			if err != nil {
+				return fmt.Errorf("insert customer %q: %w", c.Name, err)
+			}
+			if id, err = res.LastInsertId(); err != nil {
+				return fmt.Errorf("insert customer %q: %w", c.Name, err)
+			}
+			if _, err := tx.Exec(`
+INSERT INTO api_keys (customer_id, prefix, secret_hash, label, scopes, created_at)
+VALUES (?, ?, ?, 'demo', ?, ?)
+`, id, prefix, util.HashAPIKey(c.APIKey), scopes, now); err != nil {
+				return fmt.Errorf("insert api key of %q: %w", c.Name, err)
+			}
+		case err != nil:
+			return fmt.Errorf("lookup customer %q: %w", c.Name, err)
+		default:
+			if _, err := tx.Exec(`
+UPDATE customers
+   SET name = ?, status = ?, rate_limit_rps = ?, updated_at = ?
+ WHERE id = ?
+`, c.Name, c.Status, c.RateLimitRPS, now, id); err != nil {
+				return fmt.Errorf("update customer %q: %w", c.Name, err)
+			}
 		}
 	}
 
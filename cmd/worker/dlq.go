@@ -0,0 +1,148 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/config"
+	"github.com/jmehdipour/sms-gateway/internal/kafka"
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/spf13/cobra"
+)
+
+var dlqCmd = &cobra.Command{
+	Use:   "dlq",
+	Short: "Inspect, replay or purge the dead-letter topic",
+}
+
+var dlqInspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "Print pending dead letters without consuming them",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDLQ(cmd, func(ctx context.Context, dl model.DeadLetter, _ *kafka.Producer) (bool, error) {
+			fmt.Printf("%s/%d@%d consumer=%s failed_at=%s error=%q\n  key=%q\n  value=%s\n",
+				dl.Topic, dl.Partition, dl.Offset, dl.Consumer, dl.FailedAt.Format(time.RFC3339), dl.Error, dl.Key, dl.Value)
+			return false, nil
+		})
+	},
+}
+
+var dlqReplayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Republish dead letters to their source topic (or --to) and consume them",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		to, _ := cmd.Flags().GetString("to")
+		return runDLQ(cmd, func(ctx context.Context, dl model.DeadLetter, p *kafka.Producer) (bool, error) {
+			topic := dl.Topic
+			if to != "" {
+				topic = to
+			}
+			if topic == "" {
+				return false, fmt.Errorf("dead letter at offset %d has no source topic; use --to", dl.Offset)
+			}
+			if err := p.Publish(ctx, topic, dl.Key, dl.Value); err != nil {
+				return false, fmt.Errorf("publish to %s: %w", topic, err)
+			}
+			fmt.Printf("replayed %s/%d@%d → %s\n", dl.Topic, dl.Partition, dl.Offset, topic)
+			return true, nil
+		})
+	},
+}
+
+var dlqPurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Consume dead letters without replaying them",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDLQ(cmd, func(ctx context.Context, dl model.DeadLetter, _ *kafka.Producer) (bool, error) {
+			fmt.Printf("purged %s/%d@%d\n", dl.Topic, dl.Partition, dl.Offset)
+			return true, nil
+		})
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{dlqInspectCmd, dlqReplayCmd, dlqPurgeCmd} {
+		c.Flags().Int("limit", 100, "max dead letters to process (0 = all)")
+		c.Flags().Duration("wait", 5*time.Second, "stop after this long without new records")
+		dlqCmd.AddCommand(c)
+	}
+	dlqReplayCmd.Flags().String("to", "", "republish to this topic instead of the source topic")
+}
+
+// dlqAction handles one dead letter; consume=true commits it so it is not seen again.
+type dlqAction func(ctx context.Context, dl model.DeadLetter, p *kafka.Producer) (consume bool, err error)
+
+// runDLQ reads the dead-letter topic with a dedicated consumer group. Only records an action
+// consumes are committed, so inspect always shows what is still pending.
+func runDLQ(cmd *cobra.Command, act dlqAction) error {
+	cfgPath, _ := cmd.Root().PersistentFlags().GetString("config")
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if cfg.Kafka.DLQTopic == "" {
+		return errors.New("kafka.dlq_topic is not configured")
+	}
+	limit, _ := cmd.Flags().GetInt("limit")
+	wait, _ := cmd.Flags().GetDuration("wait")
+
+	groupID := cfg.Kafka.GroupID
+	if groupID == "" {
+		groupID = "smsgw-sender"
+	}
+
+	consumer := kafka.NewConsumerFromConfig(kafka.Config{
+		Brokers:        cfg.Kafka.Brokers,
+		Topic:          cfg.Kafka.DLQTopic,
+		GroupID:        groupID + "-dlq",
+		MinBytes:       cfg.Kafka.MinBytes,
+		MaxBytes:       cfg.Kafka.MaxBytes,
+		CommitInterval: time.Duration(cfg.Kafka.CommitInterval) * time.Millisecond, // pending commits flush on Close
+	})
+	defer consumer.Close()
+
+	producer := kafka.NewProducer(cfg.Kafka.Brokers)
+	defer producer.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	n := 0
+	for limit <= 0 || n < limit {
+		fctx, cancel := context.WithTimeout(ctx, wait)
+		m, err := consumer.Fetch(fctx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				break // drained
+			}
+			return fmt.Errorf("fetch: %w", err)
+		}
+
+		var dl model.DeadLetter
+		if err := json.Unmarshal(m.Value, &dl); err != nil {
+			// not written by us; show it raw so it can still be purged
+			dl = model.DeadLetter{Offset: m.Offset, Value: m.Value, Error: "undecodable dead letter: " + err.Error()}
+		}
+
+		consume, err := act(ctx, dl, producer)
+		if err != nil {
+			return err
+		}
+		if consume {
+			if err := consumer.Commit(ctx, m); err != nil {
+				return fmt.Errorf("commit: %w", err)
+			}
+		}
+		n++
+	}
+
+	fmt.Printf("%d dead letter(s) processed\n", n)
+	return nil
+}
@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jmehdipour/sms-gateway/internal/config"
+	"github.com/jmehdipour/sms-gateway/internal/db"
+	"github.com/jmehdipour/sms-gateway/internal/kafka"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmehdipour/sms-gateway/internal/worker"
+	"github.com/spf13/cobra"
+)
+
+var outboxRelayCmd = &cobra.Command{
+	Use:   "outbox-relay",
+	Short: "Publish outbox rows to Kafka (alternative to the Debezium connector)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath, _ := cmd.Root().PersistentFlags().GetString("config")
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return fmt.Errorf("load config: %w", err)
+		}
+
+		dbx, err := db.NewMySQLConnection(cfg.MySQL.DSN, db.MySQLOpts{
+			MaxOpenConns:    cfg.MySQL.MaxOpenConns,
+			MaxIdleConns:    cfg.MySQL.MaxIdleConns,
+			ConnMaxLifetime: cfg.MySQL.ConnMaxLifetime,
+			ConnMaxIdleTime: cfg.MySQL.ConnMaxIdleTime,
+			PingTimeout:     cfg.MySQL.PingTimeout,
+		})
+		if err != nil {
+			return fmt.Errorf("mysql connect: %w", err)
+		}
+		defer dbx.Close()
+
+		producer := kafka.NewProducer(cfg.Kafka.Brokers)
+		defer producer.Close()
+
+		r := worker.NewOutboxRelay(dbx, repository.NewOutboxRepository(dbx), producer)
+		if cfg.Outbox.BatchSize > 0 {
+			r.BatchSize = cfg.Outbox.BatchSize
+		}
+		if cfg.Outbox.PollInterval > 0 {
+			r.PollInterval = cfg.Outbox.PollInterval
+		}
+		r.DeletePublished = cfg.Outbox.DeletePublished
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		addr := cfg.Outbox.MetricsAddr
+		if addr == "" {
+			addr = ":9093"
+		}
+		RunMetricsServer(ctx, addr)
+
+		log.Printf(">> outbox relay started batchSize=%d poll=%s delete=%t",
+			r.BatchSize, r.PollInterval, r.DeletePublished)
+
+		return r.Run(ctx)
+	},
+}
@@ -0,0 +1,107 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/config"
+	"github.com/jmehdipour/sms-gateway/internal/kafka"
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/service/queue"
+	"github.com/jmehdipour/sms-gateway/internal/worker"
+	"github.com/spf13/cobra"
+)
+
+var retryCmd = &cobra.Command{
+	Use:   "retry",
+	Short: "Hand delayed retries back to the lane topic (normal | express)",
+}
+
+var retryNormalCmd = &cobra.Command{
+	Use:   "normal",
+	Short: "Run retry tiers of the normal lane",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRetry(cmd, model.SMSTypeNormal)
+	},
+}
+
+var retryExpressCmd = &cobra.Command{
+	Use:   "express",
+	Short: "Run retry tiers of the express lane",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRetry(cmd, model.SMSTypeExpress)
+	},
+}
+
+func init() {
+	retryCmd.AddCommand(retryNormalCmd)
+	retryCmd.AddCommand(retryExpressCmd)
+}
+
+// retryPolicyOf maps the lane's retry config to the worker policy.
+func retryPolicyOf(cfg config.Config, lane model.SMSType) worker.RetryPolicy {
+	pc := cfg.Retry.Normal
+	if lane == model.SMSTypeExpress {
+		pc = cfg.Retry.Express
+	}
+	return worker.RetryPolicy{Delays: pc.Delays, Validity: pc.Validity}
+}
+
+// runRetry runs one forwarder per delay tier of the lane.
+func runRetry(cmd *cobra.Command, lane model.SMSType) error {
+	cfgPath, _ := cmd.Root().PersistentFlags().GetString("config")
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	policy := retryPolicyOf(cfg, lane)
+	if len(policy.Delays) == 0 {
+		return fmt.Errorf("no retry delays configured for %s", lane)
+	}
+
+	groupID := cfg.Kafka.GroupID
+	if groupID == "" {
+		groupID = "smsgw-sender"
+	}
+
+	producer := kafka.NewProducer(cfg.Kafka.Brokers)
+	defer producer.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	target := queue.TopicOf(lane)
+	var wg sync.WaitGroup
+	for _, d := range policy.Delays {
+		topic := worker.RetryTopic(target, d)
+		consumer := kafka.NewConsumerFromConfig(kafka.Config{
+			Brokers:        cfg.Kafka.Brokers,
+			Topic:          topic,
+			GroupID:        groupID + "-" + topic,
+			MinBytes:       cfg.Kafka.MinBytes,
+			MaxBytes:       cfg.Kafka.MaxBytes,
+			CommitInterval: time.Duration(cfg.Kafka.CommitInterval) * time.Millisecond,
+		})
+		defer consumer.Close()
+
+		f := worker.NewRetryForwarder(consumer, producer, target)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f.Run(ctx); err != nil {
+				log.Printf("[retry] %s stopped: %v", topic, err)
+			}
+		}()
+		log.Printf(">> retry tier started topic=%s → %s", topic, target)
+	}
+
+	wg.Wait()
+	return nil
+}
@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jmehdipour/sms-gateway/internal/config"
+	"github.com/jmehdipour/sms-gateway/internal/db"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmehdipour/sms-gateway/internal/service/pricing"
+	"github.com/jmehdipour/sms-gateway/internal/service/queue"
+	"github.com/jmehdipour/sms-gateway/internal/worker"
+	"github.com/spf13/cobra"
+)
+
+var schedulerCmd = &cobra.Command{
+	Use:   "scheduler",
+	Short: "Release due scheduled messages into the outbox",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath, _ := cmd.Root().PersistentFlags().GetString("config")
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return fmt.Errorf("load config: %w", err)
+		}
+
+		dbx, err := db.NewMySQLConnection(cfg.MySQL.DSN, db.MySQLOpts{
+			MaxOpenConns:    cfg.MySQL.MaxOpenConns,
+			MaxIdleConns:    cfg.MySQL.MaxIdleConns,
+			ConnMaxLifetime: cfg.MySQL.ConnMaxLifetime,
+			ConnMaxIdleTime: cfg.MySQL.ConnMaxIdleTime,
+			PingTimeout:     cfg.MySQL.PingTimeout,
+		})
+		if err != nil {
+			return fmt.Errorf("mysql connect: %w", err)
+		}
+		defer dbx.Close()
+
+		queueSvc := queue.New(
+			dbx,
+			repository.NewMessagesRepository(dbx),
+			repository.NewOutboxRepository(dbx),
+			repository.NewWalletRepository(),
+			repository.NewLedgerRepository(),
+			repository.NewCustomersRepository(dbx),
+			repository.NewUsageRepository(dbx),
+			repository.NewWebhooksRepository(dbx),
+			pricing.New(repository.NewPricesRepository(dbx), nil, cfg.Pricing.Normal, cfg.Pricing.Express, cfg.Pricing.RefreshInterval),
+		)
+
+		s := worker.NewScheduler(queueSvc)
+		if cfg.Scheduler.BatchSize > 0 {
+			s.BatchSize = cfg.Scheduler.BatchSize
+		}
+		if cfg.Scheduler.PollInterval > 0 {
+			s.PollInterval = cfg.Scheduler.PollInterval
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		log.Printf(">> scheduler started batchSize=%d poll=%s", s.BatchSize, s.PollInterval)
+
+		return s.Run(ctx)
+	},
+}
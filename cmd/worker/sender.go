@@ -14,15 +14,20 @@ import (
 	"github.com/jmehdipour/sms-gateway/internal/config"
 	"github.com/jmehdipour/sms-gateway/internal/db"
 	"github.com/jmehdipour/sms-gateway/internal/dispatcher"
+	"github.com/jmehdipour/sms-gateway/internal/dlr"
 	"github.com/jmehdipour/sms-gateway/internal/kafka"
 	"github.com/jmehdipour/sms-gateway/internal/metrics"
 	"github.com/jmehdipour/sms-gateway/internal/model"
 	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmehdipour/sms-gateway/internal/service/pricing"
+	"github.com/jmehdipour/sms-gateway/internal/service/routing"
+	"github.com/jmehdipour/sms-gateway/internal/smpp"
 	"github.com/jmehdipour/sms-gateway/internal/worker"
 	"github.com/labstack/echo/v4"
 	echoMid "github.com/labstack/echo/v4/middleware"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
 	"github.com/spf13/cobra"
 )
 
@@ -82,29 +87,69 @@ func runSender(cmd *cobra.Command, smsType model.SMSType) error {
 	messagesRepo := repository.NewMessagesRepository(dbx)
 	walletRepo := repository.NewWalletRepository()
 	ledgerRepo := repository.NewLedgerRepository()
+	webhooksRepo := repository.NewWebhooksRepository(dbx)
+
+	// 4) providers → dispatcher (Redis only when a provider shares limits or breaker state)
+	var rdb *redis.Client
+	for _, pc := range cfg.Providers {
+		if pc.Enabled && (pc.MaxTPS > 0 || pc.MaxConcurrency > 0 || pc.Breaker.Shared) {
+			rdb, err = db.NewRedisClient(db.RedisOpts{
+				Addr:        cfg.Redis.Addr,
+				Password:    cfg.Redis.Password,
+				DB:          cfg.Redis.DB,
+				DialTimeout: cfg.Redis.DialTimeout,
+			})
+			if err != nil {
+				return fmt.Errorf("redis connect: %w", err)
+			}
+			defer func() { _ = rdb.Close() }()
+			break
+		}
+	}
+	// without Redis, admin price changes reach this worker within the refresh interval
+	pricer := pricing.New(repository.NewPricesRepository(dbx), rdb, cfg.Pricing.Normal, cfg.Pricing.Express, cfg.Pricing.RefreshInterval)
 
-	// 4) providers → dispatcher
 	var provs []dispatcher.Provider
+	applier := dlr.NewApplier(dbx, messagesRepo, webhooksRepo)
 	for _, pc := range cfg.Providers {
-		if !pc.Enabled || strings.TrimSpace(pc.BaseURL) == "" {
+		if !pc.Enabled {
 			continue
 		}
-		provs = append(provs,
-			dispatcher.NewHTTPProvider(
-				pc.Name,
-				strings.TrimRight(pc.BaseURL, "/"),
-				pc.NormalPath,
-				pc.ExpressPath,
-				pc.TimeoutMs,
-				pc.Breaker.FailThreshold,
-				pc.Breaker.OpenForMs,
-			),
-		)
+		if strings.EqualFold(pc.Kind, "smpp") {
+			sp := dispatcher.NewSMPPProvider(pc.Name, smppOptionsOf(pc), breakerOf(pc, rdb), applier.Apply)
+			defer sp.Close()
+			provs = append(provs, dispatcher.Limited(sp, rdb, limitsOf(pc)))
+			continue
+		}
+		adapter, err := dispatcher.NewAdapter(dispatcher.AdapterConfig{
+			Kind:          pc.Kind,
+			BaseURL:       strings.TrimSpace(pc.BaseURL),
+			NormalPath:    pc.NormalPath,
+			ExpressPath:   pc.ExpressPath,
+			APIKey:        pc.APIKey,
+			Sender:        pc.Sender,
+			ExpressSender: pc.ExpressSender,
+		})
+		if err != nil {
+			return fmt.Errorf("provider %s: %w", pc.Name, err)
+		}
+		hp := dispatcher.NewHTTPProvider(pc.Name, adapter, pc.TimeoutMs, breakerOf(pc, rdb))
+		provs = append(provs, dispatcher.Limited(hp, rdb, limitsOf(pc)))
 	}
 	if len(provs) == 0 {
 		return fmt.Errorf("no providers enabled in config")
 	}
 	disp := dispatcher.NewDispatcher(provs, cfg.Dispatcher.MaxRetryAttempts.Express, cfg.Dispatcher.MaxRetryAttempts.Normal)
+	strategy := cfg.Dispatcher.Routing.Normal
+	if smsType == model.SMSTypeExpress {
+		strategy = cfg.Dispatcher.Routing.Express
+	}
+	rs, err := dispatcher.NewStrategy(strategy, providerAttrsOf(cfg.Providers))
+	if err != nil {
+		return fmt.Errorf("routing %s: %w", smsType, err)
+	}
+	disp.SetStrategy(smsType, rs)
+	disp.SetRoutes(routing.New(repository.NewRoutesRepository(dbx), cfg.Dispatcher.Routing.RulesRefreshInterval))
 
 	// 5) kafka consumer
 	topic := "sms.normal"
@@ -127,16 +172,19 @@ func runSender(cmd *cobra.Command, smsType model.SMSType) error {
 	})
 	defer consumer.Close()
 
+	producer := kafka.NewProducer(cfg.Kafka.Brokers)
+	defer producer.Close()
+
 	w := worker.NewSenderKafka(
 		dbx,
 		consumer,
 		messagesRepo,
 		walletRepo,
 		ledgerRepo,
+		webhooksRepo,
 		disp,
 		smsType,
-		cfg.Pricing.Normal,
-		cfg.Pricing.Express,
+		pricer,
 	)
 
 	// tune knobs
@@ -149,19 +197,123 @@ func runSender(cmd *cobra.Command, smsType model.SMSType) error {
 	if cfg.Dispatcher.BatchWait > 0 {
 		w.BatchWait = cfg.Dispatcher.BatchWait
 	}
+	w.Producer = producer
+	w.Usage = repository.NewUsageRepository(dbx)
+	w.Retry = retryPolicyOf(cfg, smsType)
+	if cfg.Kafka.DLQTopic != "" {
+		w.DLQ = &worker.DeadLetterer{Producer: producer, Topic: cfg.Kafka.DLQTopic, Consumer: groupID}
+	}
 
 	// 7) graceful shutdown
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
 	RunMetricsServer(ctx, ":9090")
+	go pricer.Run(ctx)
 
-	log.Printf(">> sender started type=%s topic=%s group=%s workers=%d batchSize=%d batchWait=%s",
-		smsType, topic, groupID, w.Workers, w.BatchSize, w.BatchWait)
+	log.Printf(">> sender started type=%s topic=%s group=%s workers=%d batchSize=%d batchWait=%s retryTiers=%v routing=%s",
+		smsType, topic, groupID, w.Workers, w.BatchSize, w.BatchWait, w.Retry.Delays, rs.Name())
+	if len(w.Retry.Delays) > 0 {
+		log.Printf(">> retry tiers on: `worker retry %s` must run, or failed messages stay retrying", smsType)
+	}
 
 	return w.Run(ctx)
 }
 
+// breakerOf builds the circuit breaker a provider entry asks for, shared through rdb when
+// breaker.shared is set.
+func breakerOf(pc config.ProviderConfig, rdb *redis.Client) dispatcher.Breaker {
+	local := localBreakerOf(pc)
+	if !pc.Breaker.Shared || rdb == nil {
+		return local
+	}
+	return dispatcher.NewSharedBreaker(local, rdb, pc.Name, pc.Breaker.FailThreshold,
+		time.Duration(pc.Breaker.OpenForMs)*time.Millisecond)
+}
+
+func localBreakerOf(pc config.ProviderConfig) dispatcher.Breaker {
+	bc := pc.Breaker
+	openFor := time.Duration(bc.OpenForMs) * time.Millisecond
+	if strings.EqualFold(bc.Mode, "sliding") {
+		return dispatcher.NewSlidingBreaker(dispatcher.SlidingOptions{
+			Name:           pc.Name,
+			WindowSize:     bc.WindowSize,
+			WindowTime:     bc.Window,
+			MinCalls:       bc.MinCalls,
+			FailureRate:    bc.FailureRate,
+			SlowRate:       bc.SlowCallRate,
+			SlowCall:       bc.SlowCall,
+			OpenFor:        openFor,
+			HalfOpenProbes: bc.HalfOpenProbes,
+		})
+	}
+
+	threshold := bc.FailThreshold
+	if threshold <= 0 {
+		threshold = 3
+	}
+	if openFor <= 0 {
+		openFor = 15 * time.Second
+	}
+	return dispatcher.NewMicroBreaker(threshold, openFor)
+}
+
+// limitsOf maps a provider's caps; a crashed worker's concurrency slot frees after two timeouts.
+func limitsOf(pc config.ProviderConfig) dispatcher.ProviderLimits {
+	timeout := time.Duration(pc.TimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return dispatcher.ProviderLimits{
+		MaxTPS:         pc.MaxTPS,
+		MaxConcurrency: pc.MaxConcurrency,
+		LeaseTTL:       2 * timeout,
+	}
+}
+
+// providerAttrsOf collects the routing inputs of each provider by name.
+func providerAttrsOf(pcs []config.ProviderConfig) map[string]dispatcher.ProviderAttrs {
+	out := make(map[string]dispatcher.ProviderAttrs, len(pcs))
+	for _, pc := range pcs {
+		out[pc.Name] = dispatcher.ProviderAttrs{
+			Weight:      pc.Weight,
+			Priority:    pc.Priority,
+			CostNormal:  pc.Cost.Normal,
+			CostExpress: pc.Cost.Express,
+		}
+	}
+	return out
+}
+
+// smppOptionsOf maps a kind: smpp provider entry to the SMPP client options.
+func smppOptionsOf(pc config.ProviderConfig) dispatcher.SMPPOptions {
+	sc := pc.SMPP
+	destTON, destNPI := sc.DestTON, sc.DestNPI
+	if destTON == 0 && destNPI == 0 {
+		destTON, destNPI = 1, 1
+	}
+	return dispatcher.SMPPOptions{
+		Config: smpp.Config{
+			Addr:           sc.Addr,
+			SystemID:       sc.SystemID,
+			Password:       sc.Password,
+			SystemType:     sc.SystemType,
+			SourceAddr:     pc.Sender,
+			SourceTON:      byte(sc.SourceTON),
+			SourceNPI:      byte(sc.SourceNPI),
+			DestTON:        byte(destTON),
+			DestNPI:        byte(destNPI),
+			Sessions:       sc.Sessions,
+			Window:         sc.Window,
+			EnquireLink:    sc.EnquireLink,
+			ReconnectDelay: sc.ReconnectDelay,
+			Timeout:        time.Duration(pc.TimeoutMs) * time.Millisecond,
+		},
+		ExpressSource: pc.ExpressSender,
+		Concat:        sc.Concat,
+	}
+}
+
 // RunMetricsServer starts an Echo server for /metrics and shuts down when ctx is done.
 func RunMetricsServer(ctx context.Context, addr string) {
 	metrics.MustRegister(prometheus.DefaultRegisterer)
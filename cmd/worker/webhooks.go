@@ -0,0 +1,72 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jmehdipour/sms-gateway/internal/config"
+	"github.com/jmehdipour/sms-gateway/internal/db"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmehdipour/sms-gateway/internal/worker"
+	"github.com/spf13/cobra"
+)
+
+var webhooksCmd = &cobra.Command{
+	Use:   "webhooks",
+	Short: "Deliver customer status webhooks from the durable queue",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfgPath, _ := cmd.Root().PersistentFlags().GetString("config")
+		cfg, err := config.Load(cfgPath)
+		if err != nil {
+			return fmt.Errorf("load config: %w", err)
+		}
+
+		dbx, err := db.NewMySQLConnection(cfg.MySQL.DSN, db.MySQLOpts{
+			MaxOpenConns:    cfg.MySQL.MaxOpenConns,
+			MaxIdleConns:    cfg.MySQL.MaxIdleConns,
+			ConnMaxLifetime: cfg.MySQL.ConnMaxLifetime,
+			ConnMaxIdleTime: cfg.MySQL.ConnMaxIdleTime,
+			PingTimeout:     cfg.MySQL.PingTimeout,
+		})
+		if err != nil {
+			return fmt.Errorf("mysql connect: %w", err)
+		}
+		defer dbx.Close()
+
+		w := worker.NewWebhookSender(repository.NewWebhooksRepository(dbx), cfg.Webhooks.Timeout)
+
+		// tune knobs
+		if cfg.Webhooks.WorkerCount > 0 {
+			w.Workers = cfg.Webhooks.WorkerCount
+		}
+		if cfg.Webhooks.BatchSize > 0 {
+			w.BatchSize = cfg.Webhooks.BatchSize
+		}
+		if cfg.Webhooks.PollInterval > 0 {
+			w.PollInterval = cfg.Webhooks.PollInterval
+		}
+		if cfg.Webhooks.MaxAttempts > 0 {
+			w.MaxAttempts = cfg.Webhooks.MaxAttempts
+		}
+		if cfg.Webhooks.BackoffBase > 0 {
+			w.BackoffBase = cfg.Webhooks.BackoffBase
+		}
+		if cfg.Webhooks.BackoffMax > 0 {
+			w.BackoffMax = cfg.Webhooks.BackoffMax
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		RunMetricsServer(ctx, ":9091")
+
+		log.Printf(">> webhooks started workers=%d batchSize=%d maxAttempts=%d",
+			w.Workers, w.BatchSize, w.MaxAttempts)
+
+		return w.Run(ctx)
+	},
+}
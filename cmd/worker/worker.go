@@ -10,6 +10,11 @@ func NewWorkerCmd() *cobra.Command {
 	}
 	// attach subcommands
 	cmd.AddCommand(senderCmd)
+	cmd.AddCommand(webhooksCmd)
+	cmd.AddCommand(schedulerCmd)
+	cmd.AddCommand(retryCmd)
+	cmd.AddCommand(dlqCmd)
+	cmd.AddCommand(outboxRelayCmd)
 
 	return cmd
 }
@@ -13,6 +13,8 @@ CREATE TABLE smsgw.messages_latest_agg
     text_state         AggregateFunction(argMax, String,   DateTime64(3, 'UTC')),
     type_state         AggregateFunction(argMax, String,   DateTime64(3, 'UTC')),
     status_state       AggregateFunction(argMax, String,   DateTime64(3, 'UTC')),
+    provider_state     AggregateFunction(argMax, String,   DateTime64(3, 'UTC')),
+    dlr_at_state       AggregateFunction(argMax, DateTime, DateTime64(3, 'UTC')),
     created_at_state   AggregateFunction(argMax, DateTime, DateTime64(3, 'UTC')),
     updated_at_state   AggregateFunction(argMax, DateTime, DateTime64(3, 'UTC'))
 )
@@ -28,6 +30,8 @@ CREATE TABLE smsgw.kafka_messages
     text         String,
     type         String,
     status       String,
+    provider     Nullable(String),
+    dlr_at       Nullable(UInt64),  -- unix ms
     created_at   UInt64,            -- unix ms
     updated_at   UInt64,            -- unix ms
     __op         Nullable(String),
@@ -55,6 +59,8 @@ SELECT
     argMaxState(text,        toDateTime64(coalesce(__ts_ms, updated_at)/1000.0, 3, 'UTC')) AS text_state,
     argMaxState(type,        toDateTime64(coalesce(__ts_ms, updated_at)/1000.0, 3, 'UTC')) AS type_state,
     argMaxState(status,      toDateTime64(coalesce(__ts_ms, updated_at)/1000.0, 3, 'UTC')) AS status_state,
+    argMaxState(ifNull(provider, ''), toDateTime64(coalesce(__ts_ms, updated_at)/1000.0, 3, 'UTC')) AS provider_state,
+    argMaxState(toDateTime(ifNull(dlr_at, 0)/1000, 'UTC'), toDateTime64(coalesce(__ts_ms, updated_at)/1000.0, 3, 'UTC')) AS dlr_at_state,
     argMaxState(toDateTime(created_at/1000, 'UTC'),  toDateTime64(coalesce(__ts_ms, updated_at)/1000.0, 3, 'UTC')) AS created_at_state,
     argMaxState(toDateTime(updated_at/1000, 'UTC'),  toDateTime64(coalesce(__ts_ms, updated_at)/1000.0, 3, 'UTC')) AS updated_at_state
 FROM smsgw.kafka_messages
@@ -68,6 +74,8 @@ SELECT
     argMaxMerge(text_state)        AS text,
     argMaxMerge(type_state)        AS type,
     argMaxMerge(status_state)      AS status,
+    nullIf(argMaxMerge(provider_state), '')                AS provider,
+    nullIf(argMaxMerge(dlr_at_state), toDateTime(0, 'UTC')) AS dlr_at, -- UTC, NULL until a DLR arrives
     argMaxMerge(created_at_state)  AS created_at,   -- UTC
     argMaxMerge(updated_at_state)  AS updated_at    -- UTC
 FROM smsgw.messages_latest_agg
@@ -81,6 +89,8 @@ SELECT
     text,
     type,
     status,
+    provider,
+    toTimeZone(dlr_at, 'Asia/Tehran')     AS dlr_at_irt,
     toTimeZone(created_at, 'Asia/Tehran') AS created_at_irt,
     toTimeZone(updated_at, 'Asia/Tehran') AS updated_at_irt
 FROM smsgw.messages_latest;
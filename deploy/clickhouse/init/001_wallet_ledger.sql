@@ -7,9 +7,10 @@ DROP VIEW  IF EXISTS smsgw.mv_wallet_ledger_consumer;
 DROP TABLE IF EXISTS smsgw.wallet_ledger_kafka;
 DROP TABLE IF EXISTS smsgw.wallet_ledger;
 
--- ===== 2) Final table (ClickHouse, keep 30 days) =====
+-- ===== 2) Final table (ClickHouse, keep 13 months; serves statements older than the MySQL hot window) =====
 CREATE TABLE smsgw.wallet_ledger
 (
+    id          UInt64,
     customer_id UInt64,
     op          Enum8('topup'=1,'reserve'=2,'capture'=3,'refund'=4),
     amount      UInt64,
@@ -18,13 +19,14 @@ CREATE TABLE smsgw.wallet_ledger
 )
     ENGINE = MergeTree
 PARTITION BY toYYYYMM(created_at)
-ORDER BY (customer_id, created_at)
-TTL created_at + INTERVAL 30 DAY
+ORDER BY (customer_id, created_at, id)
+TTL created_at + INTERVAL 13 MONTH
 SETTINGS ttl_only_drop_parts = 1;
 
 -- ===== 3) Kafka source
 CREATE TABLE smsgw.wallet_ledger_kafka
 (
+    id              UInt64,
     customer_id     UInt64,
     op              String,
     amount          UInt64,
@@ -48,6 +50,7 @@ CREATE MATERIALIZED VIEW smsgw.mv_wallet_ledger_consumer
 TO smsgw.wallet_ledger
 AS
 SELECT
+    toUInt64(id) AS id,
     toUInt64(customer_id) AS customer_id,
     CAST(op, 'Enum8(\'topup\'=1,\'reserve\'=2,\'capture\'=3,\'refund\'=4)') AS op,
     toUInt64(amount) AS amount,
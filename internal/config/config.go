@@ -23,12 +23,22 @@ type Config struct {
 	RateLimit  RateLimitConfig  `mapstructure:"rate_limit"`
 	Providers  []ProviderConfig `mapstructure:"providers"`
 	Pricing    PricingConfig    `mapstructure:"pricing"`
+	Webhooks   WebhooksConfig   `mapstructure:"webhooks"`
+	Scheduler  SchedulerConfig  `mapstructure:"scheduler"`
+	Admin      AdminConfig      `mapstructure:"admin"`
+	Auth       AuthConfig       `mapstructure:"auth"`
+	Wallet     WalletConfig     `mapstructure:"wallet"`
+	Retry      RetryConfig      `mapstructure:"retry"`
+	Outbox     OutboxConfig     `mapstructure:"outbox_relay"`
 }
 
 // ---- Leaf structs ----
 
 type HTTPConfig struct {
-	Addr string `mapstructure:"addr"`
+	Addr              string   `mapstructure:"addr"`
+	BulkMaxRecipients int      `mapstructure:"bulk_max_recipients"`
+	MaxSegments       int      `mapstructure:"max_segments"`    // longest accepted message, in SMS parts
+	TrustedProxies    []string `mapstructure:"trusted_proxies"` // CIDRs whose X-Forwarded-For is believed
 }
 
 type DatabaseConfig struct {
@@ -53,6 +63,7 @@ type KafkaConfig struct {
 	MinBytes       int      `mapstructure:"min_bytes"`
 	MaxBytes       int      `mapstructure:"max_bytes"`
 	CommitInterval int      `mapstructure:"commit_interval_ms"`
+	DLQTopic       string   `mapstructure:"dlq_topic"` // dead-letter topic for unprocessable records
 }
 
 type DispatcherConfig struct {
@@ -60,6 +71,16 @@ type DispatcherConfig struct {
 	BatchSize        int              `mapstructure:"batch_size"`
 	BatchWait        time.Duration    `mapstructure:"batch_wait"`
 	MaxRetryAttempts MaxRetryAttempts `mapstructure:"max_retry_attempts"`
+	Routing          RoutingConfig    `mapstructure:"routing"`
+}
+
+// RoutingConfig names the provider selection strategy of each lane:
+// round_robin | weighted_round_robin | least_latency | least_cost | priority.
+// Destinations matching a routing_rules prefix bypass the strategy.
+type RoutingConfig struct {
+	Normal               string        `mapstructure:"normal"`
+	Express              string        `mapstructure:"express"`
+	RulesRefreshInterval time.Duration `mapstructure:"rules_refresh_interval"` // how often routing_rules are reloaded
 }
 
 type MaxRetryAttempts struct {
@@ -67,31 +88,150 @@ type MaxRetryAttempts struct {
 	Express int `mapstructure:"express"`
 }
 
+// RateLimitConfig is the per-customer token bucket applied when a customer has no
+// rate_limit_rps of its own. Routes sets fixed buckets per route group; a group with rps 0
+// uses the customer's (or this default) rate.
 type RateLimitConfig struct {
+	RPS    int             `mapstructure:"rps"`
+	Burst  int             `mapstructure:"burst"` // bucket size; 0 = rps
+	Routes RateLimitRoutes `mapstructure:"routes"`
+}
+
+// RateLimitRoutes are the route groups, matching the API key scopes.
+type RateLimitRoutes struct {
+	Send    RouteRate `mapstructure:"send"`    // sms:send routes
+	Topup   RouteRate `mapstructure:"topup"`   // wallet:topup
+	Reports RouteRate `mapstructure:"reports"` // reports:read routes
+}
+
+type RouteRate struct {
 	RPS   int `mapstructure:"rps"`
 	Burst int `mapstructure:"burst"`
 }
 
+// BreakerConfig picks a provider's circuit breaker. mode "consecutive" (default) opens after
+// fail_threshold failures in a row; "sliding" opens on failure/slow-call rate over a window.
 type BreakerConfig struct {
-	FailThreshold int `mapstructure:"fail_threshold" yaml:"fail_threshold"`
-	OpenForMs     int `mapstructure:"open_for_ms"    yaml:"open_for_ms"`
+	Mode           string        `mapstructure:"mode"             yaml:"mode"`
+	FailThreshold  int           `mapstructure:"fail_threshold"   yaml:"fail_threshold"`
+	OpenForMs      int           `mapstructure:"open_for_ms"      yaml:"open_for_ms"`
+	WindowSize     int           `mapstructure:"window_size"      yaml:"window_size"`      // sliding: last N calls
+	Window         time.Duration `mapstructure:"window"           yaml:"window"`           // sliding: or calls in the last d
+	MinCalls       int           `mapstructure:"min_calls"        yaml:"min_calls"`        // sliding: volume before a verdict
+	FailureRate    float64       `mapstructure:"failure_rate"     yaml:"failure_rate"`     // sliding: 0..1
+	SlowCallRate   float64       `mapstructure:"slow_call_rate"   yaml:"slow_call_rate"`   // sliding: 0..1
+	SlowCall       time.Duration `mapstructure:"slow_call"        yaml:"slow_call"`        // sliding: slower counts as slow
+	HalfOpenProbes int           `mapstructure:"half_open_probes" yaml:"half_open_probes"` // sliding: probes before closing
+	Shared         bool          `mapstructure:"shared"           yaml:"shared"`           // share state across replicas via Redis
 }
 
 type ProviderConfig struct {
-	Name        string        `mapstructure:"name"`
-	Enabled     bool          `mapstructure:"enabled"`
-	BaseURL     string        `mapstructure:"base_url"`
-	NormalPath  string        `mapstructure:"normal_path"`
-	ExpressPath string        `mapstructure:"express_path"`
-	TimeoutMs   int           `mapstructure:"timeout_ms"`
-	Breaker     BreakerConfig `mapstructure:"breaker"`
+	Name           string        `mapstructure:"name"`
+	Kind           string        `mapstructure:"kind"` // generic (default) | kavenegar | ghasedak | smsir | smpp
+	Enabled        bool          `mapstructure:"enabled"`
+	BaseURL        string        `mapstructure:"base_url"`     // optional for non-generic kinds
+	NormalPath     string        `mapstructure:"normal_path"`  // generic only
+	ExpressPath    string        `mapstructure:"express_path"` // generic only
+	APIKey         string        `mapstructure:"api_key"`
+	Sender         string        `mapstructure:"sender"`         // sender line number
+	ExpressSender  string        `mapstructure:"express_sender"` // dedicated line for express (optional)
+	TimeoutMs      int           `mapstructure:"timeout_ms"`
+	Breaker        BreakerConfig `mapstructure:"breaker"`
+	Weight         int           `mapstructure:"weight"`          // weighted_round_robin share
+	Priority       int           `mapstructure:"priority"`        // priority routing; lower goes first
+	Cost           CostConfig    `mapstructure:"cost"`            // least_cost routing
+	MaxTPS         int           `mapstructure:"max_tps"`         // shared across replicas via Redis; 0 = unlimited
+	MaxConcurrency int           `mapstructure:"max_concurrency"` // in-flight requests across replicas; 0 = unlimited
+	DLRToken       string        `mapstructure:"dlr_token"`       // shared secret expected on DLR callbacks (optional)
+	SMPP           SMPPConfig    `mapstructure:"smpp"`            // kind: smpp only; sender is the source address
 }
 
-type PricingConfig struct {
+// CostConfig is what a provider charges us per segment on each lane.
+type CostConfig struct {
 	Normal  int64 `mapstructure:"normal"`
 	Express int64 `mapstructure:"express"`
 }
 
+// SMPPConfig is an SMSC account bound as a transceiver. Delivery receipts arrive on the
+// same sessions, so SMPP providers need no DLR callback.
+type SMPPConfig struct {
+	Addr           string        `mapstructure:"addr"` // host:port
+	SystemID       string        `mapstructure:"system_id"`
+	Password       string        `mapstructure:"password"`
+	SystemType     string        `mapstructure:"system_type"`
+	SourceTON      int           `mapstructure:"source_ton"`
+	SourceNPI      int           `mapstructure:"source_npi"`
+	DestTON        int           `mapstructure:"dest_ton"` // dest_ton/dest_npi 0/0 mean 1/1 (international, E.164)
+	DestNPI        int           `mapstructure:"dest_npi"`
+	Sessions       int           `mapstructure:"sessions"` // parallel binds
+	Window         int           `mapstructure:"window"`   // outstanding submit_sm per session
+	EnquireLink    time.Duration `mapstructure:"enquire_link"`
+	ReconnectDelay time.Duration `mapstructure:"reconnect_delay"`
+	Concat         string        `mapstructure:"concat"` // udh (default) | sar
+}
+
+// PricingConfig is the default price of one SMS segment per lane, used when no
+// price_rules prefix matches the destination.
+type PricingConfig struct {
+	Normal          int64         `mapstructure:"normal"`
+	Express         int64         `mapstructure:"express"`
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"` // how often price_rules are reloaded
+}
+
+type WebhooksConfig struct {
+	WorkerCount  int           `mapstructure:"worker_count"`
+	BatchSize    int           `mapstructure:"batch_size"`
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	Timeout      time.Duration `mapstructure:"timeout"`
+	MaxAttempts  int           `mapstructure:"max_attempts"`
+	BackoffBase  time.Duration `mapstructure:"backoff_base"`
+	BackoffMax   time.Duration `mapstructure:"backoff_max"`
+}
+
+type SchedulerConfig struct {
+	BatchSize    int           `mapstructure:"batch_size"`
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+}
+
+// RetryConfig is the per-lane retry policy applied after the dispatcher runs out of attempts.
+type RetryConfig struct {
+	Normal  RetryPolicyConfig `mapstructure:"normal"`
+	Express RetryPolicyConfig `mapstructure:"express"`
+}
+
+type RetryPolicyConfig struct {
+	Delays   []time.Duration `mapstructure:"delays"`   // one retry tier topic per delay, e.g. sms.normal.retry.30s
+	Validity time.Duration   `mapstructure:"validity"` // give up (fail + refund) once the message is this old
+}
+
+// OutboxConfig tunes `worker outbox-relay`, the built-in alternative to the Debezium connector.
+type OutboxConfig struct {
+	BatchSize       int           `mapstructure:"batch_size"`
+	PollInterval    time.Duration `mapstructure:"poll_interval"`
+	DeletePublished bool          `mapstructure:"delete_published"` // false keeps rows with published_at set
+	MetricsAddr     string        `mapstructure:"metrics_addr"`
+}
+
+// WalletConfig tunes the wallet statement API. Ledger entries older than LedgerHotWindow are
+// read from ClickHouse; zero serves everything from MySQL.
+type WalletConfig struct {
+	LedgerHotWindow time.Duration `mapstructure:"ledger_hot_window"`
+}
+
+// AdminConfig guards the operator API under /admin/v1; an empty token disables it.
+type AdminConfig struct {
+	Token string `mapstructure:"token"`
+}
+
+// AuthConfig bounds the in-process API key cache of each API node. Suspensions, key
+// rotations and revocations reach every node over Redis pub/sub; the TTLs only cap staleness
+// if an invalidation is lost.
+type AuthConfig struct {
+	CacheSize   int           `mapstructure:"cache_size"`
+	CacheTTL    time.Duration `mapstructure:"cache_ttl"`
+	NegativeTTL time.Duration `mapstructure:"negative_ttl"` // unknown keys
+}
+
 // Load reads embedded defaults, merges user YAML (if provided), and applies env overrides (SMSGW_*).
 func Load(path string) (Config, error) {
 	v := viper.New()
@@ -0,0 +1,133 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+)
+
+// ErrorClass tells callers what a provider failure means for the message.
+type ErrorClass string
+
+const (
+	// ErrClassTransient: provider-side or network trouble; another provider or a later retry may work.
+	ErrClassTransient ErrorClass = "transient"
+	// ErrClassRejected: the provider refused this message (bad number, empty/too long text);
+	// retrying will not help, and it says nothing about provider health.
+	ErrClassRejected ErrorClass = "rejected"
+	// ErrClassAuth: our account with the provider is unusable (bad key, no credit, bad sender line).
+	ErrClassAuth ErrorClass = "auth"
+	// ErrClassPartial: some parts of a long message were accepted before the rest failed;
+	// sending it again anywhere would deliver those parts twice.
+	ErrClassPartial ErrorClass = "partial"
+)
+
+// Final reports whether err rules out sending the message again, on any provider or later.
+func Final(err error) bool {
+	c := ClassOf(err)
+	return c == ErrClassRejected || c == ErrClassPartial
+}
+
+// Response is a provider reply mapped to common terms.
+type Response struct {
+	Accepted  bool
+	MessageID string     // provider-side id when accepted
+	Class     ErrorClass // why it was not accepted
+	Code      string     // provider status code, for logs
+	Detail    string     // provider message, for logs
+}
+
+// SendError is returned when a provider did not accept a message.
+type SendError struct {
+	Provider string
+	Class    ErrorClass
+	Code     string
+	Detail   string
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("provider=%s class=%s code=%s: %s", e.Provider, e.Class, e.Code, e.Detail)
+}
+
+// ClassOf returns the class of a send error; errors that are not a *SendError
+// (timeouts, connection resets, no healthy provider) count as transient.
+func ClassOf(err error) ErrorClass {
+	if err == nil {
+		return ""
+	}
+	var se *SendError
+	if errors.As(err, &se) {
+		return se.Class
+	}
+	return ErrClassTransient
+}
+
+// Adapter maps our SMS to one provider's HTTP API and its reply back to a Response.
+type Adapter interface {
+	NewRequest(ctx context.Context, sms model.SMS, express bool) (*http.Request, error)
+	ParseResponse(status int, body []byte) Response
+}
+
+// AdapterConfig is the provider-specific part of a provider's config.
+type AdapterConfig struct {
+	Kind          string // generic | kavenegar | ghasedak | smsir
+	BaseURL       string // empty uses the provider's public API
+	NormalPath    string // generic only
+	ExpressPath   string // generic only
+	APIKey        string
+	Sender        string // sender line
+	ExpressSender string // dedicated line for the express lane (optional)
+}
+
+// senderFor picks the line of a lane.
+func (c AdapterConfig) senderFor(express bool) string {
+	if express && c.ExpressSender != "" {
+		return c.ExpressSender
+	}
+	return c.Sender
+}
+
+// NewAdapter builds the adapter of cfg.Kind.
+func NewAdapter(cfg AdapterConfig) (Adapter, error) {
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	switch strings.ToLower(cfg.Kind) {
+	case "", "generic":
+		if cfg.BaseURL == "" {
+			return nil, errors.New("generic provider needs base_url")
+		}
+		return &genericAdapter{cfg: cfg}, nil
+	case "kavenegar":
+		return newKavenegarAdapter(cfg)
+	case "ghasedak":
+		return newGhasedakAdapter(cfg)
+	case "smsir":
+		return newSMSIRAdapter(cfg)
+	default:
+		return nil, fmt.Errorf("unknown provider kind %q", cfg.Kind)
+	}
+}
+
+// classifyHTTP maps a non-2xx HTTP status without a usable body.
+func classifyHTTP(status int) ErrorClass {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden || status == http.StatusPaymentRequired:
+		return ErrClassAuth
+	case status == http.StatusBadRequest || status == http.StatusUnprocessableEntity:
+		return ErrClassRejected
+	default:
+		return ErrClassTransient
+	}
+}
+
+// localMobile formats an E.164 number the way Iranian providers expect:
+// 09xxxxxxxxx for Iran, 00<country><number> otherwise.
+func localMobile(phone string) string {
+	if strings.HasPrefix(phone, "+98") {
+		return "0" + phone[3:]
+	}
+	return "00" + strings.TrimPrefix(phone, "+")
+}
@@ -0,0 +1,42 @@
+package dispatcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+)
+
+// genericAdapter posts our SMS JSON to base_url + lane path and treats any 2xx as accepted.
+// Providers that answer {"message_id": "..."} get DLR matching.
+type genericAdapter struct {
+	cfg AdapterConfig
+}
+
+func (a *genericAdapter) NewRequest(ctx context.Context, sms model.SMS, express bool) (*http.Request, error) {
+	path := a.cfg.NormalPath
+	if express {
+		path = a.cfg.ExpressPath
+	}
+	b, _ := json.Marshal(sms)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.cfg.BaseURL+path, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+func (a *genericAdapter) ParseResponse(status int, body []byte) Response {
+	if status/100 != 2 {
+		return Response{Class: classifyHTTP(status), Code: strconv.Itoa(status), Detail: http.StatusText(status)}
+	}
+	var out struct {
+		MessageID string `json:"message_id"`
+	}
+	_ = json.Unmarshal(body, &out) // best effort
+	return Response{Accepted: true, MessageID: out.MessageID}
+}
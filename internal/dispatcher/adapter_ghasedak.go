@@ -0,0 +1,82 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+)
+
+// ghasedakAdapter speaks https://api.ghasedak.me/v2/sms/send/simple.
+type ghasedakAdapter struct {
+	cfg AdapterConfig
+}
+
+func newGhasedakAdapter(cfg AdapterConfig) (*ghasedakAdapter, error) {
+	if cfg.APIKey == "" {
+		return nil, errors.New("ghasedak provider needs api_key")
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.ghasedak.me"
+	}
+	return &ghasedakAdapter{cfg: cfg}, nil
+}
+
+func (a *ghasedakAdapter) NewRequest(ctx context.Context, sms model.SMS, express bool) (*http.Request, error) {
+	form := url.Values{}
+	form.Set("receptor", localMobile(sms.Phone))
+	form.Set("message", sms.Text)
+	if line := a.cfg.senderFor(express); line != "" {
+		form.Set("linenumber", line)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.cfg.BaseURL+"/v2/sms/send/simple", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("apikey", a.cfg.APIKey)
+	return req, nil
+}
+
+// ParseResponse reads {"result":{"code":200,"message":"success"},"items":[123]}.
+func (a *ghasedakAdapter) ParseResponse(status int, body []byte) Response {
+	var out struct {
+		Result struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"result"`
+		Items []json.Number `json:"items"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil || out.Result.Code == 0 {
+		if status/100 == 2 {
+			return Response{Class: ErrClassTransient, Code: strconv.Itoa(status), Detail: "unreadable response"}
+		}
+		return Response{Class: classifyHTTP(status), Code: strconv.Itoa(status), Detail: http.StatusText(status)}
+	}
+
+	code := out.Result.Code
+	if code == 200 {
+		var id string
+		if len(out.Items) > 0 {
+			id = out.Items[0].String()
+		}
+		return Response{Accepted: true, MessageID: id}
+	}
+
+	res := Response{Code: strconv.Itoa(code), Detail: out.Result.Message}
+	switch code {
+	case 401, 403, 418, 422: // bad key, access denied, no credit, bad line number
+		res.Class = ErrClassAuth
+	case 400, 411, 412, 413: // bad params, bad receptor, bad/empty text
+		res.Class = ErrClassRejected
+	default:
+		res.Class = ErrClassTransient
+	}
+	return res
+}
@@ -0,0 +1,53 @@
+package dispatcher
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestGhasedakAdapter(t *testing.T) {
+	cfg := AdapterConfig{Kind: "ghasedak", APIKey: "gk-123", Sender: "30005088"}
+	fixtures := []fixture{
+		{
+			name:   "success",
+			status: http.StatusOK,
+			body:   `{"result":{"code":200,"message":"success"},"items":[13465353]}`,
+			wantID: "13465353",
+		},
+		{
+			name:      "rejected number",
+			status:    http.StatusOK,
+			body:      `{"result":{"code":411,"message":"receptor is invalid"},"items":null}`,
+			wantClass: ErrClassRejected,
+			wantCode:  "411",
+		},
+		{
+			name:      "bad key",
+			status:    http.StatusUnauthorized,
+			body:      `{"result":{"code":401,"message":"apikey is invalid"},"items":null}`,
+			wantClass: ErrClassAuth,
+			wantCode:  "401",
+		},
+		{
+			name:      "server error",
+			status:    http.StatusInternalServerError,
+			body:      ``,
+			wantClass: ErrClassTransient,
+			wantCode:  "500",
+		},
+	}
+
+	runFixtures(t, cfg, fixtures, func(t *testing.T, got *captured) {
+		if got.method != http.MethodPost || got.path != "/v2/sms/send/simple" {
+			t.Fatalf("request = %s %s", got.method, got.path)
+		}
+		if got.header.Get("apikey") != "gk-123" {
+			t.Fatalf("apikey header = %q", got.header.Get("apikey"))
+		}
+		form, _ := url.ParseQuery(got.body)
+		if form.Get("receptor") != "09121234567" || form.Get("message") != "Hello" || form.Get("linenumber") != "30005088" {
+			t.Fatalf("form = %v", form)
+		}
+	})
+}
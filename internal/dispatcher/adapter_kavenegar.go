@@ -0,0 +1,85 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+)
+
+// kavenegarAdapter speaks https://api.kavenegar.com/v1/{API-KEY}/sms/send.json.
+type kavenegarAdapter struct {
+	cfg AdapterConfig
+}
+
+func newKavenegarAdapter(cfg AdapterConfig) (*kavenegarAdapter, error) {
+	if cfg.APIKey == "" {
+		return nil, errors.New("kavenegar provider needs api_key")
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.kavenegar.com"
+	}
+	return &kavenegarAdapter{cfg: cfg}, nil
+}
+
+func (a *kavenegarAdapter) NewRequest(ctx context.Context, sms model.SMS, express bool) (*http.Request, error) {
+	form := url.Values{}
+	form.Set("receptor", localMobile(sms.Phone))
+	form.Set("message", sms.Text)
+	if line := a.cfg.senderFor(express); line != "" {
+		form.Set("sender", line)
+	}
+
+	endpoint := a.cfg.BaseURL + "/v1/" + url.PathEscape(a.cfg.APIKey) + "/sms/send.json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// ParseResponse reads {"return":{"status":200,"message":".."},"entries":[{"messageid":123,..}]}.
+// Kavenegar reports errors both as the HTTP status and as return.status.
+func (a *kavenegarAdapter) ParseResponse(status int, body []byte) Response {
+	var out struct {
+		Return struct {
+			Status  int    `json:"status"`
+			Message string `json:"message"`
+		} `json:"return"`
+		Entries []struct {
+			MessageID json.Number `json:"messageid"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil || out.Return.Status == 0 {
+		if status/100 == 2 {
+			return Response{Class: ErrClassTransient, Code: strconv.Itoa(status), Detail: "unreadable response"}
+		}
+		return Response{Class: classifyHTTP(status), Code: strconv.Itoa(status), Detail: http.StatusText(status)}
+	}
+
+	code := out.Return.Status
+	if code == 200 {
+		var id string
+		if len(out.Entries) > 0 {
+			id = out.Entries[0].MessageID.String()
+		}
+		return Response{Accepted: true, MessageID: id}
+	}
+
+	res := Response{Code: strconv.Itoa(code), Detail: out.Return.Message}
+	switch code {
+	case 401, 403, 412, 418, 501: // account disabled, bad key, bad sender line, no credit, owner only
+		res.Class = ErrClassAuth
+	case 400, 411, 413, 414, 422: // bad params, bad receptor, bad/long text, too many receptors, bad chars
+		res.Class = ErrClassRejected
+	default:
+		res.Class = ErrClassTransient
+	}
+	return res
+}
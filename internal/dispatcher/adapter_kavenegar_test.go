@@ -0,0 +1,78 @@
+package dispatcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+)
+
+func TestKavenegarAdapter(t *testing.T) {
+	cfg := AdapterConfig{Kind: "kavenegar", APIKey: "4A6B-KEY", Sender: "10004346"}
+	fixtures := []fixture{
+		{
+			name:   "success",
+			status: http.StatusOK,
+			body: `{"return":{"status":200,"message":"تایید شد"},"entries":[{"messageid":8792343,"message":"Hello",` +
+				`"status":1,"statustext":"در صف ارسال","sender":"10004346","receptor":"09121234567","date":1356619709,"cost":120}]}`,
+			wantID: "8792343",
+		},
+		{
+			name:      "rejected number",
+			status:    411,
+			body:      `{"return":{"status":411,"message":"گیرنده نامعتبر است"},"entries":null}`,
+			wantClass: ErrClassRejected,
+			wantCode:  "411",
+		},
+		{
+			name:      "bad key",
+			status:    http.StatusForbidden,
+			body:      `{"return":{"status":403,"message":"کد شناسائی API-Key معتبر نمی‌باشد"},"entries":null}`,
+			wantClass: ErrClassAuth,
+			wantCode:  "403",
+		},
+		{
+			name:      "server error",
+			status:    http.StatusBadGateway,
+			body:      `<html><body><h1>502 Bad Gateway</h1></body></html>`,
+			wantClass: ErrClassTransient,
+			wantCode:  "502",
+		},
+	}
+
+	runFixtures(t, cfg, fixtures, func(t *testing.T, got *captured) {
+		if got.method != http.MethodPost || got.path != "/v1/4A6B-KEY/sms/send.json" {
+			t.Fatalf("request = %s %s", got.method, got.path)
+		}
+		form, _ := url.ParseQuery(got.body)
+		if form.Get("receptor") != "09121234567" || form.Get("message") != "Hello" || form.Get("sender") != "10004346" {
+			t.Fatalf("form = %v", form)
+		}
+	})
+}
+
+// The key is part of Kavenegar's URL path; transport errors must not carry it into logs.
+func TestKavenegarTransportErrorHidesKey(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	base := srv.URL
+	srv.Close() // nothing listens any more: the request fails in transport
+
+	a, err := NewAdapter(AdapterConfig{Kind: "kavenegar", APIKey: "SECRET-KEY", BaseURL: base})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewHTTPProvider("kavenegar", a, 1000, nil).SendNormal(context.Background(), model.SMS{Phone: "+989121234567", Text: "Hi"})
+	if err == nil {
+		t.Fatal("want a transport error")
+	}
+	if strings.Contains(err.Error(), "SECRET-KEY") {
+		t.Fatalf("error leaks the api key: %v", err)
+	}
+	if ClassOf(err) != ErrClassTransient {
+		t.Fatalf("ClassOf = %s, want transient", ClassOf(err))
+	}
+}
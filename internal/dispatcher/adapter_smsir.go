@@ -0,0 +1,88 @@
+package dispatcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+)
+
+// smsirAdapter speaks the sms.ir REST API (POST https://api.sms.ir/v1/send/bulk).
+type smsirAdapter struct {
+	cfg AdapterConfig
+}
+
+func newSMSIRAdapter(cfg AdapterConfig) (*smsirAdapter, error) {
+	if cfg.APIKey == "" {
+		return nil, errors.New("smsir provider needs api_key")
+	}
+	if cfg.Sender == "" {
+		return nil, errors.New("smsir provider needs sender (line number)")
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.sms.ir"
+	}
+	return &smsirAdapter{cfg: cfg}, nil
+}
+
+func (a *smsirAdapter) NewRequest(ctx context.Context, sms model.SMS, express bool) (*http.Request, error) {
+	line, err := strconv.ParseInt(a.cfg.senderFor(express), 10, 64)
+	if err != nil {
+		return nil, errors.New("smsir sender must be numeric")
+	}
+	b, _ := json.Marshal(map[string]any{
+		"lineNumber":  line,
+		"messageText": sms.Text,
+		"mobiles":     []string{localMobile(sms.Phone)},
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.cfg.BaseURL+"/v1/send/bulk", bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-API-KEY", a.cfg.APIKey)
+	return req, nil
+}
+
+// ParseResponse reads {"status":1,"message":"..","data":{"packId":"..","messageIds":[123],"cost":1}}.
+func (a *smsirAdapter) ParseResponse(status int, body []byte) Response {
+	var out struct {
+		Status  *int   `json:"status"`
+		Message string `json:"message"`
+		Data    struct {
+			MessageIDs []json.Number `json:"messageIds"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil || out.Status == nil {
+		if status/100 == 2 {
+			return Response{Class: ErrClassTransient, Code: strconv.Itoa(status), Detail: "unreadable response"}
+		}
+		return Response{Class: classifyHTTP(status), Code: strconv.Itoa(status), Detail: http.StatusText(status)}
+	}
+
+	code := *out.Status
+	if code == 1 {
+		var id string
+		if len(out.Data.MessageIDs) > 0 {
+			id = out.Data.MessageIDs[0].String()
+		}
+		return Response{Accepted: true, MessageID: id}
+	}
+
+	res := Response{Code: strconv.Itoa(code), Detail: out.Message}
+	switch code {
+	case 10, 11, 12, 13, 14, 101, 102: // key invalid/inactive/ip-bound, account off, bad line, no credit
+		res.Class = ErrClassAuth
+	case 103, 104, 105: // empty text, bad mobile, too many mobiles
+		res.Class = ErrClassRejected
+	default: // 0 = server error, 429-style throttling, unknown
+		res.Class = ErrClassTransient
+	}
+	return res
+}
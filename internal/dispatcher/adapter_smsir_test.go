@@ -0,0 +1,60 @@
+package dispatcher
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestSMSIRAdapter(t *testing.T) {
+	cfg := AdapterConfig{Kind: "smsir", APIKey: "ir-key", Sender: "30007732"}
+	fixtures := []fixture{
+		{
+			name:   "success",
+			status: http.StatusOK,
+			body:   `{"status":1,"message":"موفق","data":{"packId":"3d1f2b8e-5a3c-4a1e-9c7f-2b6f1f0e8a11","messageIds":[89545112],"cost":1.0}}`,
+			wantID: "89545112",
+		},
+		{
+			name:      "rejected number",
+			status:    http.StatusBadRequest,
+			body:      `{"status":104,"message":"شماره موبایل نامعتبر است","data":null}`,
+			wantClass: ErrClassRejected,
+			wantCode:  "104",
+		},
+		{
+			name:      "bad key",
+			status:    http.StatusUnauthorized,
+			body:      `{"status":11,"message":"کلید وب سرویس نامعتبر است","data":null}`,
+			wantClass: ErrClassAuth,
+			wantCode:  "11",
+		},
+		{
+			name:      "server error",
+			status:    http.StatusServiceUnavailable,
+			body:      `<html><body>Service Unavailable</body></html>`,
+			wantClass: ErrClassTransient,
+			wantCode:  "503",
+		},
+	}
+
+	runFixtures(t, cfg, fixtures, func(t *testing.T, got *captured) {
+		if got.method != http.MethodPost || got.path != "/v1/send/bulk" {
+			t.Fatalf("request = %s %s", got.method, got.path)
+		}
+		if got.header.Get("X-API-KEY") != "ir-key" {
+			t.Fatalf("X-API-KEY = %q", got.header.Get("X-API-KEY"))
+		}
+		var body struct {
+			LineNumber  int64    `json:"lineNumber"`
+			MessageText string   `json:"messageText"`
+			Mobiles     []string `json:"mobiles"`
+		}
+		if err := json.Unmarshal([]byte(got.body), &body); err != nil {
+			t.Fatalf("body %q: %v", got.body, err)
+		}
+		if body.LineNumber != 30007732 || body.MessageText != "Hello" || len(body.Mobiles) != 1 || body.Mobiles[0] != "09121234567" {
+			t.Fatalf("body = %+v", body)
+		}
+	})
+}
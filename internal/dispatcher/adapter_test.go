@@ -0,0 +1,87 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+)
+
+// fixture is one recorded provider reply.
+type fixture struct {
+	name   string
+	status int
+	body   string
+
+	wantID    string     // accepted with this provider message id
+	wantClass ErrorClass // or failed with this class and code
+	wantCode  string
+}
+
+// captured is what the stub server saw of the last request.
+type captured struct {
+	method, path string
+	header       http.Header
+	body         string
+}
+
+// replay serves f from a local server and returns the server and the request it received.
+func replay(t *testing.T, f fixture) (*httptest.Server, *captured) {
+	t.Helper()
+	got := &captured{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		*got = captured{method: r.Method, path: r.URL.EscapedPath(), header: r.Header.Clone(), body: string(b)}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(f.status)
+		_, _ = io.WriteString(w, f.body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, got
+}
+
+// runFixtures sends one message through an HTTPProvider of cfg for each fixture and checks
+// the result; check, when set, inspects the outgoing request.
+func runFixtures(t *testing.T, cfg AdapterConfig, fixtures []fixture, check func(t *testing.T, got *captured)) {
+	t.Helper()
+	for _, f := range fixtures {
+		t.Run(f.name, func(t *testing.T) {
+			srv, got := replay(t, f)
+			cfg := cfg
+			cfg.BaseURL = srv.URL
+			a, err := NewAdapter(cfg)
+			if err != nil {
+				t.Fatalf("NewAdapter: %v", err)
+			}
+			p := NewHTTPProvider(cfg.Kind, a, 1000, nil)
+
+			res, err := p.SendNormal(context.Background(), model.SMS{Phone: "+989121234567", Text: "Hello", Type: model.SMSTypeNormal})
+			if f.wantClass == "" {
+				if err != nil {
+					t.Fatalf("send: %v", err)
+				}
+				if res.Provider != cfg.Kind || res.MessageID != f.wantID {
+					t.Fatalf("result = %+v, want provider %s id %s", res, cfg.Kind, f.wantID)
+				}
+			} else {
+				var se *SendError
+				if !errors.As(err, &se) {
+					t.Fatalf("err = %v, want *SendError", err)
+				}
+				if se.Class != f.wantClass || se.Code != f.wantCode {
+					t.Fatalf("class/code = %s/%s, want %s/%s (%v)", se.Class, se.Code, f.wantClass, f.wantCode, err)
+				}
+				if ClassOf(err) != f.wantClass {
+					t.Fatalf("ClassOf = %s, want %s", ClassOf(err), f.wantClass)
+				}
+			}
+			if check != nil {
+				check(t, got)
+			}
+		})
+	}
+}
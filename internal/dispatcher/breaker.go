@@ -0,0 +1,94 @@
+package dispatcher
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Breaker gates calls to one provider.
+type Breaker interface {
+	Ready() bool           // would a call be admitted now (no side effects)
+	Admit() (Permit, bool) // admit a call; in half-open this spends a probe
+	// Record reports the outcome of the call Admit returned p for.
+	Record(p Permit, latency time.Duration, failed bool)
+	// Release gives back p when its call was admitted but never made.
+	Release(p Permit)
+}
+
+// Permit identifies one admitted call, so a breaker can tell the outcome of its half-open
+// probe from a call admitted before it opened that happens to finish during the probe.
+type Permit struct {
+	probe  uint64 // local half-open round the call probes; 0 for an ordinary call
+	shared uint64 // cluster probe this call holds (SharedBreaker); 0 if none
+}
+
+type permitKey struct{}
+
+// withPermit carries the permit from Provider.Acquire to the send that follows it.
+func withPermit(ctx context.Context, p Permit) context.Context {
+	return context.WithValue(ctx, permitKey{}, p)
+}
+
+// permitOf returns the permit in ctx, or an ordinary call's when there is none.
+func permitOf(ctx context.Context) Permit {
+	p, _ := ctx.Value(permitKey{}).(Permit)
+	return p
+}
+
+// Admit adapts MicroBreaker to Breaker; it has a single probe, marked so Release can free it.
+func (b *MicroBreaker) Admit() (Permit, bool) {
+	probe, ok := b.acquire()
+	if probe {
+		return Permit{probe: 1}, ok
+	}
+	return Permit{}, ok
+}
+
+// Release frees the probe slot of an unused probe permit.
+func (b *MicroBreaker) Release(p Permit) {
+	if p.probe != 0 {
+		b.releaseProbe()
+	}
+}
+
+// Record adapts MicroBreaker to Breaker; it ignores latency.
+func (b *MicroBreaker) Record(_ Permit, _ time.Duration, failed bool) {
+	if failed {
+		b.OnFailure()
+		return
+	}
+	b.OnSuccess()
+}
+
+var _ Breaker = (*MicroBreaker)(nil)
+
+// Clock is the time source of a breaker; FakeClock makes tests deterministic.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// FakeClock is a manually advanced Clock.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFakeClock(start time.Time) *FakeClock { return &FakeClock{now: start} }
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
@@ -13,6 +13,17 @@ const (
 	halfOpen
 )
 
+func (s state) String() string {
+	switch s {
+	case open:
+		return "open"
+	case halfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
 type MicroBreaker struct {
 	mu               sync.Mutex
 	st               state
@@ -43,29 +54,44 @@ func (b *MicroBreaker) Ready() bool {
 }
 
 func (b *MicroBreaker) TryAcquire() bool {
+	_, ok := b.acquire()
+	return ok
+}
+
+// acquire is TryAcquire that also reports whether the call admitted is the probe.
+func (b *MicroBreaker) acquire() (probe, ok bool) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
 	now := time.Now()
 	switch b.st {
 	case closed:
-		return true
+		return false, true
 	case open:
 		if now.After(b.nextTryAt) && !b.probeInFlight {
 			b.st = halfOpen
 			b.probeInFlight = true
-			return true
+			return true, true
 		}
-		return false
+		return false, false
 	case halfOpen:
 		if !b.probeInFlight {
 			b.probeInFlight = true
-			return true
+			return true, true
 		}
-		return false
+		return false, false
 	default:
-		return true
+		return false, true
+	}
+}
+
+// releaseProbe lets another call probe when the admitted probe was never sent.
+func (b *MicroBreaker) releaseProbe() {
+	b.mu.Lock()
+	if b.st == halfOpen {
+		b.probeInFlight = false
 	}
+	b.mu.Unlock()
 }
 
 func (b *MicroBreaker) OnSuccess() {
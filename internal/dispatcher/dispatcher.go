@@ -2,9 +2,11 @@ package dispatcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"sync/atomic"
+	"time"
 
+	"github.com/jmehdipour/sms-gateway/internal/metrics"
 	"github.com/jmehdipour/sms-gateway/internal/model"
 )
 
@@ -18,9 +20,23 @@ var (
 	ErrNoAcquire = fmt.Errorf("provider not acquired")
 )
 
+const (
+	// maxFreeTries bounds the refusals and throttle waits a message may go through without
+	// spending one of its attempts.
+	maxFreeTries = 16
+	// maxThrottleWait is the longest a message waits for a throttled provider.
+	maxThrottleWait = time.Second
+)
+
+// RouteTable restricts destinations to providers; ok is false when the default pool applies.
+type RouteTable interface {
+	Route(ctx context.Context, phone string, lane model.SMSType) (providers []string, ok bool)
+}
+
 type Dispatcher struct {
 	providers          []Provider
-	roundRobinCounter  atomic.Uint64
+	strategies         map[model.SMSType]RoutingStrategy
+	routes             RouteTable
 	maxAttemptsNormal  int
 	maxAttemptsExpress int
 }
@@ -34,74 +50,178 @@ func NewDispatcher(provs []Provider, maxAttemptsExpress, maxAttemptsNormal int)
 		maxAttemptsNormal = 2
 	}
 
-	return &Dispatcher{providers: provs, maxAttemptsExpress: maxAttemptsExpress, maxAttemptsNormal: maxAttemptsNormal}
+	return &Dispatcher{
+		providers: provs,
+		strategies: map[model.SMSType]RoutingStrategy{
+			model.SMSTypeNormal:  &roundRobin{},
+			model.SMSTypeExpress: &roundRobin{},
+		},
+		maxAttemptsExpress: maxAttemptsExpress,
+		maxAttemptsNormal:  maxAttemptsNormal,
+	}
+}
+
+// SetStrategy replaces the routing strategy of lane (round-robin by default).
+func (d *Dispatcher) SetStrategy(lane model.SMSType, s RoutingStrategy) {
+	d.strategies[lane] = s
 }
 
-func (d *Dispatcher) selectProvider() (Provider, error) {
-	healthy := make([]Provider, 0, len(d.providers))
-	for _, p := range d.providers {
-		if p.Ready() {
-			healthy = append(healthy, p)
+// SetRoutes installs prefix routing rules (none by default).
+func (d *Dispatcher) SetRoutes(rt RouteTable) {
+	d.routes = rt
+}
+
+// selectProvider chooses among ready providers, preferring ones not yet tried for this
+// message so retries fail over instead of repeating. A matching route rule limits the
+// choice to its providers, taken in rule order; otherwise the lane's strategy picks.
+func (d *Dispatcher) selectProvider(ctx context.Context, phone string, lane model.SMSType, tried map[string]bool) (Provider, error) {
+	pool, strategy := d.providers, d.strategies[lane].Name()
+	if d.routes != nil {
+		if names, ok := d.routes.Route(ctx, phone, lane); ok {
+			pool, strategy = d.byName(names), "rule"
+		}
+	}
+
+	healthy := make([]Provider, 0, len(pool))
+	fresh := make([]Provider, 0, len(pool))
+	for _, p := range pool {
+		if !p.Ready() {
+			continue
+		}
+		healthy = append(healthy, p)
+		if !tried[p.Name()] {
+			fresh = append(fresh, p)
 		}
 	}
 
 	if len(healthy) == 0 {
 		return nil, ErrNoHealthy
 	}
+	if len(fresh) > 0 {
+		healthy = fresh
+	}
+
+	var p Provider
+	if strategy == "rule" {
+		p = healthy[0]
+	} else {
+		p = d.strategies[lane].Pick(healthy, lane)
+	}
+	metrics.RoutingDecisionsTotal.WithLabelValues(lane.String(), strategy, p.Name()).Inc()
+
+	return p, nil
+}
 
-	x := d.roundRobinCounter.Add(1)
-	idx := int((x - 1) % uint64(len(healthy)))
+// throttleWait is how long until the first provider that is held back only by its limits
+// frees up, capped at maxThrottleWait; 0 when no provider is merely throttled.
+func (d *Dispatcher) throttleWait() time.Duration {
+	var wait time.Duration
+	for _, p := range d.providers {
+		t, ok := p.(interface{ throttledFor() time.Duration })
+		if !ok {
+			continue
+		}
+		if w := t.throttledFor(); w > 0 && (wait == 0 || w < wait) {
+			wait = w
+		}
+	}
+	return min(wait, maxThrottleWait)
+}
 
-	return healthy[idx], nil
+// byName returns the configured providers named in names, in that order.
+func (d *Dispatcher) byName(names []string) []Provider {
+	out := make([]Provider, 0, len(names))
+	for _, n := range names {
+		for _, p := range d.providers {
+			if p.Name() == n {
+				out = append(out, p)
+				break
+			}
+		}
+	}
+	return out
 }
 
-func (d *Dispatcher) tryOnce(ctx context.Context, sms model.SMS, express bool) error {
-	p, err := d.selectProvider()
+func (d *Dispatcher) tryOnce(ctx context.Context, sms model.SMS, lane model.SMSType, tried map[string]bool) (SendResult, error) {
+	p, err := d.selectProvider(ctx, sms.Phone, lane, tried)
 	if err != nil {
-		return err
+		return SendResult{}, err
 	}
+	tried[p.Name()] = true
 
-	if !p.Acquire() {
-		return ErrNoAcquire
+	ctx, ok := p.Acquire(ctx)
+	if !ok {
+		return SendResult{}, ErrNoAcquire
 	}
 
-	if express {
-		return p.SendExpress(ctx, sms)
+	start := time.Now()
+	var res SendResult
+	if lane == model.SMSTypeExpress {
+		res, err = p.SendExpress(ctx, sms)
+	} else {
+		res, err = p.SendNormal(ctx, sms)
 	}
+	d.observe(p.Name(), lane, time.Since(start), err)
 
-	return p.SendNormal(ctx, sms)
+	return res, err
 }
 
-func (d *Dispatcher) SendExpress(ctx context.Context, sms model.SMS) error {
-	var last error
-	for i := 0; i < d.maxAttemptsExpress; i++ {
-		if err := d.tryOnce(ctx, sms, true); err == nil {
-			return nil
-		} else {
-			last = err
-		}
-	}
+// observe feeds an attempt's outcome to the lane's strategy and to metrics.
+func (d *Dispatcher) observe(provider string, lane model.SMSType, latency time.Duration, err error) {
+	d.strategies[lane].Observe(provider, latency, err)
 
-	if last == nil {
-		last = fmt.Errorf("send express failed")
+	result := "ok"
+	if err != nil {
+		result = string(ClassOf(err))
 	}
+	metrics.ProviderAttemptsTotal.WithLabelValues(provider, lane.String(), result).Inc()
+	metrics.ProviderLatencySeconds.WithLabelValues(provider, lane.String()).Observe(latency.Seconds())
+}
+
+func (d *Dispatcher) SendExpress(ctx context.Context, sms model.SMS) (SendResult, error) {
+	return d.send(ctx, sms, model.SMSTypeExpress, d.maxAttemptsExpress)
+}
 
-	return last
+func (d *Dispatcher) SendNormal(ctx context.Context, sms model.SMS) (SendResult, error) {
+	return d.send(ctx, sms, model.SMSTypeNormal, d.maxAttemptsNormal)
 }
 
-func (d *Dispatcher) SendNormal(ctx context.Context, sms model.SMS) error {
+// send makes up to attempts sends. A provider that refuses the call at Acquire (throttled, or
+// its probe is taken) did not fail the message, so the next provider is tried for free; when
+// every provider is only throttled the message waits for the first to free up.
+func (d *Dispatcher) send(ctx context.Context, sms model.SMS, lane model.SMSType, attempts int) (SendResult, error) {
 	var last error
-	for i := 0; i < d.maxAttemptsNormal; i++ {
-		if err := d.tryOnce(ctx, sms, false); err == nil {
-			return nil
-		} else {
-			last = err
+	tried := make(map[string]bool, attempts)
+	for i, free := 0, maxFreeTries; i < attempts; {
+		res, err := d.tryOnce(ctx, sms, lane, tried)
+		if err == nil {
+			return res, nil
+		}
+		last = err
+		if free > 0 && errors.Is(err, ErrNoAcquire) {
+			free--
+			continue
+		}
+		if free > 0 && errors.Is(err, ErrNoHealthy) {
+			if wait := d.throttleWait(); wait > 0 {
+				free--
+				select {
+				case <-ctx.Done():
+					return SendResult{}, ctx.Err()
+				case <-time.After(wait):
+				}
+				continue
+			}
+		}
+		i++
+		if Final(err) { // another provider would refuse it too, or it is partly out already
+			break
 		}
 	}
-	
+
 	if last == nil {
-		last = fmt.Errorf("send normal failed")
+		last = fmt.Errorf("send %s failed", lane)
 	}
 
-	return last
+	return SendResult{}, last
 }
@@ -0,0 +1,29 @@
+package dispatcher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+)
+
+// refusing is a ready provider whose Acquire always refuses, like one at its TPS limit.
+type refusing struct{ named }
+
+func (refusing) Acquire(ctx context.Context) (context.Context, bool) { return ctx, false }
+
+func TestRefusedAcquireDoesNotSpendAnAttempt(t *testing.T) {
+	d := NewDispatcher([]Provider{refusing{"busy"}, named("ok")}, 1, 1)
+	d.SetStrategy(model.SMSTypeNormal, &priority{attrs: map[string]ProviderAttrs{"busy": {Priority: 1}, "ok": {Priority: 2}}})
+
+	if _, err := d.SendNormal(context.Background(), model.SMS{Phone: "+989121234567", Text: "hi"}); err != nil {
+		t.Fatalf("send with one attempt after a refusal: %v", err)
+	}
+}
+
+func TestRefusalsAreBounded(t *testing.T) {
+	d := NewDispatcher([]Provider{refusing{"busy"}}, 1, 1)
+	if _, err := d.SendNormal(context.Background(), model.SMS{Phone: "+989121234567", Text: "hi"}); err != ErrNoAcquire {
+		t.Fatalf("err = %v, want ErrNoAcquire", err)
+	}
+}
@@ -0,0 +1,187 @@
+package dispatcher
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/metrics"
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/util"
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript atomically admits one request against a provider's shared limits:
+// a token bucket of max_tps (burst = one second) and a set of concurrency leases that
+// expire on their own if a worker dies mid-send.
+// KEYS: bucket hash, lease zset. ARGV: tps, max_concurrency, lease id, lease ttl ms.
+// Returns {allowed, retry_after_ms, reason} with reason 1 = concurrency, 2 = tps.
+var takeScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local tps = tonumber(ARGV[1])
+local maxc = tonumber(ARGV[2])
+local ttl = tonumber(ARGV[4])
+
+if maxc > 0 then
+  redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', now)
+  if redis.call('ZCARD', KEYS[2]) >= maxc then
+    return {0, 20, 1}
+  end
+end
+
+if tps > 0 then
+  local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+  local tokens = tonumber(b[1]) or tps
+  local ts = tonumber(b[2]) or now
+  tokens = math.min(tps, tokens + (now - ts) * tps / 1000)
+  if tokens < 1 then
+    return {0, math.ceil((1 - tokens) * 1000 / tps), 2}
+  end
+  redis.call('HSET', KEYS[1], 'tokens', tostring(tokens - 1), 'ts', now)
+  redis.call('PEXPIRE', KEYS[1], 2000)
+end
+
+if maxc > 0 then
+  redis.call('ZADD', KEYS[2], now + ttl, ARGV[3])
+  redis.call('PEXPIRE', KEYS[2], ttl * 2)
+end
+return {1, 0, 0}
+`)
+
+// ProviderLimits are a provider's contractual caps, shared by every sender replica.
+type ProviderLimits struct {
+	MaxTPS         int           // submits per second; 0 = unlimited
+	MaxConcurrency int           // requests in flight; 0 = unlimited
+	LeaseTTL       time.Duration // how long a crashed worker can hold a concurrency slot
+}
+
+// limitedProvider enforces ProviderLimits through Redis in front of another provider.
+// Ready answers locally from the last refusal's retry hint, so picking a provider costs
+// no round-trip; Acquire takes the slot once the provider's breaker has admitted the call,
+// so a refused call burns no token and a throttled one hands its breaker permit back. Leases are interchangeable within a process, so
+// Acquire pushes one and the send that follows pops it.
+type limitedProvider struct {
+	Provider
+	rdb    *redis.Client
+	limits ProviderLimits
+	keys   []string
+
+	limitedUntil atomic.Int64 // unix nanos
+
+	mu     sync.Mutex
+	leases []string
+}
+
+// Limited wraps p so it respects limits across replicas; with no limits p is returned as is.
+func Limited(p Provider, rdb *redis.Client, limits ProviderLimits) Provider {
+	if rdb == nil || (limits.MaxTPS <= 0 && limits.MaxConcurrency <= 0) {
+		return p
+	}
+	if limits.LeaseTTL <= 0 {
+		limits.LeaseTTL = 30 * time.Second
+	}
+	tag := "{" + p.Name() + "}" // one hash slot per provider on Redis Cluster
+	return &limitedProvider{
+		Provider: p,
+		rdb:      rdb,
+		limits:   limits,
+		keys:     []string{"pl:" + tag + ":tps", "pl:" + tag + ":conc"},
+	}
+}
+
+func (p *limitedProvider) Ready() bool {
+	return time.Now().UnixNano() >= p.limitedUntil.Load() && p.Provider.Ready()
+}
+
+// throttledFor is how long the limits still hold back a provider that is otherwise ready.
+func (p *limitedProvider) throttledFor() time.Duration {
+	d := time.Until(time.Unix(0, p.limitedUntil.Load()))
+	if d <= 0 || !p.Provider.Ready() {
+		return 0
+	}
+	return d
+}
+
+func (p *limitedProvider) Acquire(ctx context.Context) (context.Context, bool) {
+	ctx, ok := p.Provider.Acquire(ctx)
+	if !ok {
+		return ctx, false
+	}
+	lease, ok := p.take()
+	if !ok {
+		p.Provider.Release(ctx)
+		return ctx, false
+	}
+	p.mu.Lock()
+	p.leases = append(p.leases, lease)
+	p.mu.Unlock()
+	return ctx, true
+}
+
+func (p *limitedProvider) Release(ctx context.Context) {
+	p.release(p.popLease())
+	p.Provider.Release(ctx)
+}
+
+func (p *limitedProvider) SendNormal(ctx context.Context, sms model.SMS) (SendResult, error) {
+	defer p.release(p.popLease())
+	return p.Provider.SendNormal(ctx, sms)
+}
+
+func (p *limitedProvider) SendExpress(ctx context.Context, sms model.SMS) (SendResult, error) {
+	defer p.release(p.popLease())
+	return p.Provider.SendExpress(ctx, sms)
+}
+
+// take asks Redis for a slot. Redis trouble fails open: the provider's own breaker and
+// 429 handling still apply, and sending must not stop because the limiter is down.
+func (p *limitedProvider) take() (string, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	lease := util.New()
+	res, err := takeScript.Run(ctx, p.rdb, p.keys,
+		p.limits.MaxTPS, p.limits.MaxConcurrency, lease, p.limits.LeaseTTL.Milliseconds()).Int64Slice()
+	if err != nil || len(res) != 3 {
+		log.Printf("[limiter] %s: redis err: %v (allowing)", p.Name(), err)
+		return "", true
+	}
+	if res[0] == 1 {
+		return lease, true
+	}
+
+	p.limitedUntil.Store(time.Now().Add(time.Duration(res[1]) * time.Millisecond).UnixNano())
+	reason := "tps"
+	if res[2] == 1 {
+		reason = "concurrency"
+	}
+	metrics.ProviderThrottledTotal.WithLabelValues(p.Name(), reason).Inc()
+	return "", false
+}
+
+func (p *limitedProvider) popLease() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := len(p.leases)
+	if n == 0 {
+		return ""
+	}
+	lease := p.leases[n-1]
+	p.leases = p.leases[:n-1]
+	return lease
+}
+
+func (p *limitedProvider) release(lease string) {
+	if lease == "" || p.limits.MaxConcurrency <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := p.rdb.ZRem(ctx, p.keys[1], lease).Err(); err != nil {
+		log.Printf("[limiter] %s: release lease: %v (expires in %s)", p.Name(), err, p.limits.LeaseTTL)
+	}
+}
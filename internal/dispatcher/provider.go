@@ -1,104 +1,129 @@
 package dispatcher
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/jmehdipour/sms-gateway/internal/model"
 )
 
+// SendResult describes a message accepted by a provider.
+type SendResult struct {
+	Provider  string // provider name
+	MessageID string // provider-side message id (used to match DLRs); may be empty
+}
+
 type Provider interface {
 	Name() string
 	Ready() bool
-	Acquire() bool
-	SendNormal(ctx context.Context, sms model.SMS) error
-	SendExpress(ctx context.Context, sms model.SMS) error
+	// Acquire admits one call; pass the returned ctx to the send it admits.
+	Acquire(ctx context.Context) (context.Context, bool)
+	// Release gives back what Acquire took when the send will not happen.
+	Release(ctx context.Context)
+	SendNormal(ctx context.Context, sms model.SMS) (SendResult, error)
+	SendExpress(ctx context.Context, sms model.SMS) (SendResult, error)
 }
 
+// HTTPProvider sends through a provider's HTTP API; the Adapter owns the wire format.
 type HTTPProvider struct {
-	name        string
-	baseURL     string
-	normalPath  string
-	expressPath string
-	client      *http.Client
-	br          *MicroBreaker
+	name    string
+	adapter Adapter
+	client  *http.Client
+	br      Breaker
 }
 
-func NewHTTPProvider(
-	name, baseURL, normalPath, expressPath string,
-	timeoutMs, failThreshold, openForMs int,
-) *HTTPProvider {
+// NewHTTPProvider builds an HTTP provider; a nil br gets a MicroBreaker with defaults.
+func NewHTTPProvider(name string, adapter Adapter, timeoutMs int, br Breaker) *HTTPProvider {
 	if timeoutMs <= 0 {
 		timeoutMs = 3000
 	}
 
-	if failThreshold <= 0 {
-		failThreshold = 3
-	}
-
-	if openForMs <= 0 {
-		openForMs = 15000
+	if br == nil {
+		br = NewMicroBreaker(3, 15*time.Second)
 	}
 
 	return &HTTPProvider{
-		name:        name,
-		baseURL:     baseURL,
-		normalPath:  normalPath,
-		expressPath: expressPath,
-		client:      &http.Client{Timeout: time.Duration(timeoutMs) * time.Millisecond},
-		br:          NewMicroBreaker(failThreshold, time.Duration(openForMs)*time.Millisecond),
+		name:    name,
+		adapter: adapter,
+		client:  &http.Client{Timeout: time.Duration(timeoutMs) * time.Millisecond},
+		br:      br,
 	}
 }
 
-func (p *HTTPProvider) Name() string  { return p.name }
-func (p *HTTPProvider) Ready() bool   { return p.br.Ready() }
-func (p *HTTPProvider) Acquire() bool { return p.br.TryAcquire() }
+func (p *HTTPProvider) Name() string { return p.name }
+func (p *HTTPProvider) Ready() bool  { return p.br.Ready() }
+func (p *HTTPProvider) Acquire(ctx context.Context) (context.Context, bool) {
+	return acquire(ctx, p.br)
+}
 
-func (p *HTTPProvider) SendNormal(ctx context.Context, sms model.SMS) error {
-	if err := p.post(ctx, p.normalPath, sms); err != nil {
-		p.br.OnFailure()
-		return err
-	}
+func (p *HTTPProvider) Release(ctx context.Context) { p.br.Release(permitOf(ctx)) }
 
-	p.br.OnSuccess()
+func (p *HTTPProvider) SendNormal(ctx context.Context, sms model.SMS) (SendResult, error) {
+	return p.send(ctx, sms, false)
+}
 
-	return nil
+func (p *HTTPProvider) SendExpress(ctx context.Context, sms model.SMS) (SendResult, error) {
+	return p.send(ctx, sms, true)
 }
 
-func (p *HTTPProvider) SendExpress(ctx context.Context, sms model.SMS) error {
-	if err := p.post(ctx, p.expressPath, sms); err != nil {
-		p.br.OnFailure()
-		return err
+func (p *HTTPProvider) send(ctx context.Context, sms model.SMS, express bool) (SendResult, error) {
+	start := time.Now()
+	res, err := p.post(ctx, sms, express)
+	recordOutcome(ctx, p.br, time.Since(start), err)
+	if err != nil {
+		return SendResult{}, err
 	}
 
-	p.br.OnSuccess()
+	return res, nil
+}
+
+// acquire admits a call through br and returns ctx carrying its permit.
+func acquire(ctx context.Context, br Breaker) (context.Context, bool) {
+	permit, ok := br.Admit()
+	if !ok {
+		return ctx, false
+	}
+	return withPermit(ctx, permit), true
+}
 
-	return nil
+// recordOutcome feeds the breaker: a rejected message says nothing about provider health,
+// so only transient and auth failures count against it.
+func recordOutcome(ctx context.Context, br Breaker, latency time.Duration, err error) {
+	br.Record(permitOf(ctx), latency, err != nil && ClassOf(err) != ErrClassRejected)
 }
 
-func (p *HTTPProvider) post(ctx context.Context, path string, sms model.SMS) error {
-	b, _ := json.Marshal(sms)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, bytes.NewReader(b))
+func (p *HTTPProvider) post(ctx context.Context, sms model.SMS, express bool) (SendResult, error) {
+	req, err := p.adapter.NewRequest(ctx, sms, express)
 	if err != nil {
-		return err
+		return SendResult{}, &SendError{Provider: p.name, Class: ErrClassAuth, Code: "config", Detail: err.Error()}
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-
 	res, err := p.client.Do(req)
 	if err != nil {
-		return err
+		// *url.Error quotes the request URL, which carries the API key for some providers.
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			return SendResult{}, fmt.Errorf("provider=%s %s: %w", p.name, ue.Op, ue.Err)
+		}
+		return SendResult{}, fmt.Errorf("provider=%s: %w", p.name, err)
 	}
 
 	defer res.Body.Close()
 
-	if res.StatusCode/100 != 2 {
-		return fmt.Errorf("provider=%s path=%s status=%d", p.name, path, res.StatusCode)
+	body, err := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	if err != nil {
+		return SendResult{}, fmt.Errorf("provider=%s read body: %w", p.name, err)
+	}
+
+	out := p.adapter.ParseResponse(res.StatusCode, body)
+	if !out.Accepted {
+		return SendResult{}, &SendError{Provider: p.name, Class: out.Class, Code: out.Code, Detail: out.Detail}
 	}
 
-	return nil
+	return SendResult{Provider: p.name, MessageID: out.MessageID}, nil
 }
@@ -0,0 +1,217 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/dlr"
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/smpp"
+)
+
+const (
+	smppPartRetries    = 3 // extra tries for a later part of a long message
+	smppPartRetryDelay = 200 * time.Millisecond
+)
+
+// ReceiptSink applies delivery receipts a provider received in-band (dlr.Applier.Apply).
+type ReceiptSink func(ctx context.Context, provider string, receipts []dlr.Receipt) (dlr.Outcome, error)
+
+// SMPPOptions configures an SMPPProvider.
+type SMPPOptions struct {
+	smpp.Config
+	ExpressSource string // source address for the express lane (optional)
+	Concat        string // udh (default) | sar: how long messages are concatenated
+}
+
+// SMPPProvider sends over bound SMPP 3.4 transceiver sessions. Long messages go out as
+// concatenated parts; only the last part asks for a delivery receipt and its SMSC id is
+// the one stored, so one receipt settles the whole message.
+type SMPPProvider struct {
+	name          string
+	client        *smpp.Client
+	expressSource string
+	sar           bool
+	br            Breaker
+	receipts      ReceiptSink
+	ref           atomic.Uint32
+}
+
+// NewSMPPProvider starts binding in the background; the provider is not Ready until a
+// session is bound. A nil br gets a MicroBreaker with defaults; receipts may be nil to
+// ignore delivery receipts.
+func NewSMPPProvider(name string, opts SMPPOptions, br Breaker, receipts ReceiptSink) *SMPPProvider {
+	if br == nil {
+		br = NewMicroBreaker(3, 15*time.Second)
+	}
+
+	p := &SMPPProvider{
+		name:          name,
+		expressSource: opts.ExpressSource,
+		sar:           strings.EqualFold(opts.Concat, "sar"),
+		br:            br,
+		receipts:      receipts,
+	}
+	p.client = smpp.Dial(opts.Config, p.onDeliver)
+	return p
+}
+
+func (p *SMPPProvider) Name() string { return p.name }
+func (p *SMPPProvider) Ready() bool  { return p.client.Bound() && p.br.Ready() }
+func (p *SMPPProvider) Acquire(ctx context.Context) (context.Context, bool) {
+	return acquire(ctx, p.br)
+}
+
+func (p *SMPPProvider) Release(ctx context.Context) { p.br.Release(permitOf(ctx)) }
+
+// Close unbinds all sessions.
+func (p *SMPPProvider) Close() { p.client.Close() }
+
+func (p *SMPPProvider) SendNormal(ctx context.Context, sms model.SMS) (SendResult, error) {
+	return p.send(ctx, sms, false)
+}
+
+func (p *SMPPProvider) SendExpress(ctx context.Context, sms model.SMS) (SendResult, error) {
+	return p.send(ctx, sms, true)
+}
+
+func (p *SMPPProvider) send(ctx context.Context, sms model.SMS, express bool) (SendResult, error) {
+	start := time.Now()
+	id, err := p.submit(ctx, sms, express)
+	recordOutcome(ctx, p.br, time.Since(start), err)
+	if err != nil {
+		return SendResult{}, err
+	}
+
+	return SendResult{Provider: p.name, MessageID: id}, nil
+}
+
+func (p *SMPPProvider) submit(ctx context.Context, sms model.SMS, express bool) (string, error) {
+	dc, parts := smpp.Encode(sms.Text)
+	if len(parts) > 255 {
+		return "", &SendError{Provider: p.name, Class: ErrClassRejected, Code: "parts", Detail: "message too long"}
+	}
+
+	var src string
+	if express {
+		src = p.expressSource
+	}
+	ref := p.ref.Add(1)
+
+	var id string
+	for i, part := range parts {
+		m := smpp.SubmitSM{Source: src, Dest: sms.Phone, DataCoding: dc, ShortMessage: part}
+		if len(parts) > 1 {
+			if p.sar {
+				m.TLVs = smpp.SarTLVs(uint16(ref), len(parts), i+1)
+			} else {
+				m.ESMClass |= smpp.ESMClassUDHI
+				m.ShortMessage = append(smpp.UDH(byte(ref), len(parts), i+1), part...)
+			}
+		}
+		last := i == len(parts)-1
+		if last {
+			m.RegisteredDelivery = 1
+		}
+
+		got, err := p.submitPart(ctx, m, i > 0)
+		if err != nil {
+			if i > 0 {
+				return "", &SendError{Provider: p.name, Class: ErrClassPartial, Code: "partial",
+					Detail: fmt.Sprintf("%d of %d parts sent: %v", i, len(parts), err)}
+			}
+			return "", err
+		}
+		if last {
+			id = got
+		}
+	}
+	return id, nil
+}
+
+// submitPart submits one part. Once earlier parts are out (retry), transient failures are
+// retried on this provider: the message cannot fail over without repeating those parts.
+func (p *SMPPProvider) submitPart(ctx context.Context, m smpp.SubmitSM, retry bool) (string, error) {
+	for n := 0; ; n++ {
+		id, err := p.client.Submit(ctx, m)
+		if err == nil {
+			return id, nil
+		}
+		err = p.classify(err)
+		if !retry || n == smppPartRetries || ClassOf(err) != ErrClassTransient {
+			return "", err
+		}
+		select {
+		case <-ctx.Done():
+			return "", err
+		case <-time.After(smppPartRetryDelay):
+		}
+	}
+}
+
+// classify maps a submit_sm_resp command_status to an error class; connection-level
+// errors (not bound, timeouts, dropped sessions) stay plain errors and count as transient.
+func (p *SMPPProvider) classify(err error) error {
+	var st smpp.StatusError
+	if !errors.As(err, &st) {
+		return err
+	}
+
+	class := ErrClassTransient
+	switch uint32(st) {
+	case smpp.StatusInvDstAdr, smpp.StatusInvMsgLen:
+		class = ErrClassRejected
+	case smpp.StatusInvSrcAdr, smpp.StatusBindFail, smpp.StatusInvPaswd, smpp.StatusInvSysID:
+		class = ErrClassAuth
+	}
+	return &SendError{Provider: p.name, Class: class, Code: fmt.Sprintf("0x%08X", uint32(st)), Detail: err.Error()}
+}
+
+// onDeliver handles deliver_sm. A receipt that cannot be applied yet (DB error, or its id not
+// flushed by the sender) is answered with ESME_RX_T_APPN so the SMSC redelivers it later.
+func (p *SMPPProvider) onDeliver(d smpp.DeliverSM) uint32 {
+	rc, ok := smpp.ParseReceipt(d)
+	if !ok {
+		log.Printf("[smpp] %s: ignoring mobile-originated message from %s", p.name, d.Source)
+		return smpp.StatusOK
+	}
+
+	var st model.MessageStatus
+	switch rc.Stat {
+	case "DELIVRD":
+		st = model.StatusDelivered
+	case "EXPIRED":
+		st = model.StatusExpired
+	case "UNDELIV", "REJECTD", "DELETED", "UNKNOWN":
+		st = model.StatusUndelivered
+	case "ENROUTE", "ACCEPTD":
+		return smpp.StatusOK // still in progress
+	default:
+		log.Printf("[smpp] %s: unknown receipt stat %q for %s", p.name, rc.Stat, rc.MessageID)
+		return smpp.StatusOK
+	}
+	if p.receipts == nil {
+		return smpp.StatusOK
+	}
+
+	at := rc.DoneAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out, err := p.receipts(ctx, p.name, []dlr.Receipt{{ProviderMessageID: rc.MessageID, Status: st, At: at}})
+	if err != nil {
+		log.Printf("[smpp] %s: apply receipt %s: %v", p.name, rc.MessageID, err)
+		return smpp.StatusRxTAppn
+	}
+	if out.Redeliver() {
+		return smpp.StatusRxTAppn
+	}
+	return smpp.StatusOK
+}
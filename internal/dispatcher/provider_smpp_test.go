@@ -0,0 +1,234 @@
+package dispatcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/dlr"
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/smpp"
+	"github.com/jmehdipour/sms-gateway/internal/smpp/smpptest"
+)
+
+func newTestSMPP(t *testing.T, srv *smpptest.Server, concat string, sink ReceiptSink) *SMPPProvider {
+	t.Helper()
+	p := NewSMPPProvider("smsc", SMPPOptions{
+		Config: smpp.Config{
+			Addr:           srv.Addr,
+			SystemID:       "gw",
+			SourceAddr:     "98100020",
+			Timeout:        2 * time.Second,
+			ReconnectDelay: 10 * time.Millisecond,
+		},
+		Concat: concat,
+	}, nil, sink)
+	t.Cleanup(p.Close)
+
+	deadline := time.Now().Add(time.Second)
+	for !p.Ready() {
+		if time.Now().After(deadline) {
+			t.Fatal("provider never bound")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return p
+}
+
+// longText needs two GSM-7 parts.
+var longText = strings.Repeat("a", 200)
+
+func TestSMPPConcatUDH(t *testing.T) {
+	srv := smpptest.NewServer()
+	defer srv.Close()
+	p := newTestSMPP(t, srv, "", nil)
+
+	res, err := p.SendNormal(context.Background(), model.SMS{Phone: "+989121234567", Text: longText})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subs := srv.Submits()
+	if len(subs) != 2 {
+		t.Fatalf("parts = %d, want 2", len(subs))
+	}
+	ref := subs[0].ShortMessage[3]
+	for i, s := range subs {
+		if s.ESMClass&smpp.ESMClassUDHI == 0 {
+			t.Fatalf("part %d: UDHI not set", i+1)
+		}
+		if want := smpp.UDH(ref, 2, i+1); !bytes.HasPrefix(s.ShortMessage, want) {
+			t.Fatalf("part %d: UDH = % X, want % X", i+1, s.ShortMessage[:6], want)
+		}
+		if len(s.TLVs) != 0 {
+			t.Fatalf("part %d: unexpected TLVs %v", i+1, s.TLVs)
+		}
+	}
+	assertLastRegistered(t, subs, res)
+}
+
+func TestSMPPConcatSAR(t *testing.T) {
+	srv := smpptest.NewServer()
+	defer srv.Close()
+	p := newTestSMPP(t, srv, "sar", nil)
+
+	res, err := p.SendNormal(context.Background(), model.SMS{Phone: "+989121234567", Text: longText})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subs := srv.Submits()
+	if len(subs) != 2 {
+		t.Fatalf("parts = %d, want 2", len(subs))
+	}
+	ref := subs[0].TLVs[smpp.TagSarMsgRefNum]
+	for i, s := range subs {
+		if s.ESMClass&smpp.ESMClassUDHI != 0 {
+			t.Fatalf("part %d: UDHI set with SAR", i+1)
+		}
+		if !bytes.Equal(s.TLVs[smpp.TagSarMsgRefNum], ref) ||
+			!bytes.Equal(s.TLVs[smpp.TagSarTotalSegments], []byte{2}) ||
+			!bytes.Equal(s.TLVs[smpp.TagSarSegmentSeqnum], []byte{byte(i + 1)}) {
+			t.Fatalf("part %d: SAR TLVs = %v", i+1, s.TLVs)
+		}
+	}
+	assertLastRegistered(t, subs, res)
+}
+
+// assertLastRegistered checks that only the last part asks for a receipt and that its id
+// is the one returned for DLR matching.
+func assertLastRegistered(t *testing.T, subs []smpptest.Submit, res SendResult) {
+	t.Helper()
+	last := len(subs) - 1
+	for i, s := range subs {
+		want := byte(0)
+		if i == last {
+			want = 1
+		}
+		if s.RegisteredDelivery != want {
+			t.Fatalf("part %d: registered_delivery = %d, want %d", i+1, s.RegisteredDelivery, want)
+		}
+	}
+	if res.Provider != "smsc" || res.MessageID != subs[last].MessageID {
+		t.Fatalf("result = %+v, want id of last part %s", res, subs[last].MessageID)
+	}
+}
+
+func TestSMPPReceipts(t *testing.T) {
+	tests := []struct {
+		name string
+		out  dlr.Outcome
+		err  error
+		want uint32
+	}{
+		{name: "applied", out: dlr.Outcome{Applied: 1}, want: smpp.StatusOK},
+		{name: "ignored", out: dlr.Outcome{Ignored: 1}, want: smpp.StatusOK},
+		{name: "pending is redelivered", out: dlr.Outcome{Pending: 1}, want: smpp.StatusRxTAppn},
+		{name: "unmatched past grace is acknowledged", out: dlr.Outcome{Unmatched: 1}, want: smpp.StatusOK},
+		{name: "db error is redelivered", err: errors.New("db down"), want: smpp.StatusRxTAppn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := smpptest.NewServer()
+			defer srv.Close()
+
+			var got []dlr.Receipt
+			newTestSMPP(t, srv, "", func(_ context.Context, provider string, rs []dlr.Receipt) (dlr.Outcome, error) {
+				if provider != "smsc" {
+					t.Errorf("provider = %s", provider)
+				}
+				got = append(got, rs...)
+				return tt.out, tt.err
+			})
+
+			st, err := srv.Receipt("msg-9", "DELIVRD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if st != tt.want {
+				t.Fatalf("deliver_sm_resp = 0x%08X, want 0x%08X", st, tt.want)
+			}
+			if len(got) != 1 || got[0].ProviderMessageID != "msg-9" || got[0].Status != model.StatusDelivered {
+				t.Fatalf("receipts = %+v", got)
+			}
+		})
+	}
+}
+
+func TestSMPPSubmitErrorClasses(t *testing.T) {
+	srv := smpptest.NewServer()
+	defer srv.Close()
+	p := newTestSMPP(t, srv, "", nil)
+
+	for status, want := range map[uint32]ErrorClass{
+		smpp.StatusInvDstAdr: ErrClassRejected,
+		smpp.StatusInvSrcAdr: ErrClassAuth,
+		smpp.StatusThrottled: ErrClassTransient,
+	} {
+		srv.SetSubmitStatus(status)
+		_, err := p.SendNormal(context.Background(), model.SMS{Phone: "+989121234567", Text: "hi"})
+		if ClassOf(err) != want {
+			t.Fatalf("status 0x%08X: class = %s (%v), want %s", status, ClassOf(err), err, want)
+		}
+	}
+}
+
+func TestSMPPRebindAfterDrop(t *testing.T) {
+	srv := smpptest.NewServer()
+	defer srv.Close()
+	p := newTestSMPP(t, srv, "", nil)
+
+	srv.Drop()
+	deadline := time.Now().Add(time.Second)
+	for srv.Binds() < 2 || !p.Ready() {
+		if time.Now().After(deadline) {
+			t.Fatal("provider did not rebind")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := p.SendNormal(context.Background(), model.SMS{Phone: "+989121234567", Text: "hi"}); err != nil {
+		t.Fatalf("send after rebind: %v", err)
+	}
+}
+
+func TestSMPPLaterPartRetriedOnSameProvider(t *testing.T) {
+	srv := smpptest.NewServer()
+	defer srv.Close()
+	p := newTestSMPP(t, srv, "", nil)
+
+	srv.QueueSubmitStatus(smpp.StatusOK, smpp.StatusThrottled)
+	res, err := p.SendNormal(context.Background(), model.SMS{Phone: "+989121234567", Text: longText})
+	if err != nil {
+		t.Fatalf("second part not retried: %v", err)
+	}
+	subs := srv.Submits()
+	if len(subs) != 3 || !bytes.Equal(subs[1].ShortMessage, subs[2].ShortMessage) {
+		t.Fatalf("submits = %d, want part 1, part 2 throttled, part 2 again", len(subs))
+	}
+	if res.MessageID != subs[2].MessageID {
+		t.Fatalf("id = %s, want %s", res.MessageID, subs[2].MessageID)
+	}
+}
+
+func TestSMPPPartialSendIsFinal(t *testing.T) {
+	srv := smpptest.NewServer()
+	defer srv.Close()
+	p := newTestSMPP(t, srv, "", nil)
+
+	srv.QueueSubmitStatus(smpp.StatusOK)
+	srv.SetSubmitStatus(smpp.StatusInvDstAdr) // not retried: permanent
+	_, err := p.SendNormal(context.Background(), model.SMS{Phone: "+989121234567", Text: longText})
+	if ClassOf(err) != ErrClassPartial || !Final(err) {
+		t.Fatalf("class = %s (%v), want partial", ClassOf(err), err)
+	}
+
+	// the first part failing leaves the message free to fail over
+	srv.SetSubmitStatus(smpp.StatusThrottled)
+	_, err = p.SendNormal(context.Background(), model.SMS{Phone: "+989121234567", Text: longText})
+	if ClassOf(err) != ErrClassTransient || Final(err) {
+		t.Fatalf("class = %s (%v), want transient", ClassOf(err), err)
+	}
+}
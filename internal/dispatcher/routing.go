@@ -0,0 +1,203 @@
+package dispatcher
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+)
+
+// RoutingStrategy picks the provider for one send attempt among the ready candidates
+// and learns from the outcome of each attempt.
+type RoutingStrategy interface {
+	Name() string
+	// Pick chooses one of candidates (never empty, all Ready) for a message on lane.
+	Pick(candidates []Provider, lane model.SMSType) Provider
+	// Observe records how an attempt on provider went.
+	Observe(provider string, latency time.Duration, err error)
+}
+
+// ProviderAttrs are the per-provider inputs of the static strategies.
+type ProviderAttrs struct {
+	Weight      int   // weighted_round_robin; <= 0 counts as 1
+	Priority    int   // priority; lower goes first
+	CostNormal  int64 // least_cost, what the provider charges us per segment
+	CostExpress int64
+}
+
+// NewStrategy builds a strategy by config name: round_robin (default), weighted_round_robin,
+// least_latency, least_cost or priority.
+func NewStrategy(name string, attrs map[string]ProviderAttrs) (RoutingStrategy, error) {
+	switch strings.ToLower(name) {
+	case "", "round_robin":
+		return &roundRobin{}, nil
+	case "weighted_round_robin":
+		return &weightedRoundRobin{attrs: attrs, current: map[string]int{}}, nil
+	case "least_latency":
+		return &leastLatency{ewma: map[string]float64{}, seen: map[string]uint64{}}, nil
+	case "least_cost":
+		return &leastCost{attrs: attrs}, nil
+	case "priority":
+		return &priority{attrs: attrs}, nil
+	default:
+		return nil, fmt.Errorf("unknown routing strategy %q", name)
+	}
+}
+
+// roundRobin rotates over whoever is ready.
+type roundRobin struct {
+	counter atomic.Uint64
+}
+
+func (s *roundRobin) Name() string { return "round_robin" }
+
+func (s *roundRobin) Pick(candidates []Provider, _ model.SMSType) Provider {
+	x := s.counter.Add(1)
+	return candidates[int((x-1)%uint64(len(candidates)))]
+}
+
+func (s *roundRobin) Observe(string, time.Duration, error) {}
+
+// weightedRoundRobin is nginx-style smooth weighted round-robin: a provider with weight 3
+// gets 3 of every 4 picks against one with weight 1, interleaved rather than in bursts.
+type weightedRoundRobin struct {
+	attrs map[string]ProviderAttrs
+
+	mu      sync.Mutex
+	current map[string]int
+}
+
+func (s *weightedRoundRobin) Name() string { return "weighted_round_robin" }
+
+func (s *weightedRoundRobin) Pick(candidates []Provider, _ model.SMSType) Provider {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := 0
+	var best Provider
+	for _, p := range candidates {
+		w := max(s.attrs[p.Name()].Weight, 1)
+		total += w
+		s.current[p.Name()] += w
+		if best == nil || s.current[p.Name()] > s.current[best.Name()] {
+			best = p
+		}
+	}
+	s.current[best.Name()] -= total
+	return best
+}
+
+func (s *weightedRoundRobin) Observe(string, time.Duration, error) {}
+
+const (
+	latencyAlpha   = 0.2             // weight of the newest sample in the EWMA
+	latencyPenalty = 5 * time.Second // what a failed attempt counts as
+	latencyExplore = 20              // every latencyExplore-th pick refreshes the stalest estimate
+)
+
+// leastLatency picks the provider with the lowest EWMA response time. Providers without
+// samples yet score zero so each gets tried; failures count as latencyPenalty so a provider
+// that fails fast does not look fast. Every latencyExplore-th pick goes to the candidate
+// sampled longest ago instead, so a provider that had one slow spell gets a chance to recover.
+type leastLatency struct {
+	mu    sync.Mutex
+	ewma  map[string]float64 // seconds
+	seen  map[string]uint64  // provider → samples so far when it was last observed
+	n     uint64             // samples so far
+	picks uint64
+}
+
+func (s *leastLatency) Name() string { return "least_latency" }
+
+func (s *leastLatency) Pick(candidates []Provider, _ model.SMSType) Provider {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.picks++
+	if s.picks%latencyExplore == 0 && len(candidates) > 1 {
+		stalest := candidates[0]
+		for _, p := range candidates[1:] {
+			if s.seen[p.Name()] < s.seen[stalest.Name()] {
+				stalest = p
+			}
+		}
+		return stalest
+	}
+
+	best, bestScore := candidates[0], math.Inf(1)
+	for _, p := range candidates {
+		if v := s.ewma[p.Name()]; v < bestScore {
+			best, bestScore = p, v
+		}
+	}
+	return best
+}
+
+func (s *leastLatency) Observe(provider string, latency time.Duration, err error) {
+	if err != nil && ClassOf(err) != ErrClassRejected {
+		latency = max(latency, latencyPenalty)
+	}
+	sample := latency.Seconds()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.n++
+	s.seen[provider] = s.n
+	if v, ok := s.ewma[provider]; ok {
+		s.ewma[provider] = latencyAlpha*sample + (1-latencyAlpha)*v
+	} else {
+		s.ewma[provider] = sample
+	}
+}
+
+// leastCost picks the cheapest provider for the lane, rotating among equally cheap ones.
+type leastCost struct {
+	attrs   map[string]ProviderAttrs
+	counter atomic.Uint64
+}
+
+func (s *leastCost) Name() string { return "least_cost" }
+
+func (s *leastCost) Pick(candidates []Provider, lane model.SMSType) Provider {
+	var cheapest []Provider
+	var lowest int64 = math.MaxInt64
+	for _, p := range candidates {
+		c := s.attrs[p.Name()].CostNormal
+		if lane == model.SMSTypeExpress {
+			c = s.attrs[p.Name()].CostExpress
+		}
+		switch {
+		case c < lowest:
+			lowest, cheapest = c, []Provider{p}
+		case c == lowest:
+			cheapest = append(cheapest, p)
+		}
+	}
+	x := s.counter.Add(1)
+	return cheapest[int((x-1)%uint64(len(cheapest)))]
+}
+
+func (s *leastCost) Observe(string, time.Duration, error) {}
+
+// priority is failover order: the ready provider with the lowest priority wins, ties in
+// config order. Lower ones only see traffic while better ones are tripped or already tried.
+type priority struct {
+	attrs map[string]ProviderAttrs
+}
+
+func (s *priority) Name() string { return "priority" }
+
+func (s *priority) Pick(candidates []Provider, _ model.SMSType) Provider {
+	sorted := append([]Provider(nil), candidates...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return s.attrs[sorted[i].Name()].Priority < s.attrs[sorted[j].Name()].Priority
+	})
+	return sorted[0]
+}
+
+func (s *priority) Observe(string, time.Duration, error) {}
@@ -0,0 +1,47 @@
+package dispatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+)
+
+// named is a Provider that only has a name, for strategy tests.
+type named string
+
+func (n named) Name() string { return string(n) }
+func (named) Ready() bool    { return true }
+func (named) Acquire(ctx context.Context) (context.Context, bool) {
+	return ctx, true
+}
+func (named) Release(context.Context)                                    {}
+func (named) SendNormal(context.Context, model.SMS) (SendResult, error)  { return SendResult{}, nil }
+func (named) SendExpress(context.Context, model.SMS) (SendResult, error) { return SendResult{}, nil }
+
+func TestLeastLatencyRecoversFromSlowSpell(t *testing.T) {
+	s, _ := NewStrategy("least_latency", nil)
+	a, b := named("a"), named("b")
+	candidates := []Provider{a, b}
+
+	s.Observe("a", 100*time.Millisecond, nil)
+	s.Observe("b", 3*time.Second, nil) // one slow spell
+
+	picks := map[string]int{}
+	for range 2 * latencyExplore {
+		p := s.Pick(candidates, model.SMSTypeNormal)
+		picks[p.Name()]++
+		latency := 100 * time.Millisecond
+		if p == b {
+			latency = 50 * time.Millisecond // b is fast again
+		}
+		s.Observe(p.Name(), latency, nil)
+	}
+	if picks["b"] == 0 {
+		t.Fatal("b never tried again after its slow spell")
+	}
+	if picks["a"] == 0 {
+		t.Fatal("a never picked")
+	}
+}
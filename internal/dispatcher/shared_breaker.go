@@ -0,0 +1,291 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// failScript counts a failure cluster-wide and opens the provider once threshold failures
+// in a row are in (or when the caller's local breaker already tripped).
+// KEYS: state hash. ARGV: now ms, threshold, open for ms, force open (0|1).
+var failScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local fails = redis.call('HINCRBY', KEYS[1], 'fails', 1)
+local openUntil = tonumber(redis.call('HGET', KEYS[1], 'open_until') or '0')
+if openUntil <= now and (fails >= tonumber(ARGV[2]) or ARGV[4] == '1') then
+  openUntil = now + tonumber(ARGV[3])
+  redis.call('HSET', KEYS[1], 'open_until', openUntil, 'fails', 0)
+end
+redis.call('PEXPIRE', KEYS[1], 3600000)
+return openUntil
+`)
+
+const sharedSnapshotTTL = 250 * time.Millisecond
+
+type sharedSnapshot struct {
+	openUntil time.Time
+	fails     int64
+	probing   bool // some replica holds the probe
+	at        time.Time
+}
+
+// SharedBreaker puts a provider's breaker state in Redis so every sender replica sees it:
+// a consecutive failure count, an open-until time, and a probe lock that lets exactly one
+// replica probe once the open period ends. Only the call admitted as the probe settles
+// the cluster state. The local breaker still runs underneath; its trips are published,
+// and it alone decides while Redis is unreachable.
+type SharedBreaker struct {
+	local     Breaker
+	rdb       *redis.Client
+	name      string
+	key       string
+	probeKey  string
+	threshold int
+	openFor   time.Duration
+
+	mu       sync.Mutex
+	snap     sharedSnapshot
+	gen      uint64 // bumped on every write, so a refresh racing one is dropped
+	redisBad bool
+
+	refreshing atomic.Bool // a snapshot refresh is in flight
+
+	probeSeq atomic.Uint64 // ids handed to this replica's probe calls
+	probe    atomic.Uint64 // id of the probe call holding the cluster lock; 0 if none
+}
+
+// NewSharedBreaker wraps local; threshold is the cluster-wide consecutive failure limit.
+func NewSharedBreaker(local Breaker, rdb *redis.Client, name string, threshold int, openFor time.Duration) *SharedBreaker {
+	if threshold <= 0 {
+		threshold = 3
+	}
+	if openFor <= 0 {
+		openFor = 15 * time.Second
+	}
+	tag := "{" + name + "}"
+	return &SharedBreaker{
+		local:     local,
+		rdb:       rdb,
+		name:      name,
+		key:       "br:" + tag,
+		probeKey:  "br:" + tag + ":probe",
+		threshold: threshold,
+		openFor:   openFor,
+	}
+}
+
+var _ Breaker = (*SharedBreaker)(nil)
+
+func (b *SharedBreaker) Ready() bool {
+	s := b.snapshot()
+	if !s.openUntil.IsZero() {
+		if time.Now().Before(s.openUntil) || s.probing {
+			return false
+		}
+	}
+	return b.local.Ready()
+}
+
+func (b *SharedBreaker) Admit() (Permit, bool) {
+	s := b.snapshot()
+	if s.openUntil.IsZero() { // closed cluster-wide
+		return b.local.Admit()
+	}
+	if time.Now().Before(s.openUntil) {
+		return Permit{}, false
+	}
+
+	// half-open: one probe for the whole cluster
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	won, err := b.rdb.SetNX(ctx, b.probeKey, "1", b.openFor).Result()
+	if err != nil {
+		b.redisErr(err)
+		return b.local.Admit()
+	}
+	if !won {
+		b.markProbing()
+		return Permit{}, false
+	}
+	p, ok := b.local.Admit()
+	if !ok {
+		_ = b.rdb.Del(ctx, b.probeKey).Err()
+		return Permit{}, false
+	}
+	p.shared = b.probeSeq.Add(1)
+	b.probe.Store(p.shared)
+	return p, true
+}
+
+func (b *SharedBreaker) Release(p Permit) {
+	if p.shared != 0 && b.probe.CompareAndSwap(p.shared, 0) {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		if err := b.rdb.Del(ctx, b.probeKey).Err(); err != nil {
+			b.redisErr(err) // the lock expires after openFor
+		}
+	}
+	b.local.Release(p)
+}
+
+func (b *SharedBreaker) Record(p Permit, latency time.Duration, failed bool) {
+	b.local.Record(p, latency, failed)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// Only the probe call itself settles the cluster; a call admitted before the breaker
+	// opened that finishes now is counted like any other below.
+	if p.shared != 0 && b.probe.CompareAndSwap(p.shared, 0) {
+		openUntil := int64(0)
+		if failed {
+			openUntil = time.Now().Add(b.openFor).UnixMilli()
+		}
+		pipe := b.rdb.TxPipeline()
+		pipe.HSet(ctx, b.key, "open_until", openUntil, "fails", 0)
+		pipe.Del(ctx, b.probeKey)
+		if _, err := pipe.Exec(ctx); err != nil {
+			b.redisErr(err)
+			return
+		}
+		if failed {
+			log.Printf("[breaker] %s: probe failed, open cluster-wide for %s", b.name, b.openFor)
+		} else {
+			log.Printf("[breaker] %s: probe succeeded, closed cluster-wide", b.name)
+		}
+		b.wrote(openUntil)
+		return
+	}
+
+	if !failed {
+		if b.snapshot().fails > 0 { // only write when there is a streak to break
+			if err := b.rdb.HSet(ctx, b.key, "fails", 0).Err(); err != nil {
+				b.redisErr(err)
+			}
+			b.invalidate()
+		}
+		return
+	}
+
+	force := "0"
+	if !b.local.Ready() {
+		force = "1"
+	}
+	now := time.Now()
+	openUntil, err := failScript.Run(ctx, b.rdb, []string{b.key},
+		now.UnixMilli(), b.threshold, b.openFor.Milliseconds(), force).Int64()
+	if err != nil {
+		b.redisErr(err)
+		return
+	}
+	if openUntil > now.UnixMilli() && b.snapshot().openUntil.IsZero() {
+		log.Printf("[breaker] %s: open cluster-wide for %s", b.name, b.openFor)
+	}
+	b.wrote(openUntil)
+}
+
+// snapshot returns the cached cluster state. Once it is older than sharedSnapshotTTL a
+// background refresh starts and callers get the stale value until it lands, so a slow
+// Redis never holds up provider selection.
+func (b *SharedBreaker) snapshot() sharedSnapshot {
+	b.mu.Lock()
+	s := b.snap
+	b.mu.Unlock()
+	if time.Since(s.at) >= sharedSnapshotTTL && b.refreshing.CompareAndSwap(false, true) {
+		go b.refresh()
+	}
+	return s
+}
+
+// refresh reads the cluster state from Redis without holding b.mu.
+func (b *SharedBreaker) refresh() {
+	defer b.refreshing.Store(false)
+
+	b.mu.Lock()
+	gen := b.gen
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	pipe := b.rdb.Pipeline()
+	fields := pipe.HMGet(ctx, b.key, "open_until", "fails")
+	probe := pipe.Exists(ctx, b.probeKey)
+	_, err := pipe.Exec(ctx)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		b.redisErrLocked(err)
+		b.snap = sharedSnapshot{at: time.Now()} // fall back to the local breaker alone
+		return
+	}
+	if b.redisBad {
+		b.redisBad = false
+		log.Printf("[breaker] %s: redis back, using shared state", b.name)
+	}
+
+	if gen != b.gen { // this replica wrote while we read: the read may predate it, read again
+		return
+	}
+	s := sharedSnapshot{at: time.Now(), probing: probe.Val() > 0}
+	vals := fields.Val()
+	if ms := parseInt(vals[0]); ms > 0 {
+		s.openUntil = time.UnixMilli(ms)
+	}
+	s.fails = parseInt(vals[1])
+	b.snap = s
+}
+
+func (b *SharedBreaker) invalidate() {
+	b.mu.Lock()
+	b.snap.at = time.Time{}
+	b.gen++
+	b.mu.Unlock()
+}
+
+// wrote applies the open-until time this replica just wrote (or read back from failScript)
+// to its snapshot, so it doesn't act on the old state while the refresh is in flight.
+func (b *SharedBreaker) wrote(openUntilMs int64) {
+	b.mu.Lock()
+	b.snap.openUntil = time.Time{}
+	if openUntilMs > 0 {
+		b.snap.openUntil = time.UnixMilli(openUntilMs)
+	}
+	b.snap.probing = false
+	b.snap.at = time.Time{}
+	b.gen++
+	b.mu.Unlock()
+}
+
+func (b *SharedBreaker) markProbing() {
+	b.mu.Lock()
+	b.snap.probing = true
+	b.mu.Unlock()
+}
+
+func (b *SharedBreaker) redisErr(err error) {
+	b.mu.Lock()
+	b.redisErrLocked(err)
+	b.mu.Unlock()
+}
+
+// redisErrLocked logs the first error of an outage only.
+func (b *SharedBreaker) redisErrLocked(err error) {
+	if !b.redisBad {
+		b.redisBad = true
+		log.Printf("[breaker] %s: redis err, local breaker only: %v", b.name, err)
+	}
+}
+
+func parseInt(v any) int64 {
+	s, _ := v.(string)
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
@@ -0,0 +1,154 @@
+package dispatcher
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// A Redis that accepts connections but never answers must not slow down provider selection:
+// the snapshot refresh runs in the background and callers get the cached state.
+func TestSharedBreakerDoesNotWaitOnRedis(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close() // hold the connection open, say nothing
+		}
+	}()
+
+	rdb := redis.NewClient(&redis.Options{Addr: ln.Addr().String(), MaxRetries: -1})
+	defer rdb.Close()
+	b := NewSharedBreaker(NewMicroBreaker(3, time.Second), rdb, "slow", 3, time.Second)
+
+	start := time.Now()
+	for range 100 {
+		if !b.Ready() {
+			t.Fatal("closed breaker not ready")
+		}
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Fatalf("100 Ready calls took %s with Redis stalled", d)
+	}
+
+	// once the refresh times out, the local breaker decides alone
+	time.Sleep(300 * time.Millisecond)
+	if _, ok := b.Admit(); !ok {
+		t.Fatal("call refused with Redis unreachable")
+	}
+}
+
+// replicas returns two SharedBreakers for one provider on one Redis, as two sender replicas
+// would have; their local breakers never trip on their own.
+func replicas(t *testing.T, openFor time.Duration) (a, b *SharedBreaker) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	newReplica := func() *SharedBreaker {
+		rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { _ = rdb.Close() })
+		return NewSharedBreaker(NewMicroBreaker(100, openFor), rdb, "p", 3, openFor)
+	}
+	return newReplica(), newReplica()
+}
+
+// eventually polls cond: the cluster state reaches a replica through its snapshot refresh.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting until %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func fail(t *testing.T, b Breaker) {
+	t.Helper()
+	p, ok := b.Admit()
+	if !ok {
+		t.Fatal("call refused")
+	}
+	b.Record(p, fast, true)
+}
+
+func TestSharedBreakerOpensForEveryReplica(t *testing.T) {
+	a, b := replicas(t, time.Minute)
+	eventually(t, "b has read the closed state", func() bool { return b.Ready() && !b.snapshot().at.IsZero() })
+
+	fail(t, a)
+	fail(t, a)
+	if !b.Ready() {
+		t.Fatal("opened below the threshold")
+	}
+	fail(t, a)
+
+	eventually(t, "b sees the provider open", func() bool { return !b.Ready() })
+	if _, ok := b.Admit(); ok {
+		t.Fatal("b admitted a call while open cluster-wide")
+	}
+	if a.Ready() {
+		t.Fatal("a still ready")
+	}
+}
+
+func TestSharedBreakerFailureStreakIsClusterWide(t *testing.T) {
+	a, b := replicas(t, time.Minute)
+	fail(t, a)
+	fail(t, b)
+	fail(t, a) // 3 in a row across two replicas
+	eventually(t, "both see the provider open", func() bool { return !a.Ready() && !b.Ready() })
+}
+
+func TestSharedBreakerSingleProbe(t *testing.T) {
+	for _, probeFails := range []bool{false, true} {
+		name := "probe succeeds"
+		if probeFails {
+			name = "probe fails"
+		}
+		t.Run(name, func(t *testing.T) {
+			const openFor = 300 * time.Millisecond
+			a, b := replicas(t, openFor)
+			fail(t, a)
+			fail(t, a)
+			fail(t, a)
+			eventually(t, "b sees the provider open", func() bool { return !b.Ready() })
+
+			time.Sleep(openFor)
+			eventually(t, "a may probe", a.Ready)
+			probe, ok := a.Admit()
+			if !ok {
+				t.Fatal("a refused the probe")
+			}
+			if _, ok := b.Admit(); ok {
+				t.Fatal("b probed while a holds the probe")
+			}
+			if _, ok := a.Admit(); ok {
+				t.Fatal("a admitted a second call while probing")
+			}
+
+			a.Record(probe, fast, probeFails)
+			if probeFails {
+				eventually(t, "b sees the probe settled", func() bool { return !b.snapshot().probing })
+				if b.Ready() || a.Ready() {
+					t.Fatal("ready after a failed probe")
+				}
+				return
+			}
+			eventually(t, "b sees the provider closed", b.Ready)
+			if _, ok := b.Admit(); !ok {
+				t.Fatal("b refused a call after the probe closed the breaker")
+			}
+		})
+	}
+}
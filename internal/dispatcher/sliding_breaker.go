@@ -0,0 +1,238 @@
+package dispatcher
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/metrics"
+)
+
+// SlidingOptions configures a SlidingBreaker.
+type SlidingOptions struct {
+	Name           string        // provider, for metrics
+	WindowSize     int           // count window: the last N calls (used when WindowTime is 0)
+	WindowTime     time.Duration // time window: calls in the last d, in 1s buckets
+	MinCalls       int           // no verdict below this many calls in the window
+	FailureRate    float64       // open at or above this share of failures (0..1, 0 = off)
+	SlowRate       float64       // open at or above this share of slow calls (0..1, 0 = off)
+	SlowCall       time.Duration // a call slower than this counts as slow
+	OpenFor        time.Duration // how long to stay open before probing
+	HalfOpenProbes int           // calls admitted in half-open; all must report before a verdict
+	Clock          Clock         // nil = wall clock
+}
+
+type outcome struct{ failed, slow bool }
+
+type bucket struct {
+	sec                 int64
+	calls, fails, slows int
+}
+
+// SlidingBreaker trips on the failure or slow-call rate over a rolling count or time window
+// once MinCalls are in. After OpenFor it admits HalfOpenProbes calls and closes only if
+// their rates are under the thresholds; calls admitted before it opened do not count as probes.
+type SlidingBreaker struct {
+	opts SlidingOptions
+
+	mu        sync.Mutex
+	st        state
+	openUntil time.Time
+
+	ring  []outcome // count window
+	next  int
+	count int
+
+	buckets []bucket // time window
+
+	round        uint64 // half-open rounds so far; probes carry theirs in the Permit
+	probesIssued int
+	probes       []outcome
+}
+
+func NewSlidingBreaker(opts SlidingOptions) *SlidingBreaker {
+	if opts.WindowSize <= 0 && opts.WindowTime <= 0 {
+		opts.WindowSize = 20
+	}
+	if opts.MinCalls <= 0 {
+		opts.MinCalls = 10
+	}
+	if opts.FailureRate <= 0 && opts.SlowRate <= 0 {
+		opts.FailureRate = 0.5
+	}
+	if opts.SlowCall <= 0 {
+		opts.SlowCall = 2 * time.Second
+	}
+	if opts.OpenFor <= 0 {
+		opts.OpenFor = 15 * time.Second
+	}
+	if opts.HalfOpenProbes <= 0 {
+		opts.HalfOpenProbes = 3
+	}
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
+	}
+
+	b := &SlidingBreaker{opts: opts}
+	if opts.WindowTime > 0 {
+		b.buckets = make([]bucket, max(int(opts.WindowTime/time.Second), 1))
+	} else {
+		b.ring = make([]outcome, opts.WindowSize)
+	}
+	metrics.BreakerState.WithLabelValues(opts.Name).Set(float64(closed))
+	return b
+}
+
+var _ Breaker = (*SlidingBreaker)(nil)
+
+func (b *SlidingBreaker) Ready() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.st {
+	case open:
+		return !b.opts.Clock.Now().Before(b.openUntil)
+	case halfOpen:
+		return b.probesIssued < b.opts.HalfOpenProbes
+	default:
+		return true
+	}
+}
+
+func (b *SlidingBreaker) Admit() (Permit, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.st {
+	case open:
+		if b.opts.Clock.Now().Before(b.openUntil) {
+			return Permit{}, false
+		}
+		b.transition(halfOpen)
+		b.round++
+		b.probesIssued = 1
+		return Permit{probe: b.round}, true
+	case halfOpen:
+		if b.probesIssued < b.opts.HalfOpenProbes {
+			b.probesIssued++
+			return Permit{probe: b.round}, true
+		}
+		return Permit{}, false
+	default:
+		return Permit{}, true
+	}
+}
+
+func (b *SlidingBreaker) Record(p Permit, latency time.Duration, failed bool) {
+	o := outcome{failed: failed, slow: latency >= b.opts.SlowCall}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.st {
+	case closed:
+		calls, fails, slows := b.add(o)
+		if calls >= b.opts.MinCalls && b.tripped(calls, fails, slows) {
+			b.trip()
+		}
+	case halfOpen:
+		if p.probe != b.round {
+			return // admitted before the breaker opened
+		}
+		b.probes = append(b.probes, o)
+		if len(b.probes) < b.opts.HalfOpenProbes {
+			return
+		}
+		fails, slows := 0, 0
+		for _, x := range b.probes {
+			if x.failed {
+				fails++
+			}
+			if x.slow {
+				slows++
+			}
+		}
+		if b.tripped(len(b.probes), fails, slows) {
+			b.trip()
+		} else {
+			b.reset()
+			b.transition(closed)
+		}
+	default:
+		// results of calls admitted before the breaker opened
+	}
+}
+
+func (b *SlidingBreaker) Release(p Permit) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.st == halfOpen && p.probe == b.round && b.probesIssued > len(b.probes) {
+		b.probesIssued--
+	}
+}
+
+func (b *SlidingBreaker) tripped(calls, fails, slows int) bool {
+	n := float64(calls)
+	return (b.opts.FailureRate > 0 && float64(fails)/n >= b.opts.FailureRate) ||
+		(b.opts.SlowRate > 0 && float64(slows)/n >= b.opts.SlowRate)
+}
+
+func (b *SlidingBreaker) trip() {
+	b.reset()
+	b.openUntil = b.opts.Clock.Now().Add(b.opts.OpenFor)
+	b.transition(open)
+}
+
+// add records o in the window and returns the window's totals.
+func (b *SlidingBreaker) add(o outcome) (calls, fails, slows int) {
+	if b.buckets == nil {
+		b.ring[b.next] = o
+		b.next = (b.next + 1) % len(b.ring)
+		b.count = min(b.count+1, len(b.ring))
+		for _, x := range b.ring[:b.count] {
+			calls++
+			if x.failed {
+				fails++
+			}
+			if x.slow {
+				slows++
+			}
+		}
+		return calls, fails, slows
+	}
+
+	sec := b.opts.Clock.Now().Unix()
+	cur := &b.buckets[int(sec%int64(len(b.buckets)))]
+	if cur.sec != sec {
+		*cur = bucket{sec: sec}
+	}
+	cur.calls++
+	if o.failed {
+		cur.fails++
+	}
+	if o.slow {
+		cur.slows++
+	}
+	for _, x := range b.buckets {
+		if sec-x.sec < int64(len(b.buckets)) {
+			calls += x.calls
+			fails += x.fails
+			slows += x.slows
+		}
+	}
+	return calls, fails, slows
+}
+
+func (b *SlidingBreaker) reset() {
+	for i := range b.ring {
+		b.ring[i] = outcome{}
+	}
+	b.next, b.count = 0, 0
+	for i := range b.buckets {
+		b.buckets[i] = bucket{}
+	}
+	b.probesIssued = 0
+	b.probes = b.probes[:0]
+}
+
+func (b *SlidingBreaker) transition(to state) {
+	b.st = to
+	metrics.BreakerState.WithLabelValues(b.opts.Name).Set(float64(to))
+	metrics.BreakerTransitionsTotal.WithLabelValues(b.opts.Name, to.String()).Inc()
+}
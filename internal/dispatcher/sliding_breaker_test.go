@@ -0,0 +1,224 @@
+package dispatcher
+
+import (
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2025, 10, 18, 12, 0, 0, 0, time.UTC)
+
+const (
+	fast = 10 * time.Millisecond
+	slow = 3 * time.Second
+)
+
+// call admits and records one call; it fails the test if the breaker refuses it.
+func call(t *testing.T, b Breaker, latency time.Duration, failed bool) {
+	t.Helper()
+	p, ok := b.Admit()
+	if !ok {
+		t.Fatal("call refused")
+	}
+	b.Record(p, latency, failed)
+}
+
+func assertOpen(t *testing.T, b Breaker, want bool) {
+	t.Helper()
+	if b.Ready() == want {
+		t.Fatalf("open = %t, want %t", !want, want)
+	}
+}
+
+func TestSlidingNoVerdictBelowMinCalls(t *testing.T) {
+	b := NewSlidingBreaker(SlidingOptions{WindowSize: 10, MinCalls: 5, FailureRate: 0.5, Clock: NewFakeClock(t0)})
+	for range 4 {
+		call(t, b, fast, true)
+	}
+	assertOpen(t, b, false)
+
+	call(t, b, fast, true)
+	assertOpen(t, b, true)
+}
+
+func TestSlidingCountWindow(t *testing.T) {
+	t.Run("failure rate", func(t *testing.T) {
+		b := NewSlidingBreaker(SlidingOptions{WindowSize: 4, MinCalls: 4, FailureRate: 0.5, Clock: NewFakeClock(t0)})
+		call(t, b, fast, false)
+		call(t, b, fast, false)
+		call(t, b, fast, false)
+		call(t, b, fast, true) // 1/4
+		assertOpen(t, b, false)
+
+		call(t, b, fast, true) // the first success slid out: 2/4
+		assertOpen(t, b, true)
+	})
+
+	t.Run("slow rate", func(t *testing.T) {
+		b := NewSlidingBreaker(SlidingOptions{WindowSize: 4, MinCalls: 4, SlowRate: 0.5, SlowCall: time.Second, Clock: NewFakeClock(t0)})
+		call(t, b, fast, true) // failures do not count: FailureRate is off
+		call(t, b, fast, false)
+		call(t, b, slow, false)
+		call(t, b, fast, false) // 1/4 slow
+		assertOpen(t, b, false)
+
+		call(t, b, slow, false) // 2/4 slow
+		assertOpen(t, b, true)
+	})
+}
+
+func TestSlidingTimeWindow(t *testing.T) {
+	t.Run("failure rate across buckets", func(t *testing.T) {
+		clock := NewFakeClock(t0)
+		b := NewSlidingBreaker(SlidingOptions{WindowTime: 10 * time.Second, MinCalls: 4, FailureRate: 0.75, Clock: clock})
+		call(t, b, fast, true)
+		call(t, b, fast, true)
+		clock.Advance(5 * time.Second)
+		call(t, b, fast, false)
+		assertOpen(t, b, false)
+
+		call(t, b, fast, true) // 3/4 over two buckets
+		assertOpen(t, b, true)
+	})
+
+	t.Run("slow rate", func(t *testing.T) {
+		clock := NewFakeClock(t0)
+		b := NewSlidingBreaker(SlidingOptions{WindowTime: 10 * time.Second, MinCalls: 2, SlowRate: 1, SlowCall: time.Second, Clock: clock})
+		call(t, b, slow, false)
+		clock.Advance(time.Second)
+		call(t, b, slow, false)
+		assertOpen(t, b, true)
+	})
+
+	t.Run("expired buckets drop out", func(t *testing.T) {
+		clock := NewFakeClock(t0)
+		b := NewSlidingBreaker(SlidingOptions{WindowTime: 10 * time.Second, MinCalls: 4, FailureRate: 0.5, Clock: clock})
+		call(t, b, fast, true)
+		call(t, b, fast, true)
+		clock.Advance(11 * time.Second) // both failures are now outside the window
+
+		call(t, b, fast, false)
+		call(t, b, fast, false)
+		call(t, b, fast, false)
+		assertOpen(t, b, false) // 3 calls, below MinCalls
+
+		call(t, b, fast, true) // 1/4; with the expired failures it would be 3/6
+		assertOpen(t, b, false)
+	})
+}
+
+func TestSlidingHalfOpenProbes(t *testing.T) {
+	// newTripped returns a breaker that just went half-open and the permits of its 3 probes.
+	newTripped := func(t *testing.T) (*SlidingBreaker, *FakeClock, []Permit) {
+		clock := NewFakeClock(t0)
+		b := NewSlidingBreaker(SlidingOptions{
+			WindowSize: 2, MinCalls: 2, FailureRate: 0.5,
+			OpenFor: 10 * time.Second, HalfOpenProbes: 3, Clock: clock,
+		})
+		call(t, b, fast, true)
+		call(t, b, fast, true)
+		assertOpen(t, b, true)
+		if _, ok := b.Admit(); ok {
+			t.Fatal("admitted while open")
+		}
+		clock.Advance(10 * time.Second)
+		assertOpen(t, b, false)
+		probes := make([]Permit, 3)
+		for i := range probes {
+			p, ok := b.Admit()
+			if !ok {
+				t.Fatalf("probe %d refused", i+1)
+			}
+			probes[i] = p
+		}
+		if _, ok := b.Admit(); ok || b.Ready() {
+			t.Fatal("admitted more than 3 probes")
+		}
+		return b, clock, probes
+	}
+
+	t.Run("closes once all probes succeed", func(t *testing.T) {
+		b, _, probes := newTripped(t)
+		b.Record(probes[0], fast, false)
+		b.Record(probes[1], fast, false)
+		if _, ok := b.Admit(); ok {
+			t.Fatal("verdict before every probe reported")
+		}
+		b.Record(probes[2], fast, false)
+		assertOpen(t, b, false)
+		call(t, b, fast, false)
+	})
+
+	t.Run("re-opens when probes fail", func(t *testing.T) {
+		b, clock, probes := newTripped(t)
+		b.Record(probes[0], fast, true)
+		b.Record(probes[1], fast, false)
+		if _, ok := b.Admit(); ok {
+			t.Fatal("verdict before every probe reported")
+		}
+		b.Record(probes[2], fast, true) // 2/3 failed
+		assertOpen(t, b, true)
+
+		clock.Advance(9 * time.Second)
+		assertOpen(t, b, true)
+		clock.Advance(time.Second)
+		assertOpen(t, b, false)
+	})
+
+	t.Run("closes when failures stay under the rate", func(t *testing.T) {
+		b, _, probes := newTripped(t)
+		b.Record(probes[0], fast, true)
+		b.Record(probes[1], fast, false)
+		b.Record(probes[2], fast, false) // 1/3 < 0.5
+		assertOpen(t, b, false)
+	})
+}
+
+// A call admitted while closed that finishes during half-open is not one of the probes.
+func TestSlidingLateCallIsNotAProbe(t *testing.T) {
+	clock := NewFakeClock(t0)
+	b := NewSlidingBreaker(SlidingOptions{
+		WindowSize: 2, MinCalls: 2, FailureRate: 0.5,
+		OpenFor: 10 * time.Second, HalfOpenProbes: 1, Clock: clock,
+	})
+	late, ok := b.Admit()
+	if !ok {
+		t.Fatal("call refused")
+	}
+	call(t, b, fast, true)
+	call(t, b, fast, true)
+	assertOpen(t, b, true)
+
+	clock.Advance(10 * time.Second)
+	probe, ok := b.Admit()
+	if !ok {
+		t.Fatal("probe refused")
+	}
+	b.Record(late, fast, false) // must not close the breaker
+	if _, ok := b.Admit(); ok {
+		t.Fatal("late call settled the half-open breaker")
+	}
+	b.Record(probe, fast, false)
+	assertOpen(t, b, false)
+}
+
+// A probe admitted but never sent (the provider was throttled) frees its slot.
+func TestSlidingReleasedProbeIsReissued(t *testing.T) {
+	clock := NewFakeClock(t0)
+	b := NewSlidingBreaker(SlidingOptions{
+		WindowSize: 2, MinCalls: 2, FailureRate: 0.5,
+		OpenFor: 10 * time.Second, HalfOpenProbes: 1, Clock: clock,
+	})
+	call(t, b, fast, true)
+	call(t, b, fast, true)
+	clock.Advance(10 * time.Second)
+
+	p, ok := b.Admit()
+	if !ok {
+		t.Fatal("probe refused")
+	}
+	b.Release(p)
+	assertOpen(t, b, false)
+	call(t, b, fast, false) // the re-issued probe closes the breaker
+	assertOpen(t, b, false)
+	call(t, b, fast, false)
+}
@@ -0,0 +1,124 @@
+package dlr
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/metrics"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+// DefaultGrace is how long an unmatched receipt is redelivered by default. The sender stores
+// the provider message id when it flushes, within a second of the provider accepting it.
+const DefaultGrace = 2 * time.Minute
+
+// Applier moves messages to their final state from provider receipts, whether they came
+// in over an HTTP callback or an SMPP session.
+type Applier struct {
+	DB       *sqlx.DB
+	Messages repository.MessagesRepository
+	Webhooks repository.WebhooksRepository
+	Grace    time.Duration // how long an unmatched receipt stays Pending; then it is Unmatched
+
+	mu     sync.Mutex
+	seen   map[string]time.Time // provider/id → when it first matched nothing
+	pruned time.Time
+}
+
+func NewApplier(db *sqlx.DB, msgs repository.MessagesRepository, webhooks repository.WebhooksRepository) *Applier {
+	return &Applier{DB: db, Messages: msgs, Webhooks: webhooks, Grace: DefaultGrace, seen: map[string]time.Time{}}
+}
+
+// Outcome counts what Apply did with a batch of receipts.
+type Outcome struct {
+	Applied   int // moved a sent message to its final state
+	Ignored   int // matched a message no longer sent: a repeat, or a receipt after the final state
+	Pending   int // matched nothing yet; the sender may still be storing the id
+	Unmatched int // matched nothing for longer than Grace; acknowledged and dropped
+}
+
+// Redeliver reports whether the provider should send the batch again: only while a receipt
+// may still match a message in flight. Applied and ignored receipts are skipped on the retry.
+func (o Outcome) Redeliver() bool { return o.Pending > 0 }
+
+// Apply matches receipts to messages via (provider, provider_message_id) and applies them.
+// A receipt that matches nothing is Pending for Grace after it was first seen by this
+// Applier, then Unmatched, so an id that will never match cannot hold up its batch forever.
+func (a *Applier) Apply(ctx context.Context, provider string, receipts []Receipt) (Outcome, error) {
+	var o Outcome
+	for _, r := range receipts {
+		m, err := a.Messages.GetByProviderMessageID(ctx, provider, r.ProviderMessageID)
+		if err != nil {
+			return o, err
+		}
+		if m == nil {
+			if a.pending(provider+"/"+r.ProviderMessageID, time.Now()) {
+				o.Pending++
+				metrics.ReceiptsTotal.WithLabelValues(provider, "pending").Inc()
+			} else {
+				o.Unmatched++
+				metrics.ReceiptsTotal.WithLabelValues(provider, "unmatched").Inc()
+			}
+			continue
+		}
+
+		ok, err := a.applyOne(ctx, m.ID, r)
+		if err != nil {
+			return o, err
+		}
+		if ok {
+			o.Applied++
+			metrics.ReceiptsTotal.WithLabelValues(provider, "applied").Inc()
+			metrics.MessagesTotal.WithLabelValues(r.Status.String(), m.Type.String()).Inc()
+		} else {
+			o.Ignored++
+			metrics.ReceiptsTotal.WithLabelValues(provider, "ignored").Inc()
+		}
+	}
+	return o, nil
+}
+
+// pending records key as unmatched and reports whether it was first seen less than Grace ago.
+func (a *Applier) pending(key string, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.seen == nil {
+		a.seen = map[string]time.Time{}
+	}
+	if now.Sub(a.pruned) > a.Grace {
+		// a provider still redelivering a forgotten key gets one more Grace, not an unbounded map
+		for k, t := range a.seen {
+			if now.Sub(t) > 2*a.Grace {
+				delete(a.seen, k)
+			}
+		}
+		a.pruned = now
+	}
+	first, ok := a.seen[key]
+	if !ok {
+		first = now
+		a.seen[key] = now
+	}
+	return now.Sub(first) < a.Grace
+}
+
+// applyOne updates the message and queues its status webhook in one transaction.
+func (a *Applier) applyOne(ctx context.Context, id string, r Receipt) (bool, error) {
+	tx, err := a.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	ok, err := a.Messages.ApplyDLR(ctx, tx, id, r.Status, r.At)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := a.Webhooks.EnqueueForMessages(ctx, tx, []string{id}); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
@@ -0,0 +1,30 @@
+package dlr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUnmatchedReceiptIsPendingForGrace(t *testing.T) {
+	a := &Applier{Grace: time.Minute}
+	t0 := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	if !a.pending("kavenegar/42", t0) {
+		t.Fatal("first sighting not pending")
+	}
+	if !a.pending("kavenegar/42", t0.Add(59*time.Second)) {
+		t.Fatal("redelivery within grace not pending")
+	}
+	if a.pending("kavenegar/42", t0.Add(time.Minute)) {
+		t.Fatal("still pending after grace")
+	}
+	if !a.pending("kavenegar/43", t0.Add(time.Minute)) {
+		t.Fatal("another id shares the first one's clock")
+	}
+
+	// long-gone ids are forgotten
+	a.pending("kavenegar/44", t0.Add(5*time.Minute))
+	if _, ok := a.seen["kavenegar/42"]; ok {
+		t.Fatal("stale id not pruned")
+	}
+}
@@ -0,0 +1,175 @@
+package dlr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+)
+
+var (
+	ErrEmptyReceipt  = errors.New("empty delivery receipt")
+	ErrUnknownStatus = errors.New("unknown delivery status")
+)
+
+// Receipt is a provider delivery report mapped to our message states.
+type Receipt struct {
+	ProviderMessageID string
+	Status            model.MessageStatus // delivered | undelivered | expired
+	At                time.Time
+}
+
+// Parser decodes the callback request of a single provider into receipts.
+// Receipts whose provider status is still in progress are skipped.
+type Parser func(r *http.Request) ([]Receipt, error)
+
+var parsers = map[string]Parser{
+	"kavenegar": parseKavenegar,
+	"ghasedak":  parseGhasedak,
+	"smsir":     parseSMSIR,
+}
+
+// ParserFor returns the parser of an adapter kind, falling back to the generic JSON format.
+func ParserFor(kind string) Parser {
+	if p, ok := parsers[strings.ToLower(kind)]; ok {
+		return p
+	}
+	return parseGeneric
+}
+
+// parseKavenegar handles form callbacks: messageid=<id>&status=<code>.
+func parseKavenegar(r *http.Request) ([]Receipt, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	id := strings.TrimSpace(r.Form.Get("messageid"))
+	if id == "" {
+		return nil, ErrEmptyReceipt
+	}
+
+	var st model.MessageStatus
+	switch strings.TrimSpace(r.Form.Get("status")) {
+	case "10":
+		st = model.StatusDelivered
+	case "6", "11", "13", "14":
+		st = model.StatusUndelivered
+	case "1", "2", "4", "5":
+		return nil, nil // still in progress
+	default:
+		return nil, fmt.Errorf("%w: kavenegar %q", ErrUnknownStatus, r.Form.Get("status"))
+	}
+
+	return []Receipt{{ProviderMessageID: id, Status: st, At: time.Now()}}, nil
+}
+
+// parseGhasedak handles form callbacks: messageid=<id>&status=<code>.
+func parseGhasedak(r *http.Request) ([]Receipt, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	id := strings.TrimSpace(r.Form.Get("messageid"))
+	if id == "" {
+		return nil, ErrEmptyReceipt
+	}
+
+	var st model.MessageStatus
+	switch strings.TrimSpace(r.Form.Get("status")) {
+	case "2":
+		st = model.StatusDelivered
+	case "8", "16":
+		st = model.StatusUndelivered
+	case "0", "1":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%w: ghasedak %q", ErrUnknownStatus, r.Form.Get("status"))
+	}
+
+	return []Receipt{{ProviderMessageID: id, Status: st, At: time.Now()}}, nil
+}
+
+// parseSMSIR handles JSON callbacks carrying a list of {MessageId, DeliveryState, DeliveryDateTime}.
+func parseSMSIR(r *http.Request) ([]Receipt, error) {
+	var body []struct {
+		MessageID        json.Number `json:"MessageId"`
+		DeliveryState    int         `json:"DeliveryState"`
+		DeliveryDateTime int64       `json:"DeliveryDateTime"` // unix seconds
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	out := make([]Receipt, 0, len(body))
+	for _, b := range body {
+		if b.MessageID.String() == "" {
+			continue
+		}
+		var st model.MessageStatus
+		switch b.DeliveryState {
+		case 1:
+			st = model.StatusDelivered
+		case 2, 5:
+			st = model.StatusUndelivered
+		case 3, 4:
+			continue
+		default:
+			return nil, fmt.Errorf("%w: smsir %d", ErrUnknownStatus, b.DeliveryState)
+		}
+		at := time.Now()
+		if b.DeliveryDateTime > 0 {
+			at = time.Unix(b.DeliveryDateTime, 0)
+		}
+		out = append(out, Receipt{ProviderMessageID: b.MessageID.String(), Status: st, At: at})
+	}
+	if len(out) == 0 && len(body) == 0 {
+		return nil, ErrEmptyReceipt
+	}
+	return out, nil
+}
+
+// parseGeneric handles our own format: {"message_id": "...", "status": "delivered", "timestamp": <unix>}
+// or a JSON array of such objects.
+func parseGeneric(r *http.Request) ([]Receipt, error) {
+	type item struct {
+		MessageID string `json:"message_id"`
+		Status    string `json:"status"`
+		Timestamp int64  `json:"timestamp"` // unix seconds, optional
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		return nil, err
+	}
+	var items []item
+	if len(raw) > 0 && raw[0] == '[' {
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+	} else {
+		var one item
+		if err := json.Unmarshal(raw, &one); err != nil {
+			return nil, err
+		}
+		items = []item{one}
+	}
+
+	out := make([]Receipt, 0, len(items))
+	for _, it := range items {
+		if strings.TrimSpace(it.MessageID) == "" {
+			return nil, ErrEmptyReceipt
+		}
+		st := model.MessageStatus(strings.ToLower(strings.TrimSpace(it.Status)))
+		if !st.IsDLR() {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownStatus, it.Status)
+		}
+		at := time.Now()
+		if it.Timestamp > 0 {
+			at = time.Unix(it.Timestamp, 0)
+		}
+		out = append(out, Receipt{ProviderMessageID: it.MessageID, Status: st, At: at})
+	}
+	return out, nil
+}
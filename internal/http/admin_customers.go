@@ -0,0 +1,458 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmehdipour/sms-gateway/internal/service/customers"
+	echo "github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+// maxCustomerName matches customers.name VARCHAR(120).
+const maxCustomerName = 120
+
+type createCustomerReq struct {
+	Name           string `json:"name"`
+	RateLimitRPS   *int   `json:"rate_limit_rps"`   // optional; absent or 0 uses rate_limit.rps
+	RateLimitBurst *int   `json:"rate_limit_burst"` // optional; absent or 0 uses the rate
+}
+
+// updateCustomerReq is a PATCH: absent fields are left unchanged, a rate limit of 0 clears the override.
+type updateCustomerReq struct {
+	Name           *string  `json:"name"`
+	RateLimitRPS   *int     `json:"rate_limit_rps"`
+	RateLimitBurst *int     `json:"rate_limit_burst"`
+	Timezone       *string  `json:"timezone"` // IANA name, e.g. Asia/Tehran
+	Caps           *capsReq `json:"caps"`
+}
+
+// capsReq is part of a PATCH: absent caps are left unchanged, 0 removes a cap.
+type capsReq struct {
+	DailyMessages   *int64 `json:"daily_messages"`
+	MonthlyMessages *int64 `json:"monthly_messages"`
+	DailySpend      *int64 `json:"daily_spend"`
+	MonthlySpend    *int64 `json:"monthly_spend"`
+}
+
+type createKeyReq struct {
+	Label      string     `json:"label"`
+	Scopes     []string   `json:"scopes"`      // empty = all scopes
+	AllowedIPs []string   `json:"allowed_ips"` // IPs or CIDRs; empty = any address
+	ExpiresAt  *time.Time `json:"expires_at"`  // RFC3339; nil = never
+}
+
+type rotateKeyReq struct {
+	GraceSeconds int `json:"grace_seconds"` // how long the old key keeps working, up to 7 days
+}
+
+type statusReq struct {
+	Reason string `json:"reason"`
+}
+
+// customerJSON is the admin view of a customer.
+func customerJSON(c model.Customer) map[string]any {
+	return map[string]any{
+		"id":               c.ID,
+		"name":             c.Name,
+		"status":           c.Status,
+		"rate_limit_rps":   c.RateLimitRPS,
+		"rate_limit_burst": c.RateLimitBurst,
+		"webhook_url":      c.WebhookURL,
+		"timezone":         c.Timezone,
+		"caps": map[string]any{
+			"daily_messages":   c.DailyMessages,
+			"monthly_messages": c.MonthlyMessages,
+			"daily_spend":      c.DailySpend,
+			"monthly_spend":    c.MonthlySpend,
+		},
+		"created_at": c.CreatedAt,
+		"updated_at": c.UpdatedAt,
+	}
+}
+
+// apiKeyJSON never includes the secret or its hash.
+func apiKeyJSON(k model.APIKey) map[string]any {
+	return map[string]any{
+		"id":           k.ID,
+		"prefix":       k.Prefix,
+		"label":        k.Label,
+		"scopes":       k.ScopeList(),
+		"allowed_ips":  k.AllowedIPList(),
+		"expires_at":   k.ExpiresAt,
+		"last_used_at": k.LastUsedAt,
+		"revoked_at":   k.RevokedAt,
+		"created_at":   k.CreatedAt,
+	}
+}
+
+func auditJSON(e model.AuditEntry) map[string]any {
+	var details any
+	if e.Details != nil {
+		details = json.RawMessage(*e.Details)
+	}
+	return map[string]any{
+		"id":          e.ID,
+		"actor":       e.Actor,
+		"remote_ip":   e.RemoteIP,
+		"action":      e.Action,
+		"customer_id": e.CustomerID,
+		"details":     details,
+		"created_at":  e.CreatedAt,
+	}
+}
+
+// actorOf names the operator from X-Admin-Actor; the shared admin token cannot tell them apart.
+func actorOf(c echo.Context) customers.Actor {
+	name := strings.TrimSpace(c.Request().Header.Get("X-Admin-Actor"))
+	if name == "" {
+		name = "admin"
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return customers.Actor{Name: name, RemoteIP: c.RealIP()}
+}
+
+// validLimit accepts an absent limit, 0 (no override) or a positive value.
+func validLimit(n *int) bool { return n == nil || *n >= 0 }
+
+func customerIDParam(c echo.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	return id, err == nil && id > 0
+}
+
+// customerError maps lifecycle service errors to responses.
+func customerError(c echo.Context, op string, err error) error {
+	switch {
+	case errors.Is(err, customers.ErrNotFound), errors.Is(err, customers.ErrKeyNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "not found"})
+	case errors.Is(err, customers.ErrInvalidStatus), errors.Is(err, customers.ErrKeyRevoked):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, customers.ErrInvalidKey):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	log.Errorf("%s customer failed: %v", op, err)
+
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+}
+
+// createCustomerHandler creates a customer and returns its API key (POST /admin/v1/customers).
+// This is the only time the key is shown.
+func createCustomerHandler(svc *customers.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req createCustomerReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "bad request"})
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || len(req.Name) > maxCustomerName {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid name"})
+		}
+		if !validLimit(req.RateLimitRPS) || !validLimit(req.RateLimitBurst) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid rate limit"})
+		}
+
+		cust, key, err := svc.Create(c.Request().Context(), actorOf(c), req.Name, req.RateLimitRPS, req.RateLimitBurst)
+		if err != nil {
+			return customerError(c, "create", err)
+		}
+		out := customerJSON(*cust)
+		out["api_key"] = key
+		return c.JSON(http.StatusCreated, out)
+	}
+}
+
+func listCustomersHandler(repo repository.CustomersRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		status := c.QueryParam("status")
+		if status != "" && status != customers.StatusActive && status != customers.StatusSuspended {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid status"})
+		}
+		limit := 50
+		offset := 0
+		if v := c.QueryParam("limit"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 1000 {
+				limit = n
+			}
+		}
+		if v := c.QueryParam("offset"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+				offset = n
+			}
+		}
+
+		rows, err := repo.List(c.Request().Context(), status, limit, offset)
+		if err != nil {
+			log.Errorf("list customers failed: %v", err)
+
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+		results := make([]map[string]any, 0, len(rows))
+		for _, r := range rows {
+			results = append(results, customerJSON(r))
+		}
+		return c.JSON(http.StatusOK, map[string]any{
+			"limit":   limit,
+			"offset":  offset,
+			"count":   len(results),
+			"results": results,
+		})
+	}
+}
+
+// getCustomerHandler returns a customer with its wallet state and usage against its caps
+// (GET /admin/v1/customers/:id).
+func getCustomerHandler(repo repository.CustomersRepository, stmts repository.WalletStatementRepository, usage repository.UsageRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, ok := customerIDParam(c)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		ctx := c.Request().Context()
+
+		cust, used, err := loadUsage(c, repo, usage, id)
+		if err != nil {
+			log.Errorf("get customer failed: %v", err)
+
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+		if cust == nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "not found"})
+		}
+		acc, err := stmts.GetAccount(ctx, id)
+		if err != nil {
+			log.Errorf("get wallet failed: %v", err)
+
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+		if acc == nil {
+			acc = &model.WalletAccount{CustomerID: id}
+		}
+
+		out := customerJSON(*cust)
+		out["wallet"] = map[string]any{
+			"balance":    acc.Balance,
+			"reserved":   acc.Reserved,
+			"updated_at": acc.UpdatedAt,
+		}
+		out["usage"] = used
+		return c.JSON(http.StatusOK, out)
+	}
+}
+
+func updateCustomerHandler(svc *customers.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, ok := customerIDParam(c)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		var req updateCustomerReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "bad request"})
+		}
+		if req.Name != nil {
+			name := strings.TrimSpace(*req.Name)
+			if name == "" || len(name) > maxCustomerName {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid name"})
+			}
+			req.Name = &name
+		}
+		if !validLimit(req.RateLimitRPS) || !validLimit(req.RateLimitBurst) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid rate limit"})
+		}
+		if req.Timezone != nil {
+			if _, err := time.LoadLocation(*req.Timezone); err != nil || *req.Timezone == "" || *req.Timezone == "Local" {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid timezone"})
+			}
+		}
+
+		u := customers.Update{Name: req.Name, RateLimitRPS: req.RateLimitRPS, RateLimitBurst: req.RateLimitBurst, Timezone: req.Timezone}
+		if cp := req.Caps; cp != nil {
+			for _, v := range []*int64{cp.DailyMessages, cp.MonthlyMessages, cp.DailySpend, cp.MonthlySpend} {
+				if v != nil && *v < 0 {
+					return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid cap"})
+				}
+			}
+			u.DailyMessages, u.MonthlyMessages = cp.DailyMessages, cp.MonthlyMessages
+			u.DailySpend, u.MonthlySpend = cp.DailySpend, cp.MonthlySpend
+		}
+		cust, err := svc.Update(c.Request().Context(), actorOf(c), id, u)
+		if err != nil {
+			return customerError(c, "update", err)
+		}
+		return c.JSON(http.StatusOK, customerJSON(*cust))
+	}
+}
+
+func suspendCustomerHandler(svc *customers.Service) echo.HandlerFunc {
+	return statusHandler("suspend", svc.Suspend)
+}
+
+func reactivateCustomerHandler(svc *customers.Service) echo.HandlerFunc {
+	return statusHandler("reactivate", svc.Reactivate)
+}
+
+type statusFunc func(ctx context.Context, actor customers.Actor, id int64, reason string) (*model.Customer, error)
+
+// statusHandler serves suspend/reactivate; the optional body {"reason": ".."} goes to the audit log.
+func statusHandler(op string, fn statusFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, ok := customerIDParam(c)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		var req statusReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "bad request"})
+		}
+
+		cust, err := fn(c.Request().Context(), actorOf(c), id, strings.TrimSpace(req.Reason))
+		if err != nil {
+			return customerError(c, op, err)
+		}
+		return c.JSON(http.StatusOK, customerJSON(*cust))
+	}
+}
+
+func keyIDParam(c echo.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("key_id"), 10, 64)
+	return id, err == nil && id > 0
+}
+
+func listKeysHandler(svc *customers.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, ok := customerIDParam(c)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+
+		rows, err := svc.Keys(c.Request().Context(), id)
+		if err != nil {
+			return customerError(c, "list keys of", err)
+		}
+		items := make([]map[string]any, len(rows))
+		for i, k := range rows {
+			items[i] = apiKeyJSON(k)
+		}
+		return c.JSON(http.StatusOK, map[string]any{"items": items})
+	}
+}
+
+// createKeyHandler issues an API key (POST /admin/v1/customers/:id/api-keys). The key is
+// returned only in this response.
+func createKeyHandler(svc *customers.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, ok := customerIDParam(c)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		var req createKeyReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "bad request"})
+		}
+
+		spec := customers.KeySpec{Label: req.Label, Scopes: req.Scopes, AllowedIPs: req.AllowedIPs, ExpiresAt: req.ExpiresAt}
+		key, k, err := svc.CreateKey(c.Request().Context(), actorOf(c), id, spec)
+		if err != nil {
+			return customerError(c, "create key of", err)
+		}
+		out := apiKeyJSON(*k)
+		out["api_key"] = key
+		return c.JSON(http.StatusCreated, out)
+	}
+}
+
+// rotateKeyHandler replaces a key (POST /admin/v1/customers/:id/api-keys/:key_id/rotate).
+// With grace_seconds the old key keeps working that long, otherwise it is revoked at once.
+func rotateKeyHandler(svc *customers.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, ok := customerIDParam(c)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		keyID, ok := keyIDParam(c)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid key id"})
+		}
+		var req rotateKeyReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "bad request"})
+		}
+
+		grace := time.Duration(req.GraceSeconds) * time.Second
+		key, k, err := svc.RotateKey(c.Request().Context(), actorOf(c), id, keyID, grace)
+		if err != nil {
+			return customerError(c, "rotate key of", err)
+		}
+		out := apiKeyJSON(*k)
+		out["api_key"] = key
+		return c.JSON(http.StatusOK, out)
+	}
+}
+
+func revokeKeyHandler(svc *customers.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, ok := customerIDParam(c)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		keyID, ok := keyIDParam(c)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid key id"})
+		}
+
+		if err := svc.RevokeKey(c.Request().Context(), actorOf(c), id, keyID); err != nil {
+			return customerError(c, "revoke key of", err)
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// listAuditHandler pages through the audit log newest first (GET /admin/v1/customers/:id/audit).
+// Pass next_cursor back as cursor.
+func listAuditHandler(audit repository.AuditRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, ok := customerIDParam(c)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		limit := 50
+		if v := c.QueryParam("limit"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 1000 {
+				limit = n
+			}
+		}
+		var cursor int64
+		if v := c.QueryParam("cursor"); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n <= 0 {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid cursor"})
+			}
+			cursor = n
+		}
+
+		rows, err := audit.List(c.Request().Context(), &id, cursor, limit)
+		if err != nil {
+			log.Errorf("list audit failed: %v", err)
+
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+		items := make([]map[string]any, len(rows))
+		for i, r := range rows {
+			items[i] = auditJSON(r)
+		}
+		out := map[string]any{"items": items}
+		if len(rows) == limit {
+			out["next_cursor"] = strconv.FormatInt(rows[len(rows)-1].ID, 10)
+		}
+		return c.JSON(http.StatusOK, out)
+	}
+}
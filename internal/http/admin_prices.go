@@ -0,0 +1,126 @@
+package http
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmehdipour/sms-gateway/internal/service/pricing"
+	echo "github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+// pricePrefix is "+" followed by the leading digits of an E.164 number.
+var pricePrefix = regexp.MustCompile(`^\+[1-9]\d{0,14}$`)
+
+type priceReq struct {
+	CustomerID int64  `json:"customer_id"` // 0 = global table
+	Prefix     string `json:"prefix"`
+	Lane       string `json:"lane"`  // "normal" | "express"
+	Price      int64  `json:"price"` // per segment
+}
+
+func priceRuleJSON(r model.PriceRule) map[string]any {
+	return map[string]any{
+		"id":          r.ID,
+		"customer_id": r.CustomerID,
+		"prefix":      r.Prefix,
+		"lane":        r.Lane.String(),
+		"price":       r.Price,
+		"updated_at":  r.UpdatedAt,
+	}
+}
+
+func listPricesHandler(prices repository.PricesRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var custID *int64
+		if s := c.QueryParam("customer_id"); s != "" {
+			id, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || id < 0 {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid customer_id"})
+			}
+			custID = &id
+		}
+		var lane model.SMSType
+		if s := c.QueryParam("lane"); s != "" {
+			t, ok := model.ParseSMSType(s)
+			if !ok {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid lane"})
+			}
+			lane = t
+		}
+
+		rows, err := prices.List(c.Request().Context(), custID, lane)
+		if err != nil {
+			log.Errorf("list prices failed: %v", err)
+
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+		items := make([]map[string]any, len(rows))
+		for i, r := range rows {
+			items[i] = priceRuleJSON(r)
+		}
+		return c.JSON(http.StatusOK, map[string]any{"items": items})
+	}
+}
+
+func putPriceHandler(prices repository.PricesRepository, pricer *pricing.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var req priceReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "bad request"})
+		}
+
+		req.Prefix = strings.TrimSpace(req.Prefix)
+		if !pricePrefix.MatchString(req.Prefix) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid prefix"})
+		}
+		lane, ok := model.ParseSMSType(req.Lane)
+		if !ok || strings.TrimSpace(req.Lane) == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid lane"})
+		}
+		if req.Price <= 0 || req.CustomerID < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid price"})
+		}
+
+		rule := model.PriceRule{CustomerID: req.CustomerID, Prefix: req.Prefix, Lane: lane, Price: req.Price}
+		if err := prices.Upsert(c.Request().Context(), rule); err != nil {
+			log.Errorf("upsert price failed: %v", err)
+
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+		pricer.Invalidate(c.Request().Context())
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"customer_id": rule.CustomerID,
+			"prefix":      rule.Prefix,
+			"lane":        rule.Lane.String(),
+			"price":       rule.Price,
+		})
+	}
+}
+
+func deletePriceHandler(prices repository.PricesRepository, pricer *pricing.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil || id <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+
+		ok, err := prices.Delete(c.Request().Context(), id)
+		if err != nil {
+			log.Errorf("delete price failed: %v", err)
+
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+		if !ok {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "not found"})
+		}
+		pricer.Invalidate(c.Request().Context())
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
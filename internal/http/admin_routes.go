@@ -0,0 +1,199 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jmehdipour/sms-gateway/internal/config"
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmehdipour/sms-gateway/internal/service/routing"
+	"github.com/jmehdipour/sms-gateway/internal/util"
+	echo "github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+type routeReq struct {
+	Prefix    string   `json:"prefix"`
+	Lane      string   `json:"lane"`      // "normal" | "express"
+	Providers []string `json:"providers"` // in failover order
+}
+
+func routeRuleJSON(r model.RouteRule) map[string]any {
+	return map[string]any{
+		"id":         r.ID,
+		"prefix":     r.Prefix,
+		"lane":       r.Lane.String(),
+		"providers":  r.ProviderList(),
+		"updated_at": r.UpdatedAt,
+	}
+}
+
+// enabledProviders lists the names of enabled providers in config order.
+func enabledProviders(cfg config.Config) []string {
+	var out []string
+	for _, pc := range cfg.Providers {
+		if pc.Enabled {
+			out = append(out, pc.Name)
+		}
+	}
+	return out
+}
+
+func listRoutesHandler(routes repository.RoutesRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var lane model.SMSType
+		if s := c.QueryParam("lane"); s != "" {
+			t, ok := model.ParseSMSType(s)
+			if !ok {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid lane"})
+			}
+			lane = t
+		}
+
+		rows, err := routes.List(c.Request().Context(), lane)
+		if err != nil {
+			log.Errorf("list routes failed: %v", err)
+
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+		items := make([]map[string]any, len(rows))
+		for i, r := range rows {
+			items[i] = routeRuleJSON(r)
+		}
+		return c.JSON(http.StatusOK, map[string]any{"items": items})
+	}
+}
+
+func putRouteHandler(routes repository.RoutesRepository, router *routing.Service, cfg config.Config) echo.HandlerFunc {
+	known := map[string]bool{}
+	for _, pc := range cfg.Providers {
+		known[pc.Name] = true
+	}
+
+	return func(c echo.Context) error {
+		var req routeReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "bad request"})
+		}
+
+		req.Prefix = strings.TrimSpace(req.Prefix)
+		if !pricePrefix.MatchString(req.Prefix) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid prefix"})
+		}
+		lane, ok := model.ParseSMSType(req.Lane)
+		if !ok || strings.TrimSpace(req.Lane) == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid lane"})
+		}
+		if len(req.Providers) == 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "providers required"})
+		}
+		seen := map[string]bool{}
+		for _, p := range req.Providers {
+			if !known[p] || seen[p] {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "unknown or duplicate provider: " + p})
+			}
+			seen[p] = true
+		}
+
+		rule := model.RouteRule{Prefix: req.Prefix, Lane: lane, Providers: strings.Join(req.Providers, ",")}
+		if len(rule.Providers) > 255 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "too many providers"})
+		}
+		if err := routes.Upsert(c.Request().Context(), rule); err != nil {
+			log.Errorf("upsert route failed: %v", err)
+
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+		router.Invalidate()
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"prefix":    rule.Prefix,
+			"lane":      rule.Lane.String(),
+			"providers": req.Providers,
+		})
+	}
+}
+
+func deleteRouteHandler(routes repository.RoutesRepository, router *routing.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil || id <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+
+		ok, err := routes.Delete(c.Request().Context(), id)
+		if err != nil {
+			log.Errorf("delete route failed: %v", err)
+
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+		if !ok {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "not found"})
+		}
+		router.Invalidate()
+
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// dryRunRouteHandler shows where a number would go (GET /admin/v1/routes/dry-run?phone=&lane=):
+// the matching rule and its providers in failover order, or the default pool and its strategy.
+// Provider health is not considered; the sender skips tripped providers at send time.
+func dryRunRouteHandler(router *routing.Service, cfg config.Config) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		phone := util.NormalizePhone(c.QueryParam("phone"))
+		if !util.ValidPhone(phone) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid phone"})
+		}
+		lane := model.SMSTypeNormal
+		if s := c.QueryParam("lane"); s != "" {
+			t, ok := model.ParseSMSType(s)
+			if !ok {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid lane"})
+			}
+			lane = t
+		}
+
+		enabled := enabledProviders(cfg)
+		rule, ok := router.Match(c.Request().Context(), phone, lane)
+		if !ok {
+			strategy := cfg.Dispatcher.Routing.Normal
+			if lane == model.SMSTypeExpress {
+				strategy = cfg.Dispatcher.Routing.Express
+			}
+			if strategy == "" {
+				strategy = "round_robin"
+			}
+			return c.JSON(http.StatusOK, map[string]any{
+				"phone":     phone,
+				"lane":      lane.String(),
+				"source":    "default",
+				"strategy":  strategy,
+				"providers": enabled,
+			})
+		}
+
+		isEnabled := map[string]bool{}
+		for _, p := range enabled {
+			isEnabled[p] = true
+		}
+		providers, disabled := []string{}, []string{}
+		for _, p := range rule.ProviderList() {
+			if isEnabled[p] {
+				providers = append(providers, p)
+			} else {
+				disabled = append(disabled, p)
+			}
+		}
+		return c.JSON(http.StatusOK, map[string]any{
+			"phone":     phone,
+			"lane":      lane.String(),
+			"source":    "rule",
+			"rule":      routeRuleJSON(rule),
+			"providers": providers,
+			"disabled":  disabled,
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/jmehdipour/sms-gateway/internal/dlr"
+	echo "github.com/labstack/echo/v4"
+)
+
+// dlrRoute is how the callbacks of one provider are authenticated and parsed.
+type dlrRoute struct {
+	token string // shared secret; empty disables the token check
+	kind  string // adapter kind, which picks the receipt parser
+}
+
+// dlrCallbackHandler ingests provider delivery receipts (POST /callbacks/dlr/:provider).
+// routes maps provider name → its route; providers missing from it are rejected.
+// When a receipt matches no message, most likely because the sender has not flushed its
+// provider_message_id yet, the reply is 503 so the provider redelivers the batch; receipts
+// already applied are skipped on the retry. Past the applier's grace an unmatched receipt is
+// acknowledged, so one that can never match does not block its batch forever.
+func dlrCallbackHandler(applier *dlr.Applier, routes map[string]dlrRoute) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		provider := strings.ToLower(strings.TrimSpace(c.Param("provider")))
+		route, ok := routes[provider]
+		if !ok {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "unknown provider"})
+		}
+		if want := route.token; want != "" {
+			got := c.QueryParam("token")
+			if got == "" {
+				got = c.Request().Header.Get("X-DLR-Token")
+			}
+			if subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid token"})
+			}
+		}
+
+		receipts, err := dlr.ParserFor(route.kind)(c.Request())
+		if err != nil {
+			c.Logger().Warnf("dlr parse failed provider=%s: %v", provider, err)
+
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "bad receipt"})
+		}
+
+		out, err := applier.Apply(c.Request().Context(), provider, receipts)
+		if err != nil {
+			c.Logger().Errorf("dlr apply failed provider=%s: %v", provider, err)
+
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+
+		status := http.StatusOK
+		if out.Redeliver() {
+			status = http.StatusServiceUnavailable
+		}
+		return c.JSON(status, map[string]any{
+			"received":  len(receipts),
+			"applied":   out.Applied,
+			"ignored":   out.Ignored,
+			"pending":   out.Pending,
+			"unmatched": out.Unmatched,
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/jmehdipour/sms-gateway/internal/http/middleware"
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	echo "github.com/labstack/echo/v4"
+)
+
+// maxLookupIDs bounds GET /v1/sms?ids=...
+const maxLookupIDs = 100
+
+// messageJSON is the status view of a message row; provider fields appear once a provider accepted it.
+func messageJSON(m model.Message) map[string]any {
+	out := map[string]any{
+		"id":         m.ID,
+		"phone":      m.Phone,
+		"type":       m.Type.String(),
+		"status":     m.Status.String(),
+		"encoding":   m.Encoding.String(),
+		"segments":   m.Segments,
+		"price":      m.Price,
+		"created_at": m.CreatedAt,
+		"updated_at": m.UpdatedAt,
+	}
+	if m.SendAt != nil {
+		out["send_at"] = m.SendAt
+	}
+	if m.BatchID != nil {
+		out["batch_id"] = *m.BatchID
+	}
+	if m.ClientRef != nil {
+		out["client_ref"] = *m.ClientRef
+	}
+	if m.Provider != nil {
+		provider := map[string]any{"name": *m.Provider}
+		if m.ProviderMessageID != nil {
+			provider["message_id"] = *m.ProviderMessageID
+		}
+		if m.DLRAt != nil {
+			provider["dlr_at"] = m.DLRAt
+		}
+		out["provider"] = provider
+	}
+	return out
+}
+
+// getMessageHandler returns one of the customer's messages from MySQL (GET /v1/sms/:id).
+func getMessageHandler(msgs repository.MessagesRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		custID, ok := middleware.CustomerIDFromCtx(c)
+		if !ok || custID <= 0 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		}
+
+		id := strings.TrimSpace(c.Param("id"))
+		if id == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+
+		rows, err := msgs.GetByIDs(c.Request().Context(), custID, []string{id})
+		if err != nil {
+			c.Logger().Errorf("get message failed: %v", err)
+
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "query failed"})
+		}
+		if len(rows) == 0 {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "not found"})
+		}
+		return c.JSON(http.StatusOK, messageJSON(rows[0]))
+	}
+}
+
+// lookupMessagesHandler returns many of the customer's messages at once (GET /v1/sms?ids=a,b,c).
+// Results follow the order of ids; IDs that don't exist or belong to another customer are
+// listed under not_found.
+func lookupMessagesHandler(msgs repository.MessagesRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		custID, ok := middleware.CustomerIDFromCtx(c)
+		if !ok || custID <= 0 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		}
+
+		seen := make(map[string]bool)
+		var ids []string
+		for _, id := range strings.Split(c.QueryParam("ids"), ",") {
+			id = strings.TrimSpace(id)
+			if id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+		if len(ids) == 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "ids required"})
+		}
+		if len(ids) > maxLookupIDs {
+			return c.JSON(http.StatusBadRequest, map[string]any{"error": "too many ids", "max_ids": maxLookupIDs})
+		}
+
+		rows, err := msgs.GetByIDs(c.Request().Context(), custID, ids)
+		if err != nil {
+			c.Logger().Errorf("lookup messages failed: %v", err)
+
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "query failed"})
+		}
+
+		byID := make(map[string]model.Message, len(rows))
+		for _, m := range rows {
+			byID[m.ID] = m
+		}
+		results := make([]map[string]any, 0, len(rows))
+		notFound := make([]string, 0)
+		for _, id := range ids {
+			if m, ok := byID[id]; ok {
+				results = append(results, messageJSON(m))
+			} else {
+				notFound = append(notFound, id)
+			}
+		}
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"count":     len(results),
+			"results":   results,
+			"not_found": notFound,
+		})
+	}
+}
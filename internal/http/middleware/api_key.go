@@ -1,17 +1,27 @@
 package middleware
 
 import (
+	"context"
+	"crypto/subtle"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/jmehdipour/sms-gateway/internal/model"
 	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmehdipour/sms-gateway/internal/util"
 	echo "github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
 )
 
 type ctxKey int
 
 const ctxCustomerID ctxKey = 1
 
+// lastUsedEvery throttles api_keys.last_used_at writes per key and process.
+const lastUsedEvery = time.Minute
+
 // CustomerIDFromCtx extracts authenticated customer_id set by APIKeyMiddleware.
 func CustomerIDFromCtx(c echo.Context) (int64, bool) {
 	v := c.Get("customer_id")
@@ -19,27 +29,96 @@ func CustomerIDFromCtx(c echo.Context) (int64, bool) {
 	return id, ok
 }
 
-// APIKeyMiddleware authenticates requests using X-API-Key header.
-// On success it stores customer_id in context and optionally blocks suspended accounts.
-func APIKeyMiddleware(customers repository.CustomersRepository) echo.MiddlewareFunc {
+// APIKeyFromCtx returns the key the request authenticated with.
+func APIKeyFromCtx(c echo.Context) (model.APIKey, bool) {
+	k, ok := c.Get("api_key").(model.APIKey)
+	return k, ok
+}
+
+// APIKeyMiddleware authenticates requests using the X-API-Key header against api_keys.
+// The key must hash to the stored secret, be neither revoked nor expired, come from an
+// allowed address, and belong to an active customer. On success it stores customer_id and
+// the key in context; RequireScope then checks the key per route.
+func APIKeyMiddleware(keys repository.APIKeysRepository) echo.MiddlewareFunc {
+	usage := &lastUsed{keys: keys, seen: map[int64]time.Time{}}
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			key := strings.TrimSpace(c.Request().Header.Get("X-API-Key"))
 			if key == "" {
 				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "missing api key"})
 			}
-			cu, err := customers.GetByAPIKey(c.Request().Context(), key)
+			prefix, ok := util.APIKeyPrefix(key)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid api key"})
+			}
+			cred, err := keys.GetCredential(c.Request().Context(), prefix)
 			if err != nil {
 				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "auth error"})
 			}
-			if cu == nil || cu.Status != "active" {
+			if cred == nil || subtle.ConstantTimeCompare([]byte(util.HashAPIKey(key)), []byte(cred.SecretHash)) != 1 {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid api key"})
+			}
+			now := time.Now()
+			if !cred.Usable(now) || cred.CustomerStatus != "active" {
 				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid api key"})
 			}
-			c.Set("customer_id", cu.ID)
-			if cu.RateLimitRPS != nil {
-				c.Set("customer_rps", *cu.RateLimitRPS)
+			if !cred.AllowsIP(c.RealIP()) {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "ip not allowed"})
+			}
+
+			c.Set("customer_id", cred.CustomerID)
+			c.Set("api_key", cred.APIKey)
+			if cred.RateLimitRPS != nil {
+				c.Set("customer_rps", *cred.RateLimitRPS)
+			}
+			if cred.RateLimitBurst != nil {
+				c.Set("customer_burst", *cred.RateLimitBurst)
+			}
+			usage.touch(cred.ID, now)
+			return next(c)
+		}
+	}
+}
+
+// RequireScope rejects requests whose key lacks scope. It must run after APIKeyMiddleware.
+func RequireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			k, ok := APIKeyFromCtx(c)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			}
+			if !k.HasScope(scope) {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "api key lacks scope " + scope})
 			}
 			return next(c)
 		}
 	}
 }
+
+// lastUsed records key usage at most once per lastUsedEvery, off the request path.
+type lastUsed struct {
+	keys repository.APIKeysRepository
+
+	mu   sync.Mutex
+	seen map[int64]time.Time
+}
+
+func (u *lastUsed) touch(id int64, now time.Time) {
+	u.mu.Lock()
+	if now.Sub(u.seen[id]) < lastUsedEvery {
+		u.mu.Unlock()
+		return
+	}
+	u.seen[id] = now
+	u.mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := u.keys.TouchLastUsed(ctx, id, now.Truncate(time.Second)); err != nil {
+			log.Warnf("api key %d: last_used_at update failed: %v", id, err)
+		}
+	}()
+}
@@ -3,27 +3,62 @@ package middleware
 import (
 	"net/http"
 	"strconv"
-	"time"
 
+	"github.com/jmehdipour/sms-gateway/internal/metrics"
 	echo "github.com/labstack/echo/v4"
 	"github.com/redis/go-redis/v9"
 )
 
-// RateLimitConfig config for Redis-based RPS limiter.
+// bucketScript takes one token from a customer's bucket for a route group. The bucket holds
+// up to burst tokens and refills at rate per second, continuously, so there is no window edge
+// to burst across. Time comes from the Redis server so every API node agrees.
+// KEYS: bucket hash. ARGV: rate, burst.
+// Returns {allowed, remaining, retry_after_ms, reset_ms}; reset is when the bucket is full again.
+var bucketScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(b[1]) or burst
+local ts = tonumber(b[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local allowed, retry = 0, 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, math.floor(tokens), retry, math.ceil((burst - tokens) * 1000 / rate)}
+`)
+
+// Rate is a token bucket: RPS tokens per second, up to Burst at once. Burst <= 0 means RPS.
+type Rate struct {
+	RPS   int
+	Burst int
+}
+
+// RateLimitConfig config for the Redis token-bucket limiter.
 type RateLimitConfig struct {
-	Redis          *redis.Client
-	DefaultRPS     int           // fallback if customer_rps not set
-	KeyPrefix      string        // e.g. "rl:cust:"
-	Window         time.Duration // usually 1s
-	RetryAfterHint bool          // set Retry-After header when limited
+	Redis     *redis.Client
+	Default   Rate   // rate_limit.rps / rate_limit.burst, when the customer has no override
+	KeyPrefix string // e.g. "rl:cust:"
 }
 
-// RateLimitMiddleware applies a simple fixed-window per-customer RPS limit.
-// It expects customer_id in echo.Context (set by APIKeyMiddleware).
-func RateLimitMiddleware(cfg RateLimitConfig) echo.MiddlewareFunc {
-	if cfg.Window <= 0 {
-		cfg.Window = time.Second
-	}
+// RateLimitMiddleware limits each customer per route group with a token bucket in Redis.
+// The bucket's rate is the group's own when set (fixed.RPS > 0), else the customer's
+// rate_limit_rps / rate_limit_burst, else cfg.Default. Every response carries
+// X-RateLimit-Limit (bucket size), X-RateLimit-Remaining and X-RateLimit-Reset (seconds
+// until the bucket is full); a 429 adds Retry-After. Requests pass unlimited when Redis
+// fails. It expects customer_id in echo.Context (set by APIKeyMiddleware).
+func RateLimitMiddleware(cfg RateLimitConfig, group string, fixed Rate) echo.MiddlewareFunc {
 	if cfg.KeyPrefix == "" {
 		cfg.KeyPrefix = "rl:cust:"
 	}
@@ -35,41 +70,44 @@ func RateLimitMiddleware(cfg RateLimitConfig) echo.MiddlewareFunc {
 				return next(c)
 			}
 
-			max := cfg.DefaultRPS
-			if vv := c.Get("customer_rps"); vv != nil {
-				if m, ok := vv.(int); ok && m > 0 {
-					max = m
+			rate := fixed
+			if rate.RPS <= 0 {
+				rate = cfg.Default
+				if n, ok := c.Get("customer_rps").(int); ok && n > 0 {
+					rate = Rate{RPS: n}
+					if b, ok := c.Get("customer_burst").(int); ok && b > 0 {
+						rate.Burst = b
+					}
 				}
 			}
-			if max <= 0 || cfg.Redis == nil {
+			if rate.Burst <= 0 {
+				rate.Burst = rate.RPS
+			}
+			if rate.RPS <= 0 || cfg.Redis == nil {
 				// no limit configured or redis missing (dev): allow
 				return next(c)
 			}
 
-			// fixed-window key: rl:cust:{id}:{unix_sec}
-			now := time.Now()
-			key := cfg.KeyPrefix + strconv.FormatInt(custID, 10) + ":" + strconv.FormatInt(now.Unix(), 10)
-
-			// INCR and set expiry 2*window (safety)
-			pipe := cfg.Redis.Pipeline()
-			cnt := pipe.Incr(c.Request().Context(), key)
-			pipe.Expire(c.Request().Context(), key, cfg.Window*2)
-			_, err := pipe.Exec(c.Request().Context())
-			if err != nil {
+			key := cfg.KeyPrefix + strconv.FormatInt(custID, 10) + ":" + group
+			res, err := bucketScript.Run(c.Request().Context(), cfg.Redis, []string{key}, rate.RPS, rate.Burst).Int64Slice()
+			if err != nil || len(res) != 4 {
+				c.Logger().Warnf("rate limit %s: %v", key, err)
 				return next(c)
 			}
 
-			if cnt.Val() > int64(max) {
-				if cfg.RetryAfterHint {
-					// seconds until next window
-					remain := cfg.Window - time.Duration(now.UnixNano()%int64(cfg.Window))
-					if remain > 0 {
-						c.Response().Header().Set("Retry-After", strconv.Itoa(int(remain.Round(time.Second)/time.Second)))
-					}
-				}
+			h := c.Response().Header()
+			h.Set("X-RateLimit-Limit", strconv.Itoa(rate.Burst))
+			h.Set("X-RateLimit-Remaining", strconv.FormatInt(res[1], 10))
+			h.Set("X-RateLimit-Reset", strconv.FormatInt(ceilSeconds(res[3]), 10))
+
+			if res[0] != 1 {
+				metrics.RateLimitedTotal.WithLabelValues(group).Inc()
+				h.Set("Retry-After", strconv.FormatInt(ceilSeconds(res[2]), 10))
 				return c.JSON(http.StatusTooManyRequests, map[string]string{"error": "rate limited"})
 			}
 			return next(c)
 		}
 	}
 }
+
+func ceilSeconds(ms int64) int64 { return (ms + 999) / 1000 }
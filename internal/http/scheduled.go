@@ -0,0 +1,97 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jmehdipour/sms-gateway/internal/http/middleware"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmehdipour/sms-gateway/internal/service/queue"
+	echo "github.com/labstack/echo/v4"
+)
+
+// listScheduledHandler lists the customer's pending scheduled messages (GET /v1/sms/scheduled).
+func listScheduledHandler(msgs repository.MessagesRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		custID, ok := middleware.CustomerIDFromCtx(c)
+		if !ok || custID <= 0 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		}
+
+		limit := 50
+		offset := 0
+		if v := c.QueryParam("limit"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 1000 {
+				limit = n
+			}
+		}
+		if v := c.QueryParam("offset"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+				offset = n
+			}
+		}
+
+		rows, err := msgs.ListScheduled(c.Request().Context(), custID, limit, offset)
+		if err != nil {
+			c.Logger().Errorf("list scheduled failed: %v", err)
+
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "query failed"})
+		}
+
+		results := make([]map[string]any, 0, len(rows))
+		for _, m := range rows {
+			results = append(results, map[string]any{
+				"id":         m.ID,
+				"phone":      m.Phone,
+				"text":       m.Text,
+				"type":       m.Type,
+				"send_at":    m.SendAt,
+				"batch_id":   m.BatchID,
+				"created_at": m.CreatedAt,
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"limit":   limit,
+			"offset":  offset,
+			"count":   len(results),
+			"results": results,
+		})
+	}
+}
+
+// cancelScheduledHandler cancels a scheduled message and refunds it (DELETE /v1/sms/scheduled/:id).
+func cancelScheduledHandler(queueSvc *queue.Service) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		custID, ok := middleware.CustomerIDFromCtx(c)
+		if !ok || custID <= 0 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		}
+
+		id := strings.TrimSpace(c.Param("id"))
+		if id == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "bad request"})
+		}
+
+		if err := queueSvc.CancelScheduled(c.Request().Context(), custID, id); err != nil {
+			switch {
+			case errors.Is(err, queue.ErrMessageNotFound):
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "not found"})
+			case errors.Is(err, queue.ErrNotScheduled):
+				return c.JSON(http.StatusConflict, map[string]string{"error": "message is no longer scheduled"})
+			}
+
+			c.Logger().Errorf("cancel scheduled failed: %v", err)
+
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"canceled": true,
+			"id":       id,
+			"refunded": true,
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/http/middleware"
+	"github.com/jmehdipour/sms-gateway/internal/metrics"
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmehdipour/sms-gateway/internal/service/pricing"
+	"github.com/jmehdipour/sms-gateway/internal/service/queue"
+	"github.com/jmehdipour/sms-gateway/internal/util"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+type bulkRecipient struct {
+	Phone  string            `json:"phone"`
+	Text   string            `json:"text"`   // optional, overrides the shared text
+	Params map[string]string `json:"params"` // template params, merged over the shared ones
+}
+
+type bulkReq struct {
+	Text        string          `json:"text"`       // shared text
+	Type        string          `json:"type"`       // "normal" | "express"
+	Phones      []string        `json:"phones"`     // shorthand: recipients with the shared text
+	Recipients  []bulkRecipient `json:"recipients"` // per-recipient text
+	CallbackURL string          `json:"callback_url"`
+	SendAt      *time.Time      `json:"send_at"`
+
+	// template_id + params replace text; recipients may add their own params
+	TemplateID int64             `json:"template_id"`
+	Params     map[string]string `json:"params"`
+}
+
+type bulkResult struct {
+	Index    int    `json:"index"`
+	Phone    string `json:"phone"`
+	ID       string `json:"id,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Segments int    `json:"segments,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// sendBulkHandler enqueues many messages with one wallet reservation.
+// Invalid recipients are rejected individually; the rest are accepted together or not at all.
+func sendBulkHandler(queueSvc *queue.Service, customers repository.CustomersRepository, templates repository.TemplatesRepository, maxRecipients, maxSegments int) echo.HandlerFunc {
+	if maxSegments <= 0 {
+		maxSegments = defaultMaxSegments
+	}
+	return func(c echo.Context) error {
+		var req bulkReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "bad request"})
+		}
+
+		for _, p := range req.Phones {
+			req.Recipients = append(req.Recipients, bulkRecipient{Phone: p})
+		}
+		if len(req.Recipients) == 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "no recipients"})
+		}
+		if maxRecipients > 0 && len(req.Recipients) > maxRecipients {
+			return c.JSON(http.StatusRequestEntityTooLarge, map[string]any{
+				"error":          "too many recipients",
+				"max_recipients": maxRecipients,
+			})
+		}
+
+		typ, ok := model.ParseSMSType(req.Type)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid type"})
+		}
+
+		req.CallbackURL = strings.TrimSpace(req.CallbackURL)
+		if req.CallbackURL != "" && !validWebhookURL(c.Request().Context(), req.CallbackURL) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid callback_url"})
+		}
+
+		if !validSendAt(req.SendAt) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "send_at too far in the future"})
+		}
+
+		// auth (set by APIKeyMiddleware)
+		custID, ok := middleware.CustomerIDFromCtx(c)
+		if !ok || custID <= 0 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		}
+		if req.CallbackURL != "" {
+			if ok, resp := missingSigningSecret(c, customers, custID); ok {
+				return resp
+			}
+		}
+
+		var tmpl *model.Template
+		if req.TemplateID > 0 {
+			if strings.TrimSpace(req.Text) != "" {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "text and template_id are mutually exclusive"})
+			}
+			t, err := templates.Get(c.Request().Context(), custID, req.TemplateID)
+			if err != nil {
+				log.Errorf("load template failed: %v", err)
+
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+			}
+			if t == nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "template not found"})
+			}
+			tmpl = t
+		}
+
+		// Normalize + validate each recipient
+		shared := strings.TrimSpace(req.Text)
+		results := make([]bulkResult, len(req.Recipients))
+		items := make([]model.SMS, 0, len(req.Recipients))
+		accepted := make([]int, 0, len(req.Recipients)) // result index per item
+
+		for i, r := range req.Recipients {
+			phone := util.NormalizePhone(strings.TrimSpace(r.Phone))
+			text := strings.TrimSpace(r.Text)
+			var renderErr error
+			if tmpl != nil {
+				text, renderErr = tmpl.Render(mergeParams(req.Params, r.Params))
+				text = strings.TrimSpace(text)
+			} else if text == "" {
+				text = shared
+			}
+			enc, segments := util.CountSegments(text)
+			results[i] = bulkResult{Index: i, Phone: phone, Encoding: enc.String(), Segments: segments}
+
+			switch {
+			case !util.ValidPhone(phone):
+				results[i].Error = "invalid phone"
+			case renderErr != nil:
+				results[i].Error = renderErr.Error()
+			case text == "":
+				results[i].Error = "empty text"
+			case segments > maxSegments:
+				results[i].Error = "text too long"
+			default:
+				items = append(items, model.SMS{Phone: phone, Text: text, Type: typ})
+				accepted = append(accepted, i)
+			}
+		}
+
+		var batchID string
+		if len(items) > 0 {
+			var ids []string
+			var err error
+			batchID, ids, err = queueSvc.EnqueueBulk(c.Request().Context(), custID, items, queue.EnqueueOptions{
+				CallbackURL: req.CallbackURL,
+				SendAt:      sendAtOf(req.SendAt),
+			})
+			if err != nil {
+				if ok, resp := capExceeded(c, err); ok {
+					return resp
+				}
+				if errors.Is(err, pricing.ErrUnavailable) {
+					return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "pricing unavailable"})
+				}
+				if errors.Is(err, queue.ErrInsufficientFunds) {
+					return c.JSON(http.StatusPaymentRequired, map[string]any{
+						"error":       "insufficient_funds",
+						"description": "wallet balance is not enough to reserve the cost of all accepted recipients",
+						"type":        typ.String(),
+						"accepted":    len(items),
+						"customer_id": strconv.FormatInt(custID, 10),
+					})
+				}
+
+				log.Errorf("bulk enqueue failed: %v", err)
+
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+			}
+
+			for k, idx := range accepted {
+				results[idx].ID = ids[k]
+			}
+			metrics.MessagesTotal.WithLabelValues("enqueued", typ.String()).Add(float64(len(ids)))
+		}
+
+		return c.JSON(http.StatusAccepted, map[string]any{
+			"batch_id":    batchID,
+			"type":        typ.String(),
+			"accepted":    len(items),
+			"rejected":    len(results) - len(items),
+			"customer_id": strconv.FormatInt(custID, 10),
+			"results":     results,
+		})
+	}
+}
+
+// mergeParams returns shared overlaid with own (own wins).
+func mergeParams(shared, own map[string]string) map[string]string {
+	if len(own) == 0 {
+		return shared
+	}
+	out := make(map[string]string, len(shared)+len(own))
+	for k, v := range shared {
+		out[k] = v
+	}
+	for k, v := range own {
+		out[k] = v
+	}
+	return out
+}
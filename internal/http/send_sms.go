@@ -5,30 +5,102 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
-	"unicode/utf8"
+	"time"
 
 	"github.com/jmehdipour/sms-gateway/internal/http/middleware"
 	"github.com/jmehdipour/sms-gateway/internal/metrics"
 	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmehdipour/sms-gateway/internal/service/pricing"
 	"github.com/jmehdipour/sms-gateway/internal/service/queue"
 	"github.com/jmehdipour/sms-gateway/internal/util"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/gommon/log"
 )
 
+const (
+	// defaultMaxSegments caps message length when http.max_segments is unset.
+	defaultMaxSegments = 6
+	// maxScheduleAhead is how far in the future send_at may be.
+	maxScheduleAhead = 90 * 24 * time.Hour
+	// maxClientRefLen bounds Idempotency-Key / client_ref (messages.client_ref column).
+	maxClientRefLen = 128
+)
+
 type sendReq struct {
-	Phone string `json:"phone"`
-	Text  string `json:"text"`
-	Type  string `json:"type"` // "normal" | "express"
+	Phone       string     `json:"phone"`
+	Text        string     `json:"text"`
+	Type        string     `json:"type"`         // "normal" | "express"
+	CallbackURL string     `json:"callback_url"` // optional per-message status webhook
+	SendAt      *time.Time `json:"send_at"`      // optional RFC3339 release time
+	ClientRef   string     `json:"client_ref"`   // optional idempotency key (or Idempotency-Key header)
+
+	// template_id + params replace text
+	TemplateID int64             `json:"template_id"`
+	Params     map[string]string `json:"params"`
+}
+
+// clientRefOf returns the idempotency key from the Idempotency-Key header or client_ref field.
+// ok is false when both are set and differ, or the key is too long.
+func clientRefOf(c echo.Context, body string) (string, bool) {
+	header := strings.TrimSpace(c.Request().Header.Get("Idempotency-Key"))
+	body = strings.TrimSpace(body)
+	if header != "" && body != "" && header != body {
+		return "", false
+	}
+	ref := header
+	if ref == "" {
+		ref = body
+	}
+	return ref, len(ref) <= maxClientRefLen
+}
+
+// validSendAt accepts an empty or past send_at (send now) or one within maxScheduleAhead.
+func validSendAt(t *time.Time) bool {
+	return t == nil || t.Before(time.Now().Add(maxScheduleAhead))
+}
+
+// sendAtOf returns the release time for EnqueueOptions (zero when unset).
+func sendAtOf(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
 }
 
-func sendSMSHandler(queueSvc *queue.Service) echo.HandlerFunc {
+func sendSMSHandler(queueSvc *queue.Service, customers repository.CustomersRepository, templates repository.TemplatesRepository, maxSegments int) echo.HandlerFunc {
+	if maxSegments <= 0 {
+		maxSegments = defaultMaxSegments
+	}
 	return func(c echo.Context) error {
 		var req sendReq
 		if err := c.Bind(&req); err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "bad request"})
 		}
 
+		// auth (set by APIKeyMiddleware)
+		custID, ok := middleware.CustomerIDFromCtx(c)
+		if !ok || custID <= 0 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		}
+
+		// Render template first so the rendered text is what gets validated, priced and stored
+		if req.TemplateID > 0 {
+			if strings.TrimSpace(req.Text) != "" {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "text and template_id are mutually exclusive"})
+			}
+			text, err := renderTemplate(c.Request().Context(), templates, custID, req.TemplateID, req.Params)
+			if err != nil {
+				if resp, ok := templateErrorResponse(err); ok {
+					return c.JSON(http.StatusBadRequest, resp)
+				}
+				log.Errorf("render template failed: %v", err)
+
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+			}
+			req.Text = text
+		}
+
 		// Normalize
 		req.Phone = util.NormalizePhone(strings.TrimSpace(req.Phone))
 		req.Text = strings.TrimSpace(req.Text)
@@ -38,8 +110,14 @@ func sendSMSHandler(queueSvc *queue.Service) echo.HandlerFunc {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "bad request"})
 		}
 
-		if utf8.RuneCountInString(req.Text) > 300 {
-			return c.JSON(http.StatusBadRequest, map[string]string{"error": "text too long"})
+		enc, segments := util.CountSegments(req.Text)
+		if segments > maxSegments {
+			return c.JSON(http.StatusBadRequest, map[string]any{
+				"error":        "text too long",
+				"encoding":     enc.String(),
+				"segments":     segments,
+				"max_segments": maxSegments,
+			})
 		}
 
 		typ, ok := model.ParseSMSType(req.Type)
@@ -47,19 +125,44 @@ func sendSMSHandler(queueSvc *queue.Service) echo.HandlerFunc {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid type"})
 		}
 
-		// auth (set by APIKeyMiddleware)
-		custID, ok := middleware.CustomerIDFromCtx(c)
-		if !ok || custID <= 0 {
-			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		req.CallbackURL = strings.TrimSpace(req.CallbackURL)
+		if req.CallbackURL != "" && !validWebhookURL(c.Request().Context(), req.CallbackURL) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid callback_url"})
+		}
+		if req.CallbackURL != "" {
+			if ok, resp := missingSigningSecret(c, customers, custID); ok {
+				return resp
+			}
+		}
+
+		if !validSendAt(req.SendAt) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "send_at too far in the future"})
+		}
+
+		clientRef, ok := clientRefOf(c, req.ClientRef)
+		if !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid idempotency key"})
 		}
 
 		// enqueue (wallet reserve + ledger(reserve) + messages + outbox in one TX)
-		idStr, err := queueSvc.Enqueue(c.Request().Context(), custID, model.SMS{
+		acc, err := queueSvc.Enqueue(c.Request().Context(), custID, model.SMS{
 			Phone: req.Phone,
 			Text:  req.Text,
 			Type:  typ,
-		})
+		}, queue.EnqueueOptions{CallbackURL: req.CallbackURL, SendAt: sendAtOf(req.SendAt), ClientRef: clientRef})
 		if err != nil {
+			if errors.Is(err, queue.ErrIdempotencyConflict) {
+				return c.JSON(http.StatusConflict, map[string]string{
+					"error":       "idempotency_conflict",
+					"description": "idempotency key was already used with a different request",
+				})
+			}
+			if ok, resp := capExceeded(c, err); ok {
+				return resp
+			}
+			if errors.Is(err, pricing.ErrUnavailable) {
+				return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "pricing unavailable"})
+			}
 			if errors.Is(err, queue.ErrInsufficientFunds) {
 				return c.JSON(http.StatusPaymentRequired, map[string]any{
 					"error":       "insufficient_funds",
@@ -74,13 +177,27 @@ func sendSMSHandler(queueSvc *queue.Service) echo.HandlerFunc {
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
 		}
 
-		metrics.MessagesTotal.WithLabelValues("enqueued", typ.String()).Inc()
+		if !acc.Replayed {
+			metrics.MessagesTotal.WithLabelValues("enqueued", typ.String()).Inc()
+		}
 
-		return c.JSON(http.StatusAccepted, map[string]any{
+		// built from the stored message only, so a replay gets the original answer
+		resp := map[string]any{
 			"enqueued":    true,
-			"id":          idStr,
-			"type":        typ.String(),
+			"id":          acc.ID,
+			"type":        acc.Type.String(),
+			"encoding":    acc.Encoding.String(),
+			"segments":    acc.Segments,
+			"price":       acc.Price,
 			"customer_id": strconv.FormatInt(custID, 10),
-		})
+		}
+		if acc.Status == model.StatusScheduled {
+			resp["scheduled"] = true
+			resp["send_at"] = acc.SendAt.UTC()
+		}
+		if clientRef != "" {
+			resp["client_ref"] = clientRef
+		}
+		return c.JSON(http.StatusAccepted, resp)
 	}
 }
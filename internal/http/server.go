@@ -3,14 +3,21 @@ package http
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
-	"time"
+	"strings"
 
 	"github.com/jmehdipour/sms-gateway/internal/config"
+	"github.com/jmehdipour/sms-gateway/internal/dlr"
 	"github.com/jmehdipour/sms-gateway/internal/http/middleware"
 	"github.com/jmehdipour/sms-gateway/internal/metrics"
+	"github.com/jmehdipour/sms-gateway/internal/model"
 	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmehdipour/sms-gateway/internal/service/authcache"
+	"github.com/jmehdipour/sms-gateway/internal/service/customers"
+	"github.com/jmehdipour/sms-gateway/internal/service/pricing"
 	"github.com/jmehdipour/sms-gateway/internal/service/queue"
+	"github.com/jmehdipour/sms-gateway/internal/service/routing"
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
 	echoMid "github.com/labstack/echo/v4/middleware"
@@ -19,7 +26,10 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-type Server struct{ e *echo.Echo }
+type Server struct {
+	e    *echo.Echo
+	stop context.CancelFunc // ends background loops (auth cache invalidations)
+}
 
 func NewServer(cfg config.Config, mysqlDB, clickhouseDB *sqlx.DB, rds *redis.Client) *Server {
 	// repos (MySQL)
@@ -28,24 +38,48 @@ func NewServer(cfg config.Config, mysqlDB, clickhouseDB *sqlx.DB, rds *redis.Cli
 	outboxRepo := repository.NewOutboxRepository(mysqlDB)
 	walletRepo := repository.NewWalletRepository()
 	ledgerRepo := repository.NewLedgerRepository()
+	webhooksRepo := repository.NewWebhooksRepository(mysqlDB)
+	templatesRepo := repository.NewTemplatesRepository(mysqlDB)
+	pricesRepo := repository.NewPricesRepository(mysqlDB)
+	routesRepo := repository.NewRoutesRepository(mysqlDB)
+	walletStmtRepo := repository.NewWalletStatementRepository(mysqlDB)
+	apiKeysRepo := repository.NewAPIKeysRepository(mysqlDB)
+	auditRepo := repository.NewAuditRepository(mysqlDB)
+	usageRepo := repository.NewUsageRepository(mysqlDB)
 
 	// repos (ClickHouse)
 	chMessagesRepo := repository.NewCHMessagesRepository(clickhouseDB)
+	chLedgerRepo := repository.NewCHLedgerRepository(clickhouseDB)
 
 	// services
+	pricer := pricing.New(pricesRepo, rds, cfg.Pricing.Normal, cfg.Pricing.Express, cfg.Pricing.RefreshInterval)
+	router := routing.New(routesRepo, cfg.Dispatcher.Routing.RulesRefreshInterval)
 	queueSvc := queue.New(
 		mysqlDB,
 		messagesRepo,
 		outboxRepo,
 		walletRepo,
 		ledgerRepo,
-		cfg.Pricing.Normal,
-		cfg.Pricing.Express,
+		customersRepo,
+		usageRepo,
+		webhooksRepo,
+		pricer,
 	)
+	authCache := authcache.New(apiKeysRepo, rds, authcache.Options{
+		Size:        cfg.Auth.CacheSize,
+		TTL:         cfg.Auth.CacheTTL,
+		NegativeTTL: cfg.Auth.NegativeTTL,
+	})
+	customersSvc := customers.New(mysqlDB, customersRepo, walletRepo, apiKeysRepo, auditRepo, authCache)
+
+	bg, stop := context.WithCancel(context.Background())
+	go authCache.Run(bg)
+	go pricer.Run(bg)
 
 	// echo
 	e := echo.New()
 	e.HideBanner = true
+	e.IPExtractor = ipExtractor(cfg.HTTP.TrustedProxies)
 	e.Use(echoMid.Recover(), echoMid.Logger())
 
 	metrics.MustRegister(prometheus.DefaultRegisterer)
@@ -56,26 +90,115 @@ func NewServer(cfg config.Config, mysqlDB, clickhouseDB *sqlx.DB, rds *redis.Cli
 	e.GET("/healthz", func(c echo.Context) error { return c.String(http.StatusOK, "ok") })
 
 	// middlewares
-	authMW := middleware.APIKeyMiddleware(customersRepo)
-	rlMW := middleware.RateLimitMiddleware(middleware.RateLimitConfig{
-		Redis:          rds,
-		DefaultRPS:     cfg.RateLimit.RPS,
-		KeyPrefix:      "rl:cust:",
-		Window:         time.Second,
-		RetryAfterHint: true,
-	})
+	authMW := middleware.APIKeyMiddleware(authCache)
+	rl := middleware.RateLimitConfig{
+		Redis:     rds,
+		Default:   middleware.Rate{RPS: cfg.RateLimit.RPS, Burst: cfg.RateLimit.Burst},
+		KeyPrefix: "rl:cust:",
+	}
+	routeRate := func(r config.RouteRate) middleware.Rate { return middleware.Rate{RPS: r.RPS, Burst: r.Burst} }
+
+	// route groups: the API key scope they need and their own rate limit bucket
+	send := []echo.MiddlewareFunc{
+		middleware.RequireScope(model.ScopeSMSSend),
+		middleware.RateLimitMiddleware(rl, "send", routeRate(cfg.RateLimit.Routes.Send)),
+	}
+	topup := []echo.MiddlewareFunc{
+		middleware.RequireScope(model.ScopeWalletTopup),
+		middleware.RateLimitMiddleware(rl, "topup", routeRate(cfg.RateLimit.Routes.Topup)),
+	}
+	read := []echo.MiddlewareFunc{
+		middleware.RequireScope(model.ScopeReportsRead),
+		middleware.RateLimitMiddleware(rl, "reports", routeRate(cfg.RateLimit.Routes.Reports)),
+	}
 
-	// routes
-	v1 := e.Group("/v1", authMW, rlMW)
-	v1.POST("/sms/send", sendSMSHandler(queueSvc))
-	v1.GET("/reports/messages", listMessagesHandler(chMessagesRepo))
-	v1.POST("/wallet/topup", TopupHandler(mysqlDB, walletRepo, ledgerRepo))
+	v1 := e.Group("/v1", authMW)
+	v1.POST("/sms/send", sendSMSHandler(queueSvc, customersRepo, templatesRepo, cfg.HTTP.MaxSegments), send...)
+	v1.POST("/sms/bulk", sendBulkHandler(queueSvc, customersRepo, templatesRepo, cfg.HTTP.BulkMaxRecipients, cfg.HTTP.MaxSegments), send...)
+	v1.GET("/sms", lookupMessagesHandler(messagesRepo), read...)
+	v1.GET("/sms/scheduled", listScheduledHandler(messagesRepo), read...)
+	v1.DELETE("/sms/scheduled/:id", cancelScheduledHandler(queueSvc), send...)
+	v1.GET("/sms/:id", getMessageHandler(messagesRepo), read...)
+	v1.GET("/reports/messages", listMessagesHandler(chMessagesRepo), read...)
+	v1.GET("/wallet", getWalletHandler(walletStmtRepo), read...)
+	v1.GET("/usage", getUsageHandler(customersRepo, usageRepo), read...)
+	v1.GET("/wallet/ledger", listLedgerHandler(walletStmtRepo, chLedgerRepo, cfg.Wallet.LedgerHotWindow), read...)
+	v1.POST("/wallet/topup", TopupHandler(mysqlDB, walletRepo, ledgerRepo), topup...)
+	v1.POST("/templates", createTemplateHandler(templatesRepo), send...)
+	v1.GET("/templates", listTemplatesHandler(templatesRepo), read...)
+	v1.GET("/templates/:id", getTemplateHandler(templatesRepo), read...)
+	v1.PUT("/templates/:id", updateTemplateHandler(templatesRepo), send...)
+	v1.DELETE("/templates/:id", deleteTemplateHandler(templatesRepo), send...)
+	v1.GET("/webhook", getWebhookHandler(customersRepo), read...)
+	v1.PUT("/webhook", putWebhookHandler(customersRepo), send...)
+	v1.DELETE("/webhook", deleteWebhookHandler(customersRepo), send...)
+	v1.GET("/webhook/deliveries", listWebhookDeliveriesHandler(webhooksRepo), read...)
 
-	return &Server{e: e}
+	// operator API (disabled unless admin.token is set)
+	if cfg.Admin.Token != "" {
+		admin := e.Group("/admin/v1", middleware.AdminTokenMiddleware(cfg.Admin.Token))
+		admin.GET("/prices", listPricesHandler(pricesRepo))
+		admin.PUT("/prices", putPriceHandler(pricesRepo, pricer))
+		admin.DELETE("/prices/:id", deletePriceHandler(pricesRepo, pricer))
+		admin.GET("/routes", listRoutesHandler(routesRepo))
+		admin.PUT("/routes", putRouteHandler(routesRepo, router, cfg))
+		admin.GET("/routes/dry-run", dryRunRouteHandler(router, cfg))
+		admin.DELETE("/routes/:id", deleteRouteHandler(routesRepo, router))
+		admin.POST("/customers", createCustomerHandler(customersSvc))
+		admin.GET("/customers", listCustomersHandler(customersRepo))
+		admin.GET("/customers/:id", getCustomerHandler(customersRepo, walletStmtRepo, usageRepo))
+		admin.PATCH("/customers/:id", updateCustomerHandler(customersSvc))
+		admin.POST("/customers/:id/suspend", suspendCustomerHandler(customersSvc))
+		admin.POST("/customers/:id/reactivate", reactivateCustomerHandler(customersSvc))
+		admin.GET("/customers/:id/api-keys", listKeysHandler(customersSvc))
+		admin.POST("/customers/:id/api-keys", createKeyHandler(customersSvc))
+		admin.POST("/customers/:id/api-keys/:key_id/rotate", rotateKeyHandler(customersSvc))
+		admin.DELETE("/customers/:id/api-keys/:key_id", revokeKeyHandler(customersSvc))
+		admin.GET("/customers/:id/audit", listAuditHandler(auditRepo))
+	}
+
+	// provider callbacks (authenticated per provider by dlr_token)
+	dlrRoutes := make(map[string]dlrRoute, len(cfg.Providers))
+	for _, pc := range cfg.Providers {
+		if !pc.Enabled {
+			continue
+		}
+		name := strings.ToLower(pc.Name)
+		kind := strings.ToLower(strings.TrimSpace(pc.Kind))
+		if kind == "" {
+			kind = name // providers configured before kinds existed were parsed by name
+		}
+		dlrRoutes[name] = dlrRoute{token: pc.DLRToken, kind: kind}
+	}
+	e.POST("/callbacks/dlr/:provider", dlrCallbackHandler(dlr.NewApplier(mysqlDB, messagesRepo, webhooksRepo), dlrRoutes))
+
+	return &Server{e: e, stop: stop}
+}
+
+// ipExtractor decides what c.RealIP() returns, which API key allowlists and the audit log
+// rely on. X-Forwarded-For is only believed when it was appended by a trusted proxy;
+// without any configured, the peer address is used.
+func ipExtractor(trusted []string) echo.IPExtractor {
+	if len(trusted) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	opts := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, cidr := range trusted {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Printf("http: ignoring invalid trusted proxy %q: %v", cidr, err)
+			continue
+		}
+		opts = append(opts, echo.TrustIPRange(ipNet))
+	}
+	return echo.ExtractIPFromXFFHeader(opts...)
 }
 
 func (s *Server) Start(addr string) error {
 	log.Printf("http: listening on %s", addr)
 	return s.e.Start(addr)
 }
-func (s *Server) Shutdown(ctx context.Context) error { return s.e.Shutdown(ctx) }
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.stop()
+	return s.e.Shutdown(ctx)
+}
@@ -0,0 +1,223 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jmehdipour/sms-gateway/internal/http/middleware"
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	echo "github.com/labstack/echo/v4"
+)
+
+var errTemplateNotFound = errors.New("template not found")
+
+// maxTemplateRunes bounds the raw template body; the rendered text is limited by segments.
+const maxTemplateRunes = 1000
+
+type templateReq struct {
+	Name string `json:"name"`
+	Body string `json:"body"`
+}
+
+// validate trims and checks name/body; returns an error message or "".
+func (r *templateReq) validate() string {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Body = strings.TrimSpace(r.Body)
+	switch {
+	case r.Name == "" || utf8.RuneCountInString(r.Name) > 120:
+		return "invalid name"
+	case r.Body == "" || utf8.RuneCountInString(r.Body) > maxTemplateRunes:
+		return "invalid body"
+	}
+	return ""
+}
+
+func templateJSON(t model.Template) map[string]any {
+	return map[string]any{
+		"id":           t.ID,
+		"name":         t.Name,
+		"body":         t.Body,
+		"placeholders": t.Placeholders(),
+		"created_at":   t.CreatedAt,
+		"updated_at":   t.UpdatedAt,
+	}
+}
+
+// renderTemplate loads a customer's template and renders it with params.
+func renderTemplate(ctx context.Context, templates repository.TemplatesRepository, customerID, id int64, params map[string]string) (string, error) {
+	t, err := templates.Get(ctx, customerID, id)
+	if err != nil {
+		return "", err
+	}
+	if t == nil {
+		return "", errTemplateNotFound
+	}
+	return t.Render(params)
+}
+
+// templateErrorResponse maps client-side render errors to a 400 body.
+func templateErrorResponse(err error) (map[string]string, bool) {
+	switch {
+	case errors.Is(err, errTemplateNotFound):
+		return map[string]string{"error": "template not found"}, true
+	case errors.Is(err, model.ErrMissingParams):
+		return map[string]string{"error": "missing params", "description": err.Error()}, true
+	}
+	return nil, false
+}
+
+func createTemplateHandler(templates repository.TemplatesRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		custID, ok := middleware.CustomerIDFromCtx(c)
+		if !ok || custID <= 0 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		}
+
+		var req templateReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "bad request"})
+		}
+		if msg := req.validate(); msg != "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": msg})
+		}
+
+		id, err := templates.Create(c.Request().Context(), model.Template{CustomerID: custID, Name: req.Name, Body: req.Body})
+		if err != nil {
+			if errors.Is(err, repository.ErrDuplicate) {
+				return c.JSON(http.StatusConflict, map[string]string{"error": "template name already exists"})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+
+		t, err := templates.Get(c.Request().Context(), custID, id)
+		if err != nil || t == nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+		return c.JSON(http.StatusCreated, templateJSON(*t))
+	}
+}
+
+func listTemplatesHandler(templates repository.TemplatesRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		custID, ok := middleware.CustomerIDFromCtx(c)
+		if !ok || custID <= 0 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		}
+
+		limit := 50
+		offset := 0
+		if v := c.QueryParam("limit"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 1000 {
+				limit = n
+			}
+		}
+		if v := c.QueryParam("offset"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+				offset = n
+			}
+		}
+
+		rows, err := templates.List(c.Request().Context(), custID, limit, offset)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "query failed"})
+		}
+
+		results := make([]map[string]any, 0, len(rows))
+		for _, t := range rows {
+			results = append(results, templateJSON(t))
+		}
+		return c.JSON(http.StatusOK, map[string]any{
+			"limit":   limit,
+			"offset":  offset,
+			"count":   len(results),
+			"results": results,
+		})
+	}
+}
+
+func getTemplateHandler(templates repository.TemplatesRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		custID, ok := middleware.CustomerIDFromCtx(c)
+		if !ok || custID <= 0 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		}
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil || id <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "bad request"})
+		}
+
+		t, err := templates.Get(c.Request().Context(), custID, id)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+		if t == nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "not found"})
+		}
+		return c.JSON(http.StatusOK, templateJSON(*t))
+	}
+}
+
+func updateTemplateHandler(templates repository.TemplatesRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		custID, ok := middleware.CustomerIDFromCtx(c)
+		if !ok || custID <= 0 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		}
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil || id <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "bad request"})
+		}
+
+		var req templateReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "bad request"})
+		}
+		if msg := req.validate(); msg != "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": msg})
+		}
+
+		found, err := templates.Update(c.Request().Context(), model.Template{ID: id, CustomerID: custID, Name: req.Name, Body: req.Body})
+		if err != nil {
+			if errors.Is(err, repository.ErrDuplicate) {
+				return c.JSON(http.StatusConflict, map[string]string{"error": "template name already exists"})
+			}
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+		if !found {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "not found"})
+		}
+
+		t, err := templates.Get(c.Request().Context(), custID, id)
+		if err != nil || t == nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+		return c.JSON(http.StatusOK, templateJSON(*t))
+	}
+}
+
+func deleteTemplateHandler(templates repository.TemplatesRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		custID, ok := middleware.CustomerIDFromCtx(c)
+		if !ok || custID <= 0 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		}
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil || id <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "bad request"})
+		}
+
+		found, err := templates.Delete(c.Request().Context(), custID, id)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+		if !found {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "not found"})
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,92 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/http/middleware"
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmehdipour/sms-gateway/internal/service/queue"
+	echo "github.com/labstack/echo/v4"
+)
+
+// capExceeded answers a send rejected by a customer cap with 429 cap_exceeded, or returns
+// false when err is something else.
+func capExceeded(c echo.Context, err error) (bool, error) {
+	var ce *queue.CapError
+	if !errors.As(err, &ce) {
+		return false, nil
+	}
+	retry := int64(time.Until(ce.ResetsAt).Seconds()) + 1
+	c.Response().Header().Set("Retry-After", strconv.FormatInt(retry, 10))
+	return true, c.JSON(http.StatusTooManyRequests, map[string]any{
+		"error":       "cap_exceeded",
+		"description": "the request would exceed the account's " + ce.Cap + " cap",
+		"cap":         ce.Cap,
+		"limit":       ce.Limit,
+		"used":        ce.Used,
+		"resets_at":   ce.ResetsAt.UTC(),
+	})
+}
+
+// usageJSON is the customer's current usage against each cap; a nil cap is unlimited.
+func usageJSON(cust model.Customer, u model.Usage, p model.Periods) map[string]any {
+	counter := func(used int64, cap *int64) map[string]any {
+		return map[string]any{"used": used, "cap": cap}
+	}
+	return map[string]any{
+		"timezone": model.LocationOf(cust.Timezone).String(),
+		"day": map[string]any{
+			"start":     p.Day,
+			"resets_at": p.DayEnds.UTC(),
+			"messages":  counter(u.DayMessages, cust.DailyMessages),
+			"spend":     counter(u.DaySpend, cust.DailySpend),
+		},
+		"month": map[string]any{
+			"start":     p.Month,
+			"resets_at": p.MonthEnds.UTC(),
+			"messages":  counter(u.MonthMessages, cust.MonthlyMessages),
+			"spend":     counter(u.MonthSpend, cust.MonthlySpend),
+		},
+	}
+}
+
+// loadUsage reads a customer and its usage in the current periods; nil customer = not found.
+func loadUsage(c echo.Context, customers repository.CustomersRepository, usage repository.UsageRepository, id int64) (*model.Customer, map[string]any, error) {
+	ctx := c.Request().Context()
+	cust, err := customers.GetByID(ctx, id)
+	if err != nil || cust == nil {
+		return nil, nil, err
+	}
+	p := model.PeriodsAt(model.LocationOf(cust.Timezone), time.Now())
+	u, err := usage.Get(ctx, nil, id, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cust, usageJSON(*cust, u, p), nil
+}
+
+// getUsageHandler returns today's and this month's usage against the account caps (GET /v1/usage).
+// Days and months follow the account timezone.
+func getUsageHandler(customers repository.CustomersRepository, usage repository.UsageRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		custID, ok := middleware.CustomerIDFromCtx(c)
+		if !ok || custID <= 0 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		}
+
+		cust, out, err := loadUsage(c, customers, usage, custID)
+		if err != nil {
+			c.Logger().Errorf("get usage failed: %v", err)
+
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "query failed"})
+		}
+		if cust == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		}
+		return c.JSON(http.StatusOK, out)
+	}
+}
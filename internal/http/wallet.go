@@ -0,0 +1,155 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/http/middleware"
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	echo "github.com/labstack/echo/v4"
+)
+
+// getWalletHandler returns the customer's balance and reserved amount (GET /v1/wallet).
+func getWalletHandler(stmts repository.WalletStatementRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		custID, ok := middleware.CustomerIDFromCtx(c)
+		if !ok || custID <= 0 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		}
+
+		acc, err := stmts.GetAccount(c.Request().Context(), custID)
+		if err != nil {
+			c.Logger().Errorf("get wallet failed: %v", err)
+
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "query failed"})
+		}
+		if acc == nil {
+			acc = &model.WalletAccount{CustomerID: custID}
+		}
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"customer_id": strconv.FormatInt(custID, 10),
+			"balance":     acc.Balance,
+			"reserved":    acc.Reserved,
+			"updated_at":  acc.UpdatedAt,
+		})
+	}
+}
+
+// parseTimeParam reads an optional RFC3339 query parameter.
+func parseTimeParam(c echo.Context, name string) (time.Time, bool) {
+	v := strings.TrimSpace(c.QueryParam(name))
+	if v == "" {
+		return time.Time{}, true
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	return t, err == nil
+}
+
+// listLedgerHandler pages through the customer's ledger newest first (GET /v1/wallet/ledger).
+// Pagination is keyset on the ledger id: pass next_cursor back as cursor. Entries newer than
+// hotWindow come from MySQL, older ones from ClickHouse; one page may span both.
+func listLedgerHandler(stmts repository.WalletStatementRepository, chLedger repository.CHLedgerRepository, hotWindow time.Duration) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		custID, ok := middleware.CustomerIDFromCtx(c)
+		if !ok || custID <= 0 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		}
+
+		limit := 50
+		if v := c.QueryParam("limit"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 500 {
+				limit = n
+			}
+		}
+
+		f := repository.LedgerFilter{CustomerID: custID, Limit: limit + 1}
+		if v := strings.TrimSpace(c.QueryParam("cursor")); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || id <= 0 {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid cursor"})
+			}
+			f.BeforeID = id
+		}
+		switch op := strings.TrimSpace(c.QueryParam("op")); op {
+		case "", "topup", "reserve", "capture", "refund":
+			f.Op = op
+		default:
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid op"})
+		}
+		if f.From, ok = parseTimeParam(c, "from"); !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid from"})
+		}
+		if f.To, ok = parseTimeParam(c, "to"); !ok {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid to"})
+		}
+
+		var boundary time.Time
+		if hotWindow > 0 {
+			boundary = time.Now().Add(-hotWindow)
+		}
+
+		var rows []model.LedgerEntry
+
+		// hot part: MySQL serves [max(from, boundary), to)
+		if boundary.IsZero() || f.To.IsZero() || f.To.After(boundary) {
+			hot := f
+			if !boundary.IsZero() && hot.From.Before(boundary) {
+				hot.From = boundary
+			}
+			res, err := stmts.ListLedger(c.Request().Context(), hot)
+			if err != nil {
+				c.Logger().Errorf("list ledger failed: %v", err)
+
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "query failed"})
+			}
+			rows = res
+		}
+
+		// cold part: ClickHouse serves [from, min(to, boundary)) once MySQL is exhausted
+		if !boundary.IsZero() && len(rows) < f.Limit && (f.From.IsZero() || f.From.Before(boundary)) {
+			cold := f
+			if cold.To.IsZero() || cold.To.After(boundary) {
+				cold.To = boundary
+			}
+			if n := len(rows); n > 0 {
+				cold.BeforeID = rows[n-1].ID
+			}
+			cold.Limit = f.Limit - len(rows)
+			res, err := chLedger.ListLedger(c.Request().Context(), cold)
+			if err != nil {
+				c.Logger().Errorf("clickhouse ledger failed: %v", err)
+
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "query failed"})
+			}
+			rows = append(rows, res...)
+		}
+
+		var next string
+		if len(rows) > limit {
+			rows = rows[:limit]
+			next = strconv.FormatInt(rows[limit-1].ID, 10)
+		}
+
+		results := make([]map[string]any, 0, len(rows))
+		for _, e := range rows {
+			results = append(results, map[string]any{
+				"id":         e.ID,
+				"op":         e.Op,
+				"amount":     e.Amount,
+				"message_id": e.MessageID,
+				"created_at": e.CreatedAt,
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"limit":       limit,
+			"count":       len(results),
+			"results":     results,
+			"next_cursor": next,
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/http/middleware"
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmehdipour/sms-gateway/internal/util"
+	echo "github.com/labstack/echo/v4"
+)
+
+type webhookReq struct {
+	URL          string `json:"url"`
+	RotateSecret bool   `json:"rotate_secret"`
+}
+
+// validWebhookURL accepts absolute https URLs that fit the url columns and whose host
+// resolves only to public addresses, so customers cannot aim the webhook worker at the
+// cluster's own network. The worker checks the dialed address again on every delivery.
+func validWebhookURL(ctx context.Context, raw string) bool {
+	if raw == "" || len(raw) > 512 {
+		return false
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	return util.ResolvesPublic(ctx, u.Hostname())
+}
+
+// missingSigningSecret answers 400 when the customer has no webhook secret. Per-message
+// callbacks are signed with the account webhook's secret and are never delivered unsigned.
+func missingSigningSecret(c echo.Context, customers repository.CustomersRepository, custID int64) (bool, error) {
+	cu, err := customers.GetByID(c.Request().Context(), custID)
+	if err != nil || cu == nil {
+		return true, c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+	}
+	if cu.WebhookSecret == nil || *cu.WebhookSecret == "" {
+		return true, c.JSON(http.StatusBadRequest, map[string]string{
+			"error":       "webhook_secret_required",
+			"description": "set the account webhook (PUT /v1/webhook) to get a signing secret before using callback_url",
+		})
+	}
+	return false, nil
+}
+
+// getWebhookHandler returns the account webhook (the secret is only shown when set or rotated).
+func getWebhookHandler(customers repository.CustomersRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		custID, ok := middleware.CustomerIDFromCtx(c)
+		if !ok || custID <= 0 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		}
+
+		cu, err := customers.GetByID(c.Request().Context(), custID)
+		if err != nil || cu == nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"url":        cu.WebhookURL,
+			"has_secret": cu.WebhookSecret != nil && *cu.WebhookSecret != "",
+		})
+	}
+}
+
+// putWebhookHandler sets the account webhook URL; a secret is generated on first set or on rotate.
+func putWebhookHandler(customers repository.CustomersRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		custID, ok := middleware.CustomerIDFromCtx(c)
+		if !ok || custID <= 0 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		}
+
+		var req webhookReq
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "bad request"})
+		}
+		req.URL = strings.TrimSpace(req.URL)
+		if !validWebhookURL(c.Request().Context(), req.URL) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid url"})
+		}
+
+		cu, err := customers.GetByID(c.Request().Context(), custID)
+		if err != nil || cu == nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+
+		secret := cu.WebhookSecret
+		issued := false
+		if secret == nil || *secret == "" || req.RotateSecret {
+			s := util.NewSecret(32)
+			secret = &s
+			issued = true
+		}
+
+		if err := customers.SetWebhook(c.Request().Context(), custID, &req.URL, secret); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+
+		resp := map[string]any{"url": req.URL, "has_secret": true}
+		if issued {
+			resp["secret"] = *secret
+		}
+		return c.JSON(http.StatusOK, resp)
+	}
+}
+
+func deleteWebhookHandler(customers repository.CustomersRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		custID, ok := middleware.CustomerIDFromCtx(c)
+		if !ok || custID <= 0 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		}
+
+		if err := customers.SetWebhook(c.Request().Context(), custID, nil, nil); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "db error"})
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// listWebhookDeliveriesHandler lists recent deliveries with every attempt and its response.
+func listWebhookDeliveriesHandler(webhooks repository.WebhooksRepository) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		custID, ok := middleware.CustomerIDFromCtx(c)
+		if !ok || custID <= 0 {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		}
+
+		limit := 50
+		if v := c.QueryParam("limit"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 200 {
+				limit = n
+			}
+		}
+		messageID := strings.TrimSpace(c.QueryParam("message_id"))
+
+		ctx := c.Request().Context()
+		deliveries, err := webhooks.ListByCustomer(ctx, custID, messageID, limit)
+		if err != nil {
+			c.Logger().Errorf("list webhook deliveries failed: %v", err)
+
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "query failed"})
+		}
+
+		ids := make([]int64, 0, len(deliveries))
+		for _, d := range deliveries {
+			ids = append(ids, d.ID)
+		}
+		attempts, err := webhooks.ListAttempts(ctx, ids)
+		if err != nil {
+			c.Logger().Errorf("list webhook attempts failed: %v", err)
+
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "query failed"})
+		}
+		byDelivery := make(map[int64][]model.WebhookAttempt, len(deliveries))
+		for _, a := range attempts {
+			byDelivery[a.DeliveryID] = append(byDelivery[a.DeliveryID], a)
+		}
+
+		results := make([]map[string]any, 0, len(deliveries))
+		for _, d := range deliveries {
+			atts := make([]map[string]any, 0, len(byDelivery[d.ID]))
+			for _, a := range byDelivery[d.ID] {
+				atts = append(atts, map[string]any{
+					"attempt":       a.Attempt,
+					"response_code": a.ResponseCode,
+					"response_body": a.ResponseBody,
+					"error":         a.Error,
+					"duration_ms":   a.DurationMs,
+					"at":            a.CreatedAt,
+				})
+			}
+			results = append(results, map[string]any{
+				"id":              d.ID,
+				"message_id":      d.MessageID,
+				"event":           d.Event,
+				"url":             d.URL,
+				"state":           d.State,
+				"attempts":        d.Attempts,
+				"next_attempt_at": d.NextAttemptAt,
+				"created_at":      d.CreatedAt,
+				"history":         atts,
+			})
+		}
+
+		return c.JSON(http.StatusOK, map[string]any{
+			"limit":   limit,
+			"count":   len(results),
+			"results": results,
+		})
+	}
+}
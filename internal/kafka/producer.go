@@ -0,0 +1,35 @@
+package kafka
+
+import (
+	"context"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Producer is a thin wrapper around segmentio/kafka-go Writer; the topic is chosen per message.
+type Producer struct {
+	w *kafka.Writer
+}
+
+func NewProducer(brokers []string) *Producer {
+	return &Producer{w: &kafka.Writer{
+		Addr:                   kafka.TCP(brokers...),
+		Balancer:               &kafka.Hash{}, // same key → same partition
+		RequiredAcks:           kafka.RequireAll,
+		BatchTimeout:           10 * time.Millisecond,
+		AllowAutoTopicCreation: true,
+	}}
+}
+
+// Publish writes one message and returns once the broker acknowledged it.
+func (p *Producer) Publish(ctx context.Context, topic string, key, value []byte) error {
+	return p.w.WriteMessages(ctx, kafka.Message{Topic: topic, Key: key, Value: value})
+}
+
+// PublishBatch writes msgs (each with its Topic set) in one call; order is kept per partition.
+func (p *Producer) PublishBatch(ctx context.Context, msgs ...Message) error {
+	return p.w.WriteMessages(ctx, msgs...)
+}
+
+func (p *Producer) Close() error { return p.w.Close() }
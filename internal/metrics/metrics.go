@@ -12,10 +12,135 @@ var (
 		},
 		[]string{"stage", "lane"}, // queued|sent|failed , normal|express
 	)
+
+	WebhookDeliveriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "smsgw_webhook_deliveries_total",
+			Help: "Customer webhook delivery attempts by result",
+		},
+		[]string{"result"}, // delivered|retry|failed
+	)
+
+	DeadLettersTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "smsgw_dead_letters_total",
+			Help: "Kafka records parked on the dead-letter topic by source topic",
+		},
+		[]string{"topic"},
+	)
+
+	ReceiptsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "smsgw_dlr_receipts_total",
+			Help: "Delivery receipts by provider and outcome",
+		},
+		[]string{"provider", "outcome"}, // applied|ignored|pending|unmatched
+	)
+
+	OutboxPublishedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "smsgw_outbox_published_total",
+			Help: "Outbox events handled by the relay by result",
+		},
+		[]string{"result"}, // published|failed
+	)
+
+	OutboxLagEvents = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "smsgw_outbox_lag_events",
+			Help: "Outbox events not yet published",
+		},
+	)
+
+	OutboxLagSeconds = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "smsgw_outbox_lag_seconds",
+			Help: "Age of the oldest unpublished outbox event",
+		},
+	)
+
+	RoutingDecisionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "smsgw_routing_decisions_total",
+			Help: "Providers chosen by the dispatcher by lane and routing strategy",
+		},
+		[]string{"lane", "strategy", "provider"},
+	)
+
+	ProviderAttemptsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "smsgw_provider_attempts_total",
+			Help: "Send attempts per provider by outcome",
+		},
+		[]string{"provider", "lane", "result"}, // ok|transient|rejected|auth
+	)
+
+	ProviderThrottledTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "smsgw_provider_throttled_total",
+			Help: "Send attempts held back by a provider's shared limits",
+		},
+		[]string{"provider", "reason"}, // tps|concurrency
+	)
+
+	BreakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "smsgw_breaker_state",
+			Help: "Provider circuit breaker state (0 closed, 1 open, 2 half-open)",
+		},
+		[]string{"provider"},
+	)
+
+	BreakerTransitionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "smsgw_breaker_transitions_total",
+			Help: "Provider circuit breaker state changes by target state",
+		},
+		[]string{"provider", "to"}, // closed|open|half_open
+	)
+
+	AuthCacheRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "smsgw_auth_cache_requests_total",
+			Help: "API key lookups by cache result",
+		},
+		[]string{"result"}, // hit|negative_hit|miss
+	)
+
+	RateLimitedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "smsgw_rate_limited_total",
+			Help: "API requests rejected by the per-customer rate limiter by route group",
+		},
+		[]string{"group"}, // send|topup|reports
+	)
+
+	ProviderLatencySeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "smsgw_provider_latency_seconds",
+			Help:    "Provider send latency",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10},
+		},
+		[]string{"provider", "lane"},
+	)
 )
 
 func MustRegister(r prometheus.Registerer) {
 	r.MustRegister(
 		MessagesTotal,
+		WebhookDeliveriesTotal,
+		DeadLettersTotal,
+		ReceiptsTotal,
+		OutboxPublishedTotal,
+		OutboxLagEvents,
+		OutboxLagSeconds,
+		RoutingDecisionsTotal,
+		ProviderAttemptsTotal,
+		ProviderThrottledTotal,
+		ProviderLatencySeconds,
+		BreakerState,
+		BreakerTransitionsTotal,
+		AuthCacheRequestsTotal,
+		RateLimitedTotal,
 	)
 }
@@ -0,0 +1,110 @@
+package model
+
+import (
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// API key scopes. Each /v1 route requires one of them.
+const (
+	ScopeSMSSend     = "sms:send"     // send, cancel, templates and webhook settings
+	ScopeWalletTopup = "wallet:topup" // POST /v1/wallet/topup
+	ScopeReportsRead = "reports:read" // message lookups, reports, wallet statements
+)
+
+// AllScopes is what a key gets when none are requested.
+var AllScopes = []string{ScopeSMSSend, ScopeWalletTopup, ScopeReportsRead}
+
+// ValidScope reports whether s is a known scope.
+func ValidScope(s string) bool {
+	for _, v := range AllScopes {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey is one customer credential. The secret itself is never stored, only its hash.
+type APIKey struct {
+	ID         int64      `db:"id"`
+	CustomerID int64      `db:"customer_id"`
+	Prefix     string     `db:"prefix"`      // visible part of the key, e.g. sk_3f9a0c12be47
+	SecretHash string     `db:"secret_hash"` // SHA-256 hex of the full key
+	Label      string     `db:"label"`
+	Scopes     string     `db:"scopes"`      // comma-separated
+	AllowedIPs *string    `db:"allowed_ips"` // comma-separated CIDRs; nil = any
+	ExpiresAt  *time.Time `db:"expires_at"`
+	LastUsedAt *time.Time `db:"last_used_at"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+	CreatedAt  time.Time  `db:"created_at"`
+}
+
+// ScopeList splits Scopes.
+func (k APIKey) ScopeList() []string {
+	return splitList(k.Scopes)
+}
+
+// AllowedIPList splits AllowedIPs; empty means any address.
+func (k APIKey) AllowedIPList() []string {
+	if k.AllowedIPs == nil {
+		return nil
+	}
+	return splitList(*k.AllowedIPs)
+}
+
+// HasScope reports whether the key grants scope.
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Usable reports whether the key is neither revoked nor expired at now.
+func (k APIKey) Usable(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// AllowsIP reports whether ip falls in the allowlist. Keys without one accept any address.
+func (k APIKey) AllowsIP(ip string) bool {
+	list := k.AllowedIPList()
+	if len(list) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, s := range list {
+		if p, err := netip.ParsePrefix(s); err == nil && p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Credential is an API key joined with what authentication needs from its customer.
+type Credential struct {
+	APIKey
+	CustomerStatus string `db:"customer_status"`
+	RateLimitRPS   *int   `db:"rate_limit_rps"`
+	RateLimitBurst *int   `db:"rate_limit_burst"`
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
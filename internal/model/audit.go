@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// AuditEntry records one operator change made through the admin API.
+type AuditEntry struct {
+	ID         int64     `db:"id"`
+	Actor      string    `db:"actor"`       // X-Admin-Actor of the operator
+	RemoteIP   string    `db:"remote_ip"`   // where the request came from
+	Action     string    `db:"action"`      // e.g. customer.create, customer.suspend
+	CustomerID *int64    `db:"customer_id"` // affected customer, if any
+	Details    *string   `db:"details"`     // JSON: changed fields as {"field": {"from": .., "to": ..}}
+	CreatedAt  time.Time `db:"created_at"`
+}
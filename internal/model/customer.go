@@ -3,11 +3,15 @@ package model
 import "time"
 
 type Customer struct {
-	ID           int64     `db:"id"`
-	Name         string    `db:"name"`
-	APIKey       string    `db:"api_key"`
-	Status       string    `db:"status"`         // active|suspended
-	RateLimitRPS *int      `db:"rate_limit_rps"` // nullable
-	CreatedAt    time.Time `db:"created_at"`
-	UpdatedAt    time.Time `db:"updated_at"`
+	ID             int64   `db:"id"`
+	Name           string  `db:"name"`
+	Status         string  `db:"status"`           // active|suspended
+	RateLimitRPS   *int    `db:"rate_limit_rps"`   // nullable
+	RateLimitBurst *int    `db:"rate_limit_burst"` // nullable; defaults to the rate
+	WebhookURL     *string `db:"webhook_url"`      // account-level status webhook (nullable)
+	WebhookSecret  *string `db:"webhook_secret"`   // HMAC key for webhook signatures (nullable)
+	Timezone       string  `db:"timezone"`         // IANA name; caps reset at its midnight
+	Caps
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
 }
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// DeadLetter wraps a Kafka record that could not be processed, with where it came from.
+// Key and Value are the original bytes so a replay republishes them unchanged.
+type DeadLetter struct {
+	Topic     string    `json:"topic"`
+	Partition int       `json:"partition"`
+	Offset    int64     `json:"offset"`
+	Key       []byte    `json:"key,omitempty"`
+	Value     []byte    `json:"value"`
+	Error     string    `json:"error"`
+	Consumer  string    `json:"consumer"` // group that gave up on it
+	FailedAt  time.Time `json:"failed_at"`
+}
@@ -1,8 +1,18 @@
 package model
 
+import "time"
+
 // Envelope is the payload published to Kafka (via Debezium outbox SMT).
 type Envelope struct {
-	ID     string `json:"id"`      // message ULID
-	UserID int64  `json:"user_id"` // customer id
-	SMS    SMS    `json:"sms"`
+	ID       string `json:"id"`                 // message ULID
+	UserID   int64  `json:"user_id"`            // customer id
+	Segments int    `json:"segments,omitempty"` // SMS parts reserved for (0 = recount from text)
+	Price    int64  `json:"price,omitempty"`    // total amount reserved (0 = reprice at the sender)
+	SMS      SMS    `json:"sms"`
+
+	// Retry state: QueuedAt starts the lane's validity window, Attempt counts failed
+	// dispatch rounds, and NotBefore is when a retry tier may hand the envelope back.
+	QueuedAt  time.Time `json:"queued_at,omitzero"`
+	Attempt   int       `json:"attempt,omitempty"`
+	NotBefore time.Time `json:"not_before,omitzero"`
 }
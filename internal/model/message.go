@@ -5,9 +5,15 @@ import "time"
 type MessageStatus string
 
 const (
-	StatusQueued MessageStatus = "queued"
-	StatusSent   MessageStatus = "sent"
-	StatusFailed MessageStatus = "failed"
+	StatusQueued      MessageStatus = "queued"
+	StatusSent        MessageStatus = "sent"
+	StatusFailed      MessageStatus = "failed"
+	StatusDelivered   MessageStatus = "delivered"
+	StatusUndelivered MessageStatus = "undelivered"
+	StatusExpired     MessageStatus = "expired"
+	StatusScheduled   MessageStatus = "scheduled"
+	StatusCanceled    MessageStatus = "canceled"
+	StatusRetrying    MessageStatus = "retrying" // waiting in a retry tier after a failed dispatch
 )
 
 func (s MessageStatus) String() string {
@@ -15,17 +21,41 @@ func (s MessageStatus) String() string {
 }
 
 func (s MessageStatus) Valid() bool {
-	return s == StatusQueued || s == StatusSent || s == StatusFailed
+	switch s {
+	case StatusQueued, StatusSent, StatusFailed,
+		StatusDelivered, StatusUndelivered, StatusExpired,
+		StatusScheduled, StatusCanceled, StatusRetrying:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsDLR reports whether s is a final state reported by a provider delivery receipt.
+func (s MessageStatus) IsDLR() bool {
+	return s == StatusDelivered || s == StatusUndelivered || s == StatusExpired
 }
 
 // Message is the DB entity persisted in messages table.
 type Message struct {
-	ID         string        `db:"id"`
-	CustomerID int64         `db:"customer_id"`
-	Phone      string        `db:"phone"`
-	Text       string        `db:"text"`
-	Type       SMSType       `db:"type"` // normal|express
-	Status     MessageStatus `db:"status"`
-	CreatedAt  time.Time     `db:"created_at"`
-	UpdatedAt  time.Time     `db:"updated_at"`
+	ID                string        `db:"id"`
+	CustomerID        int64         `db:"customer_id"`
+	Phone             string        `db:"phone"`
+	Text              string        `db:"text"`
+	Type              SMSType       `db:"type"` // normal|express
+	Status            MessageStatus `db:"status"`
+	Encoding          Encoding      `db:"encoding"`            // gsm7|ucs2
+	Segments          int           `db:"segments"`            // SMS parts; price is per segment
+	Price             int64         `db:"price"`               // total amount reserved at enqueue time
+	Provider          *string       `db:"provider"`            // set once a provider accepted it
+	ProviderMessageID *string       `db:"provider_message_id"` // provider-side id, used to match DLRs
+	DLRAt             *time.Time    `db:"dlr_at"`              // delivery receipt time
+	CallbackURL       *string       `db:"callback_url"`        // per-message webhook, overrides the account one
+	BatchID           *string       `db:"batch_id"`            // bulk request this message belongs to
+	SendAt            *time.Time    `db:"send_at"`             // release time of a scheduled message
+	ClientRef         *string       `db:"client_ref"`          // customer idempotency key, unique per customer
+	RequestHash       *string       `db:"request_hash"`        // hash of the request sent with ClientRef
+	UsageDay          string        `db:"usage_day"`           // customer's day (YYYY-MM-DD) the message was counted in
+	CreatedAt         time.Time     `db:"created_at"`
+	UpdatedAt         time.Time     `db:"updated_at"`
 }
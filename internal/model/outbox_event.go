@@ -3,12 +3,13 @@ package model
 import "time"
 
 type OutboxEvent struct {
-	ID          int64     `db:"id"`
-	Aggregate   string    `db:"aggregate"`    // e.g. "message"
-	AggregateID string    `db:"aggregate_id"` // message.ID
-	Topic       string    `db:"topic"`
-	Payload     []byte    `db:"payload"`
-	Attempts    int       `db:"attempts"`
-	CreatedAt   time.Time `db:"created_at"`
-	UpdatedAt   time.Time `db:"updated_at"`
+	ID          int64      `db:"id"`
+	Aggregate   string     `db:"aggregate"`    // e.g. "message"
+	AggregateID string     `db:"aggregate_id"` // message.ID
+	Topic       string     `db:"topic"`
+	Payload     []byte     `db:"payload"`
+	Attempts    int        `db:"attempts"`     // failed relay publishes
+	PublishedAt *time.Time `db:"published_at"` // set by the relay once the broker acked
+	CreatedAt   time.Time  `db:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at"`
 }
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// PriceRule is the per-segment price for destinations starting with Prefix on a lane.
+// CustomerID 0 is the global table; other values override it for one customer.
+type PriceRule struct {
+	ID         int64     `db:"id"`
+	CustomerID int64     `db:"customer_id"`
+	Prefix     string    `db:"prefix"` // E.164 prefix, e.g. "+98912"
+	Lane       SMSType   `db:"lane"`
+	Price      int64     `db:"price"`
+	CreatedAt  time.Time `db:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
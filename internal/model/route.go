@@ -0,0 +1,28 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// RouteRule restricts destinations starting with Prefix on a lane to the listed providers,
+// tried in order.
+type RouteRule struct {
+	ID        int64     `db:"id"`
+	Prefix    string    `db:"prefix"` // E.164 prefix, e.g. "+98935"
+	Lane      SMSType   `db:"lane"`
+	Providers string    `db:"providers"` // comma-separated provider names, in failover order
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// ProviderList splits Providers.
+func (r RouteRule) ProviderList() []string {
+	var out []string
+	for _, p := range strings.Split(r.Providers, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
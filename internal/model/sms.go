@@ -28,6 +28,16 @@ func (t SMSType) Valid() bool {
 	return t == SMSTypeNormal || t == SMSTypeExpress
 }
 
+// Encoding is the data coding a text needs on the air interface.
+type Encoding string
+
+const (
+	EncodingGSM7 Encoding = "gsm7" // GSM 03.38 default alphabet: 160 chars, 153 per concatenated part
+	EncodingUCS2 Encoding = "ucs2" // UTF-16: 70 chars, 67 per concatenated part (Persian, emoji, ...)
+)
+
+func (e Encoding) String() string { return string(e) }
+
 type SMS struct {
 	Phone string  `json:"phone"`
 	Text  string  `json:"text"`
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var ErrMissingParams = errors.New("missing template params")
+
+// placeholderRe matches {{name}} (whitespace inside the braces is allowed).
+var placeholderRe = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+// Template is a customer-owned message text with named {{placeholders}}.
+type Template struct {
+	ID         int64     `db:"id"`
+	CustomerID int64     `db:"customer_id"`
+	Name       string    `db:"name"`
+	Body       string    `db:"body"`
+	CreatedAt  time.Time `db:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
+
+// Placeholders returns the distinct placeholder names in order of first appearance.
+func (t Template) Placeholders() []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, m := range placeholderRe.FindAllStringSubmatch(t.Body, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			out = append(out, m[1])
+		}
+	}
+	return out
+}
+
+// Render substitutes every placeholder from params. Extra params are ignored;
+// missing ones fail with ErrMissingParams listing their names.
+func (t Template) Render(params map[string]string) (string, error) {
+	var missing []string
+	for _, name := range t.Placeholders() {
+		if _, ok := params[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrMissingParams, strings.Join(missing, ", "))
+	}
+
+	return placeholderRe.ReplaceAllStringFunc(t.Body, func(m string) string {
+		return params[placeholderRe.FindStringSubmatch(m)[1]]
+	}), nil
+}
@@ -0,0 +1,88 @@
+package model
+
+import "time"
+
+// Cap names, as reported when a send is rejected.
+const (
+	CapDailyMessages   = "daily_messages"
+	CapMonthlyMessages = "monthly_messages"
+	CapDailySpend      = "daily_spend"
+	CapMonthlySpend    = "monthly_spend"
+)
+
+// Caps are a customer's ceilings per calendar day and month in the customer's timezone;
+// nil means no cap. Spend is what was reserved at enqueue, in wallet units.
+type Caps struct {
+	DailyMessages   *int64 `db:"daily_message_cap"`
+	MonthlyMessages *int64 `db:"monthly_message_cap"`
+	DailySpend      *int64 `db:"daily_spend_cap"`
+	MonthlySpend    *int64 `db:"monthly_spend_cap"`
+}
+
+// Any reports whether at least one cap is set.
+func (c Caps) Any() bool {
+	return c.DailyMessages != nil || c.MonthlyMessages != nil || c.DailySpend != nil || c.MonthlySpend != nil
+}
+
+// Check reports the first cap that adding messages and spend to u would exceed.
+func (c Caps) Check(u Usage, messages, spend int64) (name string, limit, used int64, exceeded bool) {
+	checks := []struct {
+		name string
+		cap  *int64
+		used int64
+		add  int64
+	}{
+		{CapDailyMessages, c.DailyMessages, u.DayMessages, messages},
+		{CapMonthlyMessages, c.MonthlyMessages, u.MonthMessages, messages},
+		{CapDailySpend, c.DailySpend, u.DaySpend, spend},
+		{CapMonthlySpend, c.MonthlySpend, u.MonthSpend, spend},
+	}
+	for _, ch := range checks {
+		if ch.cap != nil && ch.used+ch.add > *ch.cap {
+			return ch.name, *ch.cap, ch.used, true
+		}
+	}
+	return "", 0, 0, false
+}
+
+// Usage is what a customer sent in the current day and month.
+type Usage struct {
+	DayMessages   int64
+	DaySpend      int64
+	MonthMessages int64
+	MonthSpend    int64
+}
+
+// Periods are the current calendar day and month in a customer's timezone. Day and Month
+// are their first dates (YYYY-MM-DD), as stored in customer_usage.period_start.
+type Periods struct {
+	Day       string
+	Month     string
+	DayEnds   time.Time
+	MonthEnds time.Time
+}
+
+// PeriodsAt returns the day and month containing now in loc.
+func PeriodsAt(loc *time.Location, now time.Time) Periods {
+	t := now.In(loc)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	month := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	return Periods{
+		Day:       day.Format(time.DateOnly),
+		Month:     month.Format(time.DateOnly),
+		DayEnds:   day.AddDate(0, 0, 1),
+		MonthEnds: month.AddDate(0, 1, 0),
+	}
+}
+
+// LocationOf loads an IANA timezone, falling back to UTC.
+func LocationOf(tz string) *time.Location {
+	if tz == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
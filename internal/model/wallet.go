@@ -10,3 +10,13 @@ type WalletAccount struct {
 	UpdatedAt  time.Time `db:"updated_at"`
 	CreatedAt  time.Time `db:"created_at"`
 }
+
+// LedgerEntry is one wallet_ledger row as shown on a customer statement.
+type LedgerEntry struct {
+	ID         int64     `db:"id"`
+	CustomerID int64     `db:"customer_id"`
+	Op         string    `db:"op"` // topup|reserve|capture|refund
+	Amount     int64     `db:"amount"`
+	MessageID  *string   `db:"message_id"`
+	CreatedAt  time.Time `db:"created_at"`
+}
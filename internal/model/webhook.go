@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+type WebhookState string
+
+const (
+	WebhookPending   WebhookState = "pending"
+	WebhookDelivered WebhookState = "delivered"
+	WebhookFailed    WebhookState = "failed"
+)
+
+// WebhookDelivery is one status event queued for delivery to a customer webhook.
+type WebhookDelivery struct {
+	ID             int64         `db:"id"`
+	CustomerID     int64         `db:"customer_id"`
+	MessageID      string        `db:"message_id"`
+	Event          MessageStatus `db:"status_event"` // message status that triggered the event
+	URL            string        `db:"url"`
+	Payload        []byte        `db:"payload"`
+	State          WebhookState  `db:"state"`
+	Attempts       int           `db:"attempts"`
+	NextAttemptAt  time.Time     `db:"next_attempt_at"`
+	LastStatusCode *int          `db:"last_status_code"`
+	LastError      *string       `db:"last_error"`
+	CreatedAt      time.Time     `db:"created_at"`
+	UpdatedAt      time.Time     `db:"updated_at"`
+}
+
+// WebhookAttempt records one HTTP call made for a delivery.
+type WebhookAttempt struct {
+	ID           int64     `db:"id"`
+	DeliveryID   int64     `db:"delivery_id"`
+	Attempt      int       `db:"attempt"`
+	ResponseCode *int      `db:"response_code"`
+	ResponseBody *string   `db:"response_body"`
+	Error        *string   `db:"error"`
+	DurationMs   int       `db:"duration_ms"`
+	CreatedAt    time.Time `db:"created_at"`
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// APIKeysRepository persists customer API credentials.
+type APIKeysRepository interface {
+	// GetCredential returns the key with the given prefix joined with its customer, or (nil, nil).
+	GetCredential(ctx context.Context, prefix string) (*model.Credential, error)
+	ListByCustomer(ctx context.Context, customerID int64) ([]model.APIKey, error)
+	GetForUpdate(ctx context.Context, tx *sqlx.Tx, customerID, id int64) (*model.APIKey, error)
+	// Create inserts k and returns its id; ErrDuplicate on a prefix collision.
+	Create(ctx context.Context, tx *sqlx.Tx, k model.APIKey) (int64, error)
+	Revoke(ctx context.Context, tx *sqlx.Tx, id int64) error
+	SetExpiry(ctx context.Context, tx *sqlx.Tx, id int64, at time.Time) error
+	TouchLastUsed(ctx context.Context, id int64, at time.Time) error
+}
+
+type APIKeysRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewAPIKeysRepository(db *sqlx.DB) *APIKeysRepositoryImpl {
+	return &APIKeysRepositoryImpl{db: db}
+}
+
+var _ APIKeysRepository = (*APIKeysRepositoryImpl)(nil)
+
+const apiKeyColumns = `k.id, k.customer_id, k.prefix, k.secret_hash, k.label, k.scopes, k.allowed_ips,
+		       k.expires_at, k.last_used_at, k.revoked_at, k.created_at`
+
+func (r *APIKeysRepositoryImpl) GetCredential(ctx context.Context, prefix string) (*model.Credential, error) {
+	var c model.Credential
+	err := r.db.GetContext(ctx, &c, `
+		SELECT `+apiKeyColumns+`,
+		       cu.status AS customer_status, cu.rate_limit_rps, cu.rate_limit_burst
+		  FROM api_keys k
+		  JOIN customers cu ON cu.id = k.customer_id
+		 WHERE k.prefix = ? LIMIT 1
+	`, prefix)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ListByCustomer returns all of a customer's keys, revoked ones included, newest first.
+func (r *APIKeysRepositoryImpl) ListByCustomer(ctx context.Context, customerID int64) ([]model.APIKey, error) {
+	var rows []model.APIKey
+	err := r.db.SelectContext(ctx, &rows, `
+		SELECT `+apiKeyColumns+`
+		  FROM api_keys k
+		 WHERE k.customer_id = ?
+		 ORDER BY k.id DESC
+	`, customerID)
+	return rows, err
+}
+
+func (r *APIKeysRepositoryImpl) GetForUpdate(ctx context.Context, tx *sqlx.Tx, customerID, id int64) (*model.APIKey, error) {
+	var k model.APIKey
+	err := tx.GetContext(ctx, &k, `
+		SELECT `+apiKeyColumns+`
+		  FROM api_keys k
+		 WHERE k.id = ? AND k.customer_id = ?
+		   FOR UPDATE
+	`, id, customerID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *APIKeysRepositoryImpl) Create(ctx context.Context, tx *sqlx.Tx, k model.APIKey) (int64, error) {
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO api_keys (customer_id, prefix, secret_hash, label, scopes, allowed_ips, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, NOW())
+	`, k.CustomerID, k.Prefix, k.SecretHash, k.Label, k.Scopes, k.AllowedIPs, k.ExpiresAt)
+	if err != nil {
+		return 0, mapDuplicate(err)
+	}
+	return res.LastInsertId()
+}
+
+func (r *APIKeysRepositoryImpl) Revoke(ctx context.Context, tx *sqlx.Tx, id int64) error {
+	_, err := tx.ExecContext(ctx, `UPDATE api_keys SET revoked_at = NOW() WHERE id = ? AND revoked_at IS NULL`, id)
+	return err
+}
+
+func (r *APIKeysRepositoryImpl) SetExpiry(ctx context.Context, tx *sqlx.Tx, id int64, at time.Time) error {
+	_, err := tx.ExecContext(ctx, `UPDATE api_keys SET expires_at = ? WHERE id = ?`, at, id)
+	return err
+}
+
+// TouchLastUsed moves last_used_at forward; callers throttle it, it is not meant per request.
+func (r *APIKeysRepositoryImpl) TouchLastUsed(ctx context.Context, id int64, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE api_keys
+		   SET last_used_at = ?
+		 WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)
+	`, at, id, at)
+	return err
+}
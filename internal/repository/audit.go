@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// AuditRepository persists the admin audit trail.
+type AuditRepository interface {
+	Insert(ctx context.Context, tx *sqlx.Tx, e model.AuditEntry) error
+	// List returns entries newest first; customerID nil = all, beforeID 0 = from the newest.
+	List(ctx context.Context, customerID *int64, beforeID int64, limit int) ([]model.AuditEntry, error)
+}
+
+type AuditRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewAuditRepository(db *sqlx.DB) *AuditRepositoryImpl {
+	return &AuditRepositoryImpl{db: db}
+}
+
+var _ AuditRepository = (*AuditRepositoryImpl)(nil)
+
+func (r *AuditRepositoryImpl) Insert(ctx context.Context, tx *sqlx.Tx, e model.AuditEntry) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO admin_audit_log (actor, remote_ip, action, customer_id, details, created_at)
+		VALUES (?, ?, ?, ?, ?, NOW())
+	`, e.Actor, e.RemoteIP, e.Action, e.CustomerID, e.Details)
+	return err
+}
+
+func (r *AuditRepositoryImpl) List(ctx context.Context, customerID *int64, beforeID int64, limit int) ([]model.AuditEntry, error) {
+	q := `
+		SELECT id, actor, remote_ip, action, customer_id, details, created_at
+		  FROM admin_audit_log
+		 WHERE 1 = 1
+	`
+	var args []any
+	if customerID != nil {
+		q += " AND customer_id = ?"
+		args = append(args, *customerID)
+	}
+	if beforeID > 0 {
+		q += " AND id < ?"
+		args = append(args, beforeID)
+	}
+	q += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	var rows []model.AuditEntry
+	err := r.db.SelectContext(ctx, &rows, q, args...)
+	return rows, err
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// CHLedgerRepository lists ledger history from ClickHouse (CDC copy of wallet_ledger).
+type CHLedgerRepository interface {
+	ListLedger(ctx context.Context, f LedgerFilter) ([]model.LedgerEntry, error)
+}
+
+type chLedgerRepository struct {
+	ch *sqlx.DB // ClickHouse connection
+}
+
+func NewCHLedgerRepository(ch *sqlx.DB) CHLedgerRepository {
+	return &chLedgerRepository{ch: ch}
+}
+
+func (r *chLedgerRepository) ListLedger(ctx context.Context, f LedgerFilter) ([]model.LedgerEntry, error) {
+	q, args := ledgerQuery(`
+		SELECT toInt64(id) AS id, toInt64(customer_id) AS customer_id, toString(op) AS op,
+		       toInt64(amount) AS amount, nullIf(message_id, '') AS message_id, created_at
+		FROM smsgw.wallet_ledger
+		WHERE customer_id = ?
+	`, f)
+
+	var rows []model.LedgerEntry
+	if err := r.ch.SelectContext(ctx, &rows, q, args...); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
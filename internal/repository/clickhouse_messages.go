@@ -29,7 +29,7 @@ func (r *chMessagesRepository) ListByCustomer(ctx context.Context, customerID in
 	}
 
 	q := `
-		SELECT id, customer_id, phone, text, type, status, created_at, updated_at
+		SELECT id, customer_id, phone, text, type, status, provider, dlr_at, created_at, updated_at
 		FROM smsgw.messages_latest
 		WHERE customer_id = ?
 	`
@@ -9,7 +9,16 @@ import (
 )
 
 type CustomersRepository interface {
-	GetByAPIKey(ctx context.Context, apiKey string) (*model.Customer, error)
+	GetByID(ctx context.Context, id int64) (*model.Customer, error)
+	GetShared(ctx context.Context, tx *sqlx.Tx, id int64) (*model.Customer, error)
+	SetWebhook(ctx context.Context, id int64, url, secret *string) error
+
+	// admin
+	List(ctx context.Context, status string, limit, offset int) ([]model.Customer, error)
+	GetForUpdate(ctx context.Context, tx *sqlx.Tx, id int64) (*model.Customer, error)
+	Create(ctx context.Context, tx *sqlx.Tx, c model.Customer) (int64, error)
+	Update(ctx context.Context, tx *sqlx.Tx, c model.Customer) error
+	SetStatus(ctx context.Context, tx *sqlx.Tx, id int64, status string) error
 }
 
 type CustomersRepositoryImpl struct {
@@ -22,13 +31,35 @@ func NewCustomersRepository(db *sqlx.DB) *CustomersRepositoryImpl {
 
 var _ CustomersRepository = (*CustomersRepositoryImpl)(nil)
 
-func (r *CustomersRepositoryImpl) GetByAPIKey(ctx context.Context, apiKey string) (*model.Customer, error) {
+const customerColumns = `id, name, status, rate_limit_rps, rate_limit_burst, webhook_url, webhook_secret, timezone,
+		       daily_message_cap, monthly_message_cap, daily_spend_cap, monthly_spend_cap, created_at, updated_at`
+
+func (r *CustomersRepositoryImpl) GetByID(ctx context.Context, id int64) (*model.Customer, error) {
 	var c model.Customer
 	err := r.db.GetContext(ctx, &c, `
-		SELECT id, name, api_key, status, rate_limit_rps, created_at, updated_at
+		SELECT `+customerColumns+`
+		  FROM customers
+		 WHERE id = ? LIMIT 1
+	`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetShared reads the customer under a shared lock, so caps and timezone cannot change
+// until tx ends.
+func (r *CustomersRepositoryImpl) GetShared(ctx context.Context, tx *sqlx.Tx, id int64) (*model.Customer, error) {
+	var c model.Customer
+	err := tx.GetContext(ctx, &c, `
+		SELECT `+customerColumns+`
 		  FROM customers
-		 WHERE api_key = ? LIMIT 1
-	`, apiKey)
+		 WHERE id = ?
+		   FOR SHARE
+	`, id)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -37,3 +68,79 @@ func (r *CustomersRepositoryImpl) GetByAPIKey(ctx context.Context, apiKey string
 	}
 	return &c, nil
 }
+
+// SetWebhook sets (or clears, with nil values) the account-level webhook URL and signing secret.
+func (r *CustomersRepositoryImpl) SetWebhook(ctx context.Context, id int64, url, secret *string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE customers
+		   SET webhook_url = ?, webhook_secret = ?, updated_at = NOW()
+		 WHERE id = ?
+	`, url, secret, id)
+	return err
+}
+
+// List returns customers by id; status filters when not empty.
+func (r *CustomersRepositoryImpl) List(ctx context.Context, status string, limit, offset int) ([]model.Customer, error) {
+	q := `
+		SELECT ` + customerColumns + `
+		  FROM customers
+	`
+	var args []any
+	if status != "" {
+		q += " WHERE status = ?"
+		args = append(args, status)
+	}
+	q += " ORDER BY id LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	var rows []model.Customer
+	err := r.db.SelectContext(ctx, &rows, q, args...)
+	return rows, err
+}
+
+// GetForUpdate locks the customer row for an admin change.
+func (r *CustomersRepositoryImpl) GetForUpdate(ctx context.Context, tx *sqlx.Tx, id int64) (*model.Customer, error) {
+	var c model.Customer
+	err := tx.GetContext(ctx, &c, `
+		SELECT `+customerColumns+`
+		  FROM customers
+		 WHERE id = ?
+		   FOR UPDATE
+	`, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *CustomersRepositoryImpl) Create(ctx context.Context, tx *sqlx.Tx, c model.Customer) (int64, error) {
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO customers (name, status, rate_limit_rps, rate_limit_burst, created_at, updated_at)
+		VALUES (?, ?, ?, ?, NOW(), NOW())
+	`, c.Name, c.Status, c.RateLimitRPS, c.RateLimitBurst)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// Update writes the admin-editable fields: name, rate limits, timezone and caps.
+func (r *CustomersRepositoryImpl) Update(ctx context.Context, tx *sqlx.Tx, c model.Customer) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE customers
+		   SET name = ?, rate_limit_rps = ?, rate_limit_burst = ?, timezone = ?,
+		       daily_message_cap = ?, monthly_message_cap = ?, daily_spend_cap = ?, monthly_spend_cap = ?,
+		       updated_at = NOW()
+		 WHERE id = ?
+	`, c.Name, c.RateLimitRPS, c.RateLimitBurst, c.Timezone,
+		c.DailyMessages, c.MonthlyMessages, c.DailySpend, c.MonthlySpend, c.ID)
+	return err
+}
+
+func (r *CustomersRepositoryImpl) SetStatus(ctx context.Context, tx *sqlx.Tx, id int64, status string) error {
+	_, err := tx.ExecContext(ctx, `UPDATE customers SET status = ?, updated_at = NOW() WHERE id = ?`, status, id)
+	return err
+}
@@ -14,6 +14,7 @@ type LedgerRepository interface {
 	ExistsByIdem(ctx context.Context, tx *sqlx.Tx, idem string) (bool, error)
 	InsertTopup(ctx context.Context, tx *sqlx.Tx, customerID int64, amount int64, idem string) error
 	InsertReserve(ctx context.Context, tx *sqlx.Tx, customerID int64, amount int64, msgID, idem string) error
+	InsertBulkReserve(ctx context.Context, tx *sqlx.Tx, customerID int64, amount int64, batchID string) error
 	InsertCaptureBatch(ctx context.Context, tx *sqlx.Tx, rows []LedgerRow) error
 	InsertRefundBatch(ctx context.Context, tx *sqlx.Tx, rows []LedgerRow) error
 }
@@ -63,6 +64,17 @@ func (r *ledgerRepo) InsertReserve(ctx context.Context, tx *sqlx.Tx, customerID
 	return err
 }
 
+// InsertBulkReserve records one reserve covering every message of a bulk request.
+// message_id stays NULL; captures/refunds are still written per message.
+func (r *ledgerRepo) InsertBulkReserve(ctx context.Context, tx *sqlx.Tx, customerID int64, amount int64, batchID string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO wallet_ledger (customer_id, op, amount, idempotency_key)
+		VALUES (?, 'reserve', ?, ?)
+		ON DUPLICATE KEY UPDATE id = id
+	`, customerID, amount, "reserve-bulk-"+batchID)
+	return err
+}
+
 func (r *ledgerRepo) InsertCaptureBatch(ctx context.Context, tx *sqlx.Tx, rows []LedgerRow) error {
 	return r.insertBatch(ctx, tx, "capture", rows)
 }
@@ -2,17 +2,42 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jmehdipour/sms-gateway/internal/model"
 	"github.com/jmoiron/sqlx"
 )
 
-// MessagesRepository defines persistence for the messages table (no attempts, no provider).
+// MessagesRepository defines persistence for the messages table.
 type MessagesRepository interface {
 	InsertQueued(ctx context.Context, tx *sqlx.Tx, m model.Message) error
+	InsertQueuedBatch(ctx context.Context, tx *sqlx.Tx, ms []model.Message) error
+	GetForUpdate(ctx context.Context, tx *sqlx.Tx, customerID int64, id string) (*model.Message, error)
+	ClaimDueScheduled(ctx context.Context, tx *sqlx.Tx, limit int) ([]model.Message, error)
+	ListScheduled(ctx context.Context, customerID int64, limit, offset int) ([]model.Message, error)
 	BatchUpdateStatus(ctx context.Context, tx *sqlx.Tx, ids []string, status model.MessageStatus) error
+	BatchMarkSent(ctx context.Context, tx *sqlx.Tx, rows []SentRow) error
+	GetByProviderMessageID(ctx context.Context, provider, providerMsgID string) (*model.Message, error)
+	GetByClientRef(ctx context.Context, customerID int64, clientRef string) (*model.Message, error)
+	GetByIDs(ctx context.Context, customerID int64, ids []string) ([]model.Message, error)
+	ApplyDLR(ctx context.Context, tx *sqlx.Tx, id string, status model.MessageStatus, at time.Time) (bool, error)
 }
 
+// SentRow carries the provider that accepted a message and its provider-side id.
+type SentRow struct {
+	ID                string
+	Provider          string
+	ProviderMessageID string
+}
+
+// messageColumns is the full column list scanned into model.Message.
+const messageColumns = `id, customer_id, phone, text, type, status, encoding, segments, price, provider, provider_message_id, dlr_at,
+		callback_url, batch_id, send_at, client_ref, request_hash, DATE_FORMAT(usage_day, '%Y-%m-%d') AS usage_day, created_at, updated_at`
+
 type MessagesRepositoryImpl struct {
 	db *sqlx.DB
 }
@@ -36,18 +61,52 @@ func (r *MessagesRepositoryImpl) withTx(ctx context.Context, tx *sqlx.Tx, fn fun
 	return t.Commit()
 }
 
-// InsertQueued inserts a new message row with status=queued.
+// InsertQueued inserts a new message row with status=queued (or scheduled when m.Status says so).
+// Returns ErrDuplicate when the customer already used m.ClientRef.
 func (r *MessagesRepositoryImpl) InsertQueued(ctx context.Context, tx *sqlx.Tx, m model.Message) error {
 	const q = `
 		INSERT INTO messages
-		    (id, customer_id, phone, text, type, status, created_at, updated_at)
+		    (id, customer_id, phone, text, type, status, encoding, segments, price, callback_url, batch_id, send_at, client_ref, request_hash, usage_day, created_at, updated_at)
 		VALUES
-		    (?,  ?,           ?,     ?,   ?,   'queued', NOW(),    NOW())
+		    (?,  ?,           ?,     ?,   ?,   ?,      ?,        ?,        ?,     ?,            ?,        ?,       ?,          ?,            ?,         NOW(),      NOW())
 	`
 	return r.withTx(ctx, tx, func(tx *sqlx.Tx) error {
 		_, err := tx.ExecContext(ctx, q,
-			m.ID, m.CustomerID, m.Phone, m.Text, m.Type.String(),
+			m.ID, m.CustomerID, m.Phone, m.Text, m.Type.String(), initialStatus(m), m.Encoding.String(), m.Segments,
+			m.Price, m.CallbackURL, m.BatchID, m.SendAt, m.ClientRef, m.RequestHash, m.UsageDay,
 		)
+		return mapDuplicate(err)
+	})
+}
+
+func initialStatus(m model.Message) string {
+	if m.Status == model.StatusScheduled {
+		return m.Status.String()
+	}
+	return model.StatusQueued.String()
+}
+
+// InsertQueuedBatch inserts many queued (or scheduled) messages with a single multi-row statement.
+func (r *MessagesRepositoryImpl) InsertQueuedBatch(ctx context.Context, tx *sqlx.Tx, ms []model.Message) error {
+	if len(ms) == 0 {
+		return nil
+	}
+
+	var sb strings.Builder
+	args := make([]any, 0, len(ms)*13)
+
+	sb.WriteString(`INSERT INTO messages (id, customer_id, phone, text, type, status, encoding, segments, price, callback_url, batch_id, send_at, usage_day, created_at, updated_at) VALUES `)
+	for i, m := range ms {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())")
+		args = append(args, m.ID, m.CustomerID, m.Phone, m.Text, m.Type.String(), initialStatus(m), m.Encoding.String(), m.Segments,
+			m.Price, m.CallbackURL, m.BatchID, m.SendAt, m.UsageDay)
+	}
+
+	return r.withTx(ctx, tx, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, sb.String(), args...)
 		return err
 	})
 }
@@ -69,3 +128,178 @@ func (r *MessagesRepositoryImpl) BatchUpdateStatus(ctx context.Context, tx *sqlx
 		return err
 	})
 }
+
+// BatchMarkSent sets status=sent together with provider and provider_message_id in one statement.
+func (r *MessagesRepositoryImpl) BatchMarkSent(ctx context.Context, tx *sqlx.Tx, rows []SentRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	var sbRaw strings.Builder
+	args := make([]any, 0, len(rows)*3)
+
+	sbRaw.WriteString("SELECT ? AS id, ? AS provider, ? AS provider_message_id")
+	for i, rw := range rows {
+		if i > 0 {
+			sbRaw.WriteString(" UNION ALL SELECT ?, ?, ?")
+		}
+		args = append(args, rw.ID, nullIfEmpty(rw.Provider), nullIfEmpty(rw.ProviderMessageID))
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE messages m
+		JOIN (
+			%s
+		) s ON s.id = m.id
+		SET m.status              = 'sent',
+		    m.provider            = s.provider,
+		    m.provider_message_id = s.provider_message_id,
+		    m.updated_at          = NOW()
+	`, sbRaw.String())
+
+	return r.withTx(ctx, tx, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, query, args...)
+		return err
+	})
+}
+
+// GetByProviderMessageID finds the message a provider accepted under providerMsgID.
+// Returns (nil, nil) when no such message exists.
+func (r *MessagesRepositoryImpl) GetByProviderMessageID(ctx context.Context, provider, providerMsgID string) (*model.Message, error) {
+	var m model.Message
+	err := r.db.GetContext(ctx, &m, `
+		SELECT `+messageColumns+`
+		  FROM messages
+		 WHERE provider = ? AND provider_message_id = ? LIMIT 1
+	`, provider, providerMsgID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// GetByIDs returns the customer's messages among ids; unknown or foreign IDs are skipped.
+func (r *MessagesRepositoryImpl) GetByIDs(ctx context.Context, customerID int64, ids []string) ([]model.Message, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	query, args, err := sqlx.In(`
+		SELECT `+messageColumns+`
+		  FROM messages
+		 WHERE customer_id = ? AND id IN (?)
+	`, customerID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []model.Message
+	err = r.db.SelectContext(ctx, &rows, r.db.Rebind(query), args...)
+	return rows, err
+}
+
+// GetByClientRef finds the customer's message created under an idempotency key.
+// Returns (nil, nil) when the key is unused.
+func (r *MessagesRepositoryImpl) GetByClientRef(ctx context.Context, customerID int64, clientRef string) (*model.Message, error) {
+	var m model.Message
+	err := r.db.GetContext(ctx, &m, `
+		SELECT `+messageColumns+`
+		  FROM messages
+		 WHERE customer_id = ? AND client_ref = ? LIMIT 1
+	`, customerID, clientRef)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ApplyDLR moves a sent message to a delivery-receipt state (delivered|undelivered|expired).
+// Returns false when the message is not in the sent state (unknown id or receipt already applied).
+func (r *MessagesRepositoryImpl) ApplyDLR(ctx context.Context, tx *sqlx.Tx, id string, status model.MessageStatus, at time.Time) (bool, error) {
+	if !status.IsDLR() {
+		return false, fmt.Errorf("not a dlr status: %s", status)
+	}
+	const q = `
+		UPDATE messages
+		   SET status = ?, dlr_at = ?, updated_at = NOW()
+		 WHERE id = ? AND status = 'sent'
+	`
+	var applied bool
+	err := r.withTx(ctx, tx, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx, q, status.String(), at, id)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		applied = n > 0
+		return nil
+	})
+	return applied, err
+}
+
+// GetForUpdate locks one message of customerID. Returns (nil, nil) when not found.
+func (r *MessagesRepositoryImpl) GetForUpdate(ctx context.Context, tx *sqlx.Tx, customerID int64, id string) (*model.Message, error) {
+	var m model.Message
+	err := tx.GetContext(ctx, &m, `
+		SELECT `+messageColumns+`
+		  FROM messages
+		 WHERE id = ? AND customer_id = ?
+		   FOR UPDATE
+	`, id, customerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ClaimDueScheduled locks up to limit scheduled messages whose send_at has passed, oldest first.
+// Rows locked by another replica are skipped.
+func (r *MessagesRepositoryImpl) ClaimDueScheduled(ctx context.Context, tx *sqlx.Tx, limit int) ([]model.Message, error) {
+	var rows []model.Message
+	err := tx.SelectContext(ctx, &rows, `
+		SELECT `+messageColumns+`
+		  FROM messages
+		 WHERE status = 'scheduled' AND send_at <= NOW()
+		 ORDER BY send_at, id
+		 LIMIT ?
+		   FOR UPDATE SKIP LOCKED
+	`, limit)
+	return rows, err
+}
+
+// ListScheduled lists a customer's pending scheduled messages, soonest first.
+func (r *MessagesRepositoryImpl) ListScheduled(ctx context.Context, customerID int64, limit, offset int) ([]model.Message, error) {
+	if limit <= 0 || limit > 1000 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	var rows []model.Message
+	err := r.db.SelectContext(ctx, &rows, `
+		SELECT `+messageColumns+`
+		  FROM messages
+		 WHERE customer_id = ? AND status = 'scheduled'
+		 ORDER BY send_at, id
+		 LIMIT ? OFFSET ?
+	`, customerID, limit, offset)
+	return rows, err
+}
+
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
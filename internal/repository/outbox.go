@@ -2,7 +2,11 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"strings"
+	"time"
 
+	"github.com/jmehdipour/sms-gateway/internal/model"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -11,6 +15,28 @@ type OutboxRepository interface {
 	// Insert writes a single outbox event. If tx is nil, it will open/commit
 	// an internal transaction; otherwise it uses the given tx.
 	Insert(ctx context.Context, tx *sqlx.Tx, aggregate, aggregateID, topic string, payload []byte) error
+	// InsertBatch writes many events with a single multi-row statement.
+	InsertBatch(ctx context.Context, tx *sqlx.Tx, rows []OutboxRow) error
+
+	// ClaimUnpublished locks up to limit unpublished events in id order; rows locked by
+	// another relay are skipped. The lock lasts until tx ends.
+	ClaimUnpublished(ctx context.Context, tx *sqlx.Tx, limit int) ([]model.OutboxEvent, error)
+	// MarkPublished stamps published_at on acked events.
+	MarkPublished(ctx context.Context, tx *sqlx.Tx, ids []int64) error
+	// DeleteByIDs removes acked events.
+	DeleteByIDs(ctx context.Context, tx *sqlx.Tx, ids []int64) error
+	// IncrementAttempts counts a failed publish of the events.
+	IncrementAttempts(ctx context.Context, tx *sqlx.Tx, ids []int64) error
+	// Lag returns the number of unpublished events and the creation time of the oldest one.
+	Lag(ctx context.Context) (int64, *time.Time, error)
+}
+
+// OutboxRow is one event for InsertBatch.
+type OutboxRow struct {
+	Aggregate   string
+	AggregateID string
+	Topic       string
+	Payload     []byte
 }
 
 // OutboxRepositoryImpl is a sqlx-backed implementation.
@@ -55,3 +81,85 @@ func (r *OutboxRepositoryImpl) Insert(ctx context.Context, tx *sqlx.Tx, aggregat
 		return err
 	})
 }
+
+// InsertBatch adds many event rows to outbox in one statement.
+func (r *OutboxRepositoryImpl) InsertBatch(ctx context.Context, tx *sqlx.Tx, rows []OutboxRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	var sb strings.Builder
+	args := make([]any, 0, len(rows)*4)
+
+	sb.WriteString(`INSERT INTO outbox (aggregate, aggregate_id, topic, payload, created_at) VALUES `)
+	for i, rw := range rows {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("(?, ?, ?, ?, NOW())")
+		args = append(args, rw.Aggregate, rw.AggregateID, rw.Topic, rw.Payload)
+	}
+
+	return r.withTx(ctx, tx, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, sb.String(), args...)
+		return err
+	})
+}
+
+func (r *OutboxRepositoryImpl) ClaimUnpublished(ctx context.Context, tx *sqlx.Tx, limit int) ([]model.OutboxEvent, error) {
+	var rows []model.OutboxEvent
+	err := tx.SelectContext(ctx, &rows, `
+		SELECT id, aggregate, aggregate_id, topic, payload, attempts, published_at, created_at, updated_at
+		  FROM outbox
+		 WHERE published_at IS NULL
+		 ORDER BY id
+		 LIMIT ?
+		   FOR UPDATE SKIP LOCKED
+	`, limit)
+	return rows, err
+}
+
+func (r *OutboxRepositoryImpl) MarkPublished(ctx context.Context, tx *sqlx.Tx, ids []int64) error {
+	return r.execIn(ctx, tx, `UPDATE outbox SET published_at = NOW() WHERE id IN (?)`, ids)
+}
+
+func (r *OutboxRepositoryImpl) DeleteByIDs(ctx context.Context, tx *sqlx.Tx, ids []int64) error {
+	return r.execIn(ctx, tx, `DELETE FROM outbox WHERE id IN (?)`, ids)
+}
+
+func (r *OutboxRepositoryImpl) IncrementAttempts(ctx context.Context, tx *sqlx.Tx, ids []int64) error {
+	return r.execIn(ctx, tx, `UPDATE outbox SET attempts = attempts + 1 WHERE id IN (?)`, ids)
+}
+
+// execIn runs a statement with a single IN (?) placeholder bound to ids.
+func (r *OutboxRepositoryImpl) execIn(ctx context.Context, tx *sqlx.Tx, q string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In(q, ids)
+	if err != nil {
+		return err
+	}
+	query = r.db.Rebind(query)
+
+	return r.withTx(ctx, tx, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, query, args...)
+		return err
+	})
+}
+
+func (r *OutboxRepositoryImpl) Lag(ctx context.Context) (int64, *time.Time, error) {
+	var (
+		n      int64
+		oldest sql.NullTime
+	)
+	err := r.db.QueryRowxContext(ctx, `
+		SELECT COUNT(*), MIN(created_at)
+		  FROM outbox
+		 WHERE published_at IS NULL
+	`).Scan(&n, &oldest)
+	if err != nil || !oldest.Valid {
+		return n, nil, err
+	}
+	return n, &oldest.Time, nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// PricesRepository persists the destination price table.
+type PricesRepository interface {
+	ListAll(ctx context.Context) ([]model.PriceRule, error)
+	List(ctx context.Context, customerID *int64, lane model.SMSType) ([]model.PriceRule, error)
+	Upsert(ctx context.Context, r model.PriceRule) error
+	Delete(ctx context.Context, id int64) (bool, error)
+}
+
+type PricesRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewPricesRepository(db *sqlx.DB) *PricesRepositoryImpl {
+	return &PricesRepositoryImpl{db: db}
+}
+
+var _ PricesRepository = (*PricesRepositoryImpl)(nil)
+
+// ListAll returns every rule (global and overrides); the table is small and cached by callers.
+func (r *PricesRepositoryImpl) ListAll(ctx context.Context) ([]model.PriceRule, error) {
+	var rows []model.PriceRule
+	err := r.db.SelectContext(ctx, &rows, `
+		SELECT id, customer_id, prefix, lane, price, created_at, updated_at
+		  FROM price_rules
+	`)
+	return rows, err
+}
+
+// List filters rules by customer (nil = all, 0 = global only) and lane (empty = both).
+func (r *PricesRepositoryImpl) List(ctx context.Context, customerID *int64, lane model.SMSType) ([]model.PriceRule, error) {
+	q := `
+		SELECT id, customer_id, prefix, lane, price, created_at, updated_at
+		  FROM price_rules
+		 WHERE 1 = 1
+	`
+	var args []any
+	if customerID != nil {
+		q += " AND customer_id = ?"
+		args = append(args, *customerID)
+	}
+	if lane != "" {
+		q += " AND lane = ?"
+		args = append(args, lane.String())
+	}
+	q += " ORDER BY customer_id, prefix, lane"
+
+	var rows []model.PriceRule
+	err := r.db.SelectContext(ctx, &rows, q, args...)
+	return rows, err
+}
+
+// Upsert creates or replaces the rule for (customer_id, prefix, lane).
+func (r *PricesRepositoryImpl) Upsert(ctx context.Context, pr model.PriceRule) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO price_rules (customer_id, prefix, lane, price, created_at, updated_at)
+		VALUES (?, ?, ?, ?, NOW(), NOW())
+		ON DUPLICATE KEY UPDATE price = VALUES(price), updated_at = NOW()
+	`, pr.CustomerID, pr.Prefix, pr.Lane.String(), pr.Price)
+	return err
+}
+
+func (r *PricesRepositoryImpl) Delete(ctx context.Context, id int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM price_rules WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
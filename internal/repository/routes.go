@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// RoutesRepository persists the destination routing table.
+type RoutesRepository interface {
+	ListAll(ctx context.Context) ([]model.RouteRule, error)
+	List(ctx context.Context, lane model.SMSType) ([]model.RouteRule, error)
+	Upsert(ctx context.Context, r model.RouteRule) error
+	Delete(ctx context.Context, id int64) (bool, error)
+}
+
+type RoutesRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewRoutesRepository(db *sqlx.DB) *RoutesRepositoryImpl {
+	return &RoutesRepositoryImpl{db: db}
+}
+
+var _ RoutesRepository = (*RoutesRepositoryImpl)(nil)
+
+// ListAll returns every rule; the table is small and cached by callers.
+func (r *RoutesRepositoryImpl) ListAll(ctx context.Context) ([]model.RouteRule, error) {
+	return r.List(ctx, "")
+}
+
+// List filters rules by lane (empty = both).
+func (r *RoutesRepositoryImpl) List(ctx context.Context, lane model.SMSType) ([]model.RouteRule, error) {
+	q := `
+		SELECT id, prefix, lane, providers, created_at, updated_at
+		  FROM routing_rules
+	`
+	var args []any
+	if lane != "" {
+		q += " WHERE lane = ?"
+		args = append(args, lane.String())
+	}
+	q += " ORDER BY prefix, lane"
+
+	var rows []model.RouteRule
+	err := r.db.SelectContext(ctx, &rows, q, args...)
+	return rows, err
+}
+
+// Upsert creates or replaces the rule for (prefix, lane).
+func (r *RoutesRepositoryImpl) Upsert(ctx context.Context, rr model.RouteRule) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO routing_rules (prefix, lane, providers, created_at, updated_at)
+		VALUES (?, ?, ?, NOW(), NOW())
+		ON DUPLICATE KEY UPDATE providers = VALUES(providers), updated_at = NOW()
+	`, rr.Prefix, rr.Lane.String(), rr.Providers)
+	return err
+}
+
+func (r *RoutesRepositoryImpl) Delete(ctx context.Context, id int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM routing_rules WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrDuplicate is returned when a write violates a unique key.
+var ErrDuplicate = errors.New("duplicate")
+
+// TemplatesRepository persists customer message templates. Every method is scoped to a customer.
+type TemplatesRepository interface {
+	Create(ctx context.Context, t model.Template) (int64, error)
+	Get(ctx context.Context, customerID, id int64) (*model.Template, error)
+	List(ctx context.Context, customerID int64, limit, offset int) ([]model.Template, error)
+	Update(ctx context.Context, t model.Template) (bool, error)
+	Delete(ctx context.Context, customerID, id int64) (bool, error)
+}
+
+type TemplatesRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewTemplatesRepository(db *sqlx.DB) *TemplatesRepositoryImpl {
+	return &TemplatesRepositoryImpl{db: db}
+}
+
+var _ TemplatesRepository = (*TemplatesRepositoryImpl)(nil)
+
+func (r *TemplatesRepositoryImpl) Create(ctx context.Context, t model.Template) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO templates (customer_id, name, body, created_at, updated_at)
+		VALUES (?, ?, ?, NOW(), NOW())
+	`, t.CustomerID, t.Name, t.Body)
+	if err != nil {
+		return 0, mapDuplicate(err)
+	}
+	return res.LastInsertId()
+}
+
+// Get returns (nil, nil) when the template doesn't exist or belongs to another customer.
+func (r *TemplatesRepositoryImpl) Get(ctx context.Context, customerID, id int64) (*model.Template, error) {
+	var t model.Template
+	err := r.db.GetContext(ctx, &t, `
+		SELECT id, customer_id, name, body, created_at, updated_at
+		  FROM templates
+		 WHERE id = ? AND customer_id = ?
+	`, id, customerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *TemplatesRepositoryImpl) List(ctx context.Context, customerID int64, limit, offset int) ([]model.Template, error) {
+	if limit <= 0 || limit > 1000 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	var rows []model.Template
+	err := r.db.SelectContext(ctx, &rows, `
+		SELECT id, customer_id, name, body, created_at, updated_at
+		  FROM templates
+		 WHERE customer_id = ?
+		 ORDER BY id
+		 LIMIT ? OFFSET ?
+	`, customerID, limit, offset)
+	return rows, err
+}
+
+// Update replaces name and body. Returns false when the template wasn't found.
+func (r *TemplatesRepositoryImpl) Update(ctx context.Context, t model.Template) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE templates
+		   SET name = ?, body = ?, updated_at = NOW()
+		 WHERE id = ? AND customer_id = ?
+	`, t.Name, t.Body, t.ID, t.CustomerID)
+	if err != nil {
+		return false, mapDuplicate(err)
+	}
+	return r.found(ctx, t.CustomerID, t.ID, res)
+}
+
+func (r *TemplatesRepositoryImpl) Delete(ctx context.Context, customerID, id int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM templates WHERE id = ? AND customer_id = ?`, id, customerID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// found resolves an UPDATE result: zero affected rows can also mean "no change".
+func (r *TemplatesRepositoryImpl) found(ctx context.Context, customerID, id int64, res sql.Result) (bool, error) {
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		return true, nil
+	}
+	t, err := r.Get(ctx, customerID, id)
+	return t != nil, err
+}
+
+// mapDuplicate turns MySQL duplicate-key errors into ErrDuplicate.
+func mapDuplicate(err error) error {
+	var me *mysql.MySQLError
+	if errors.As(err, &me) && me.Number == 1062 {
+		return ErrDuplicate
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// UsageRepository counts messages and spend per customer and calendar day/month.
+type UsageRepository interface {
+	// Get returns usage in the periods p; tx nil reads outside a transaction.
+	Get(ctx context.Context, tx *sqlx.Tx, customerID int64, p model.Periods) (model.Usage, error)
+	// Add counts messages and spend in the periods p; negative values give usage back,
+	// never below zero.
+	Add(ctx context.Context, tx *sqlx.Tx, customerID int64, p model.Periods, messages, spend int64) error
+	// Refund gives the usage of refunded messages back to the day and month they were
+	// counted in (messages.usage_day), whatever the customer's timezone is now. Call it before the status change: failed and canceled messages are skipped,
+	// so a repeated refund is a no-op.
+	Refund(ctx context.Context, tx *sqlx.Tx, messageIDs []string) error
+}
+
+type UsageRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewUsageRepository(db *sqlx.DB) *UsageRepositoryImpl {
+	return &UsageRepositoryImpl{db: db}
+}
+
+var _ UsageRepository = (*UsageRepositoryImpl)(nil)
+
+func (r *UsageRepositoryImpl) Get(ctx context.Context, tx *sqlx.Tx, customerID int64, p model.Periods) (model.Usage, error) {
+	var q sqlx.QueryerContext = r.db
+	if tx != nil {
+		q = tx
+	}
+
+	var rows []struct {
+		Period   string `db:"period"`
+		Messages int64  `db:"messages"`
+		Spend    int64  `db:"spend"`
+	}
+	err := sqlx.SelectContext(ctx, q, &rows, `
+		SELECT period, messages, spend
+		  FROM customer_usage
+		 WHERE customer_id = ?
+		   AND ((period = 'day' AND period_start = ?) OR (period = 'month' AND period_start = ?))
+	`, customerID, p.Day, p.Month)
+	if err != nil {
+		return model.Usage{}, err
+	}
+
+	var u model.Usage
+	for _, row := range rows {
+		if row.Period == "day" {
+			u.DayMessages, u.DaySpend = row.Messages, row.Spend
+		} else {
+			u.MonthMessages, u.MonthSpend = row.Messages, row.Spend
+		}
+	}
+	return u, nil
+}
+
+func (r *UsageRepositoryImpl) Add(ctx context.Context, tx *sqlx.Tx, customerID int64, p model.Periods, messages, spend int64) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO customer_usage (customer_id, period, period_start, messages, spend, updated_at)
+		VALUES (?, 'day', ?, GREATEST(?, 0), GREATEST(?, 0), NOW()), (?, 'month', ?, GREATEST(?, 0), GREATEST(?, 0), NOW())
+		ON DUPLICATE KEY UPDATE
+		    messages   = GREATEST(messages + ?, 0),
+		    spend      = GREATEST(spend + ?, 0),
+		    updated_at = NOW()
+	`, customerID, p.Day, messages, spend, customerID, p.Month, messages, spend, messages, spend)
+	return err
+}
+
+func (r *UsageRepositoryImpl) Refund(ctx context.Context, tx *sqlx.Tx, messageIDs []string) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In(`
+		SELECT customer_id, price, DATE_FORMAT(usage_day, '%Y-%m-%d') AS usage_day
+		  FROM messages
+		 WHERE id IN (?)
+		   AND status NOT IN ('failed', 'canceled')
+	`, messageIDs)
+	if err != nil {
+		return err
+	}
+	var rows []struct {
+		CustomerID int64  `db:"customer_id"`
+		Price      int64  `db:"price"`
+		UsageDay   string `db:"usage_day"`
+	}
+	if err := tx.SelectContext(ctx, &rows, r.db.Rebind(query), args...); err != nil {
+		return err
+	}
+
+	type key struct {
+		customerID int64
+		day        string
+	}
+	type refund struct {
+		p               model.Periods
+		messages, spend int64
+	}
+	sums := make(map[key]*refund, len(rows))
+	var keys []key
+	for _, row := range rows {
+		day, err := time.Parse(time.DateOnly, row.UsageDay)
+		if err != nil {
+			return fmt.Errorf("message usage day %q: %w", row.UsageDay, err)
+		}
+		p := model.PeriodsAt(time.UTC, day)
+		k := key{row.CustomerID, p.Day}
+		if sums[k] == nil {
+			sums[k] = &refund{p: p}
+			keys = append(keys, k)
+		}
+		sums[k].messages++
+		sums[k].spend += row.Price
+	}
+	// a fixed order keeps concurrent refunds from deadlocking on the usage rows
+	slices.SortFunc(keys, func(a, b key) int {
+		return cmp.Or(cmp.Compare(a.customerID, b.customerID), cmp.Compare(a.day, b.day))
+	})
+	for _, k := range keys {
+		s := sums[k]
+		if err := r.Add(ctx, tx, k.customerID, s.p, -s.messages, -s.spend); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// LedgerFilter selects a page of a customer's ledger, newest first.
+type LedgerFilter struct {
+	CustomerID int64
+	Op         string    // empty = all ops
+	From       time.Time // inclusive; zero = unbounded
+	To         time.Time // exclusive; zero = unbounded
+	BeforeID   int64     // keyset cursor: only entries with id < BeforeID (0 = first page)
+	Limit      int
+}
+
+// WalletStatementRepository reads balances and ledger history outside the write path.
+type WalletStatementRepository interface {
+	GetAccount(ctx context.Context, customerID int64) (*model.WalletAccount, error)
+	ListLedger(ctx context.Context, f LedgerFilter) ([]model.LedgerEntry, error)
+}
+
+type WalletStatementRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewWalletStatementRepository(db *sqlx.DB) *WalletStatementRepositoryImpl {
+	return &WalletStatementRepositoryImpl{db: db}
+}
+
+var _ WalletStatementRepository = (*WalletStatementRepositoryImpl)(nil)
+
+// GetAccount returns the customer's wallet, or (nil, nil) before the first top-up or send.
+func (r *WalletStatementRepositoryImpl) GetAccount(ctx context.Context, customerID int64) (*model.WalletAccount, error) {
+	var a model.WalletAccount
+	err := r.db.GetContext(ctx, &a, `
+		SELECT customer_id, balance, reserved, created_at, updated_at
+		  FROM wallet_accounts
+		 WHERE customer_id = ?
+	`, customerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *WalletStatementRepositoryImpl) ListLedger(ctx context.Context, f LedgerFilter) ([]model.LedgerEntry, error) {
+	q, args := ledgerQuery(`
+		SELECT id, customer_id, op, amount, message_id, created_at
+		  FROM wallet_ledger
+		 WHERE customer_id = ?
+	`, f)
+
+	var rows []model.LedgerEntry
+	err := r.db.SelectContext(ctx, &rows, q, args...)
+	return rows, err
+}
+
+// ledgerQuery appends the filter to base (which must end in a customer_id predicate);
+// shared by the MySQL and ClickHouse statements.
+func ledgerQuery(base string, f LedgerFilter) (string, []any) {
+	q := base
+	args := []any{f.CustomerID}
+	if f.Op != "" {
+		q += " AND op = ?"
+		args = append(args, f.Op)
+	}
+	if !f.From.IsZero() {
+		q += " AND created_at >= ?"
+		args = append(args, f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		q += " AND created_at < ?"
+		args = append(args, f.To.UTC())
+	}
+	if f.BeforeID > 0 {
+		q += " AND id < ?"
+		args = append(args, f.BeforeID)
+	}
+	q += " ORDER BY id DESC LIMIT ?"
+	args = append(args, f.Limit)
+	return q, args
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// WebhooksRepository is the durable queue of customer status webhooks.
+type WebhooksRepository interface {
+	// EnqueueForMessages queues one event per message at its current status, for messages
+	// whose customer (or the message itself) has a webhook URL. Must run in the tx that
+	// changed the status so events are never lost or emitted for rolled-back changes.
+	EnqueueForMessages(ctx context.Context, tx *sqlx.Tx, ids []string) error
+	// ClaimDue leases up to limit due deliveries for lease, so other replicas skip them.
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]ClaimedDelivery, error)
+	// RecordAttempt stores an attempt and moves the delivery to its next state.
+	RecordAttempt(ctx context.Context, a model.WebhookAttempt, state model.WebhookState, nextAttemptAt time.Time) error
+	ListByCustomer(ctx context.Context, customerID int64, messageID string, limit int) ([]model.WebhookDelivery, error)
+	ListAttempts(ctx context.Context, deliveryIDs []int64) ([]model.WebhookAttempt, error)
+}
+
+// ClaimedDelivery is a leased delivery along with the customer's current signing secret.
+type ClaimedDelivery struct {
+	model.WebhookDelivery
+	Secret *string `db:"secret"`
+}
+
+type WebhooksRepositoryImpl struct {
+	db *sqlx.DB
+}
+
+func NewWebhooksRepository(db *sqlx.DB) *WebhooksRepositoryImpl {
+	return &WebhooksRepositoryImpl{db: db}
+}
+
+var _ WebhooksRepository = (*WebhooksRepositoryImpl)(nil)
+
+func (r *WebhooksRepositoryImpl) EnqueueForMessages(ctx context.Context, tx *sqlx.Tx, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	const base = `
+		INSERT IGNORE INTO webhook_deliveries
+		    (customer_id, message_id, status_event, url, payload, state, next_attempt_at, created_at, updated_at)
+		SELECT m.customer_id, m.id, m.status, COALESCE(m.callback_url, c.webhook_url),
+		       JSON_OBJECT(
+		           'event',       'message.status',
+		           'id',          m.id,
+		           'status',      m.status,
+		           'type',        m.type,
+		           'phone',       m.phone,
+		           'provider',    m.provider,
+		           'dlr_at',      m.dlr_at,
+		           'updated_at',  m.updated_at
+		       ),
+		       'pending', NOW(), NOW(), NOW()
+		  FROM messages m
+		  JOIN customers c ON c.id = m.customer_id
+		 WHERE m.id IN (?)
+		   AND COALESCE(m.callback_url, c.webhook_url) IS NOT NULL
+		   AND c.webhook_secret <> ''
+	`
+	query, args, err := sqlx.In(base, ids)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, tx.Rebind(query), args...)
+	return err
+}
+
+func (r *WebhooksRepositoryImpl) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]ClaimedDelivery, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var ids []int64
+	if err := tx.SelectContext(ctx, &ids, `
+		SELECT id
+		  FROM webhook_deliveries
+		 WHERE state = 'pending' AND next_attempt_at <= NOW()
+		 ORDER BY next_attempt_at
+		 LIMIT ?
+		   FOR UPDATE SKIP LOCKED
+	`, limit); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query, args, err := sqlx.In(`
+		UPDATE webhook_deliveries
+		   SET next_attempt_at = NOW() + INTERVAL ? SECOND
+		 WHERE id IN (?)
+	`, int(lease.Seconds()), ids)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, tx.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	query, args, err = sqlx.In(`
+		SELECT d.id, d.customer_id, d.message_id, d.status_event, d.url, d.payload, d.state,
+		       d.attempts, d.next_attempt_at, d.last_status_code, d.last_error, d.created_at, d.updated_at,
+		       c.webhook_secret AS secret
+		  FROM webhook_deliveries d
+		  JOIN customers c ON c.id = d.customer_id
+		 WHERE d.id IN (?)
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	var out []ClaimedDelivery
+	if err := r.db.SelectContext(ctx, &out, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *WebhooksRepositoryImpl) RecordAttempt(ctx context.Context, a model.WebhookAttempt, state model.WebhookState, nextAttemptAt time.Time) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO webhook_attempts (delivery_id, attempt, response_code, response_body, error, duration_ms, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, NOW())
+	`, a.DeliveryID, a.Attempt, a.ResponseCode, a.ResponseBody, a.Error, a.DurationMs); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		   SET state = ?, attempts = ?, next_attempt_at = ?,
+		       last_status_code = ?, last_error = ?, updated_at = NOW()
+		 WHERE id = ?
+	`, string(state), a.Attempt, nextAttemptAt, a.ResponseCode, a.Error, a.DeliveryID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *WebhooksRepositoryImpl) ListByCustomer(ctx context.Context, customerID int64, messageID string, limit int) ([]model.WebhookDelivery, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	q := `
+		SELECT id, customer_id, message_id, status_event, url, payload, state,
+		       attempts, next_attempt_at, last_status_code, last_error, created_at, updated_at
+		  FROM webhook_deliveries
+		 WHERE customer_id = ?
+	`
+	args := []any{customerID}
+	if messageID != "" {
+		q += " AND message_id = ?"
+		args = append(args, messageID)
+	}
+	q += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	var rows []model.WebhookDelivery
+	if err := r.db.SelectContext(ctx, &rows, q, args...); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *WebhooksRepositoryImpl) ListAttempts(ctx context.Context, deliveryIDs []int64) ([]model.WebhookAttempt, error) {
+	if len(deliveryIDs) == 0 {
+		return nil, nil
+	}
+	query, args, err := sqlx.In(`
+		SELECT id, delivery_id, attempt, response_code, response_body, error, duration_ms, created_at
+		  FROM webhook_attempts
+		 WHERE delivery_id IN (?)
+		 ORDER BY delivery_id, attempt
+	`, deliveryIDs)
+	if err != nil {
+		return nil, err
+	}
+	var rows []model.WebhookAttempt
+	if err := r.db.SelectContext(ctx, &rows, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
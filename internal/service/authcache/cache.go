@@ -0,0 +1,260 @@
+package authcache
+
+import (
+	"container/list"
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/metrics"
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+// Channel carries invalidations between API nodes: "c:<customer id>" drops every key of a
+// customer, "k:<prefix>" drops one key.
+const Channel = "auth:invalidate"
+
+// Options bounds the cache. Zero values fall back to defaults.
+type Options struct {
+	Size        int           // positive entries kept; least recently used go first
+	TTL         time.Duration // how long a found key is trusted without an invalidation
+	NegativeTTL time.Duration // how long an unknown prefix is remembered
+}
+
+// Cache sits in front of APIKeysRepository.GetCredential, which APIKeyMiddleware calls on
+// every request. Found keys are kept for TTL and unknown prefixes for NegativeTTL in separate
+// LRUs, so a flood of bogus keys cannot push out real ones. Changes made through the admin API
+// are published on Channel and dropped on every node as soon as they arrive; TTL only bounds
+// staleness when a message is lost.
+type Cache struct {
+	repository.APIKeysRepository
+	rdb  *redis.Client
+	opts Options
+
+	mu       sync.Mutex
+	found    *lru
+	notFound *lru
+	gen      uint64 // bumped by every invalidation; loads that straddle one are not stored
+}
+
+var _ repository.APIKeysRepository = (*Cache)(nil)
+
+// New wraps keys. rdb may be nil, in which case invalidations only apply to this process.
+func New(keys repository.APIKeysRepository, rdb *redis.Client, opts Options) *Cache {
+	if opts.Size <= 0 {
+		opts.Size = 10000
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = 30 * time.Second
+	}
+	if opts.NegativeTTL <= 0 {
+		opts.NegativeTTL = 5 * time.Second
+	}
+	return &Cache{
+		APIKeysRepository: keys,
+		rdb:               rdb,
+		opts:              opts,
+		found:             newLRU(opts.Size),
+		notFound:          newLRU(max(opts.Size/4, 1)),
+	}
+}
+
+// GetCredential returns the cached credential for prefix, loading it on a miss.
+func (c *Cache) GetCredential(ctx context.Context, prefix string) (*model.Credential, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	if cred, ok := c.found.get(prefix, now); ok {
+		c.mu.Unlock()
+		metrics.AuthCacheRequestsTotal.WithLabelValues("hit").Inc()
+		cp := *cred
+		return &cp, nil
+	}
+	if _, ok := c.notFound.get(prefix, now); ok {
+		c.mu.Unlock()
+		metrics.AuthCacheRequestsTotal.WithLabelValues("negative_hit").Inc()
+		return nil, nil
+	}
+	gen := c.gen
+	c.mu.Unlock()
+	metrics.AuthCacheRequestsTotal.WithLabelValues("miss").Inc()
+
+	cred, err := c.APIKeysRepository.GetCredential(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.gen == gen {
+		if cred != nil {
+			cp := *cred
+			c.found.put(prefix, &cp, now.Add(c.opts.TTL))
+		} else {
+			c.notFound.put(prefix, nil, now.Add(c.opts.NegativeTTL))
+		}
+	}
+	c.mu.Unlock()
+	return cred, nil
+}
+
+// CustomerChanged drops the customer's keys here and on every other node.
+func (c *Cache) CustomerChanged(ctx context.Context, customerID int64) {
+	c.dropCustomer(customerID)
+	c.publish(ctx, "c:"+strconv.FormatInt(customerID, 10))
+}
+
+// KeyChanged drops one key here and on every other node.
+func (c *Cache) KeyChanged(ctx context.Context, prefix string) {
+	c.dropKey(prefix)
+	c.publish(ctx, "k:"+prefix)
+}
+
+func (c *Cache) publish(ctx context.Context, msg string) {
+	if c.rdb == nil {
+		return
+	}
+	if err := c.rdb.Publish(ctx, Channel, msg).Err(); err != nil {
+		log.Printf("authcache: publish %q failed, other nodes catch up within %s: %v", msg, c.opts.TTL, err)
+	}
+}
+
+// Run applies invalidations from other nodes until ctx is done. Whenever the subscription
+// is (re)established everything is dropped, since messages sent meanwhile are lost.
+func (c *Cache) Run(ctx context.Context) {
+	if c.rdb == nil {
+		return
+	}
+	ps := c.rdb.Subscribe(ctx, Channel)
+	defer func() { _ = ps.Close() }()
+
+	for {
+		msg, err := ps.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("authcache: subscription error: %v", err)
+			c.dropAll()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			if m.Kind == "subscribe" {
+				c.dropAll()
+			}
+		case *redis.Message:
+			c.apply(m.Payload)
+		}
+	}
+}
+
+func (c *Cache) apply(payload string) {
+	kind, arg, ok := strings.Cut(payload, ":")
+	if !ok {
+		return
+	}
+	switch kind {
+	case "c":
+		if id, err := strconv.ParseInt(arg, 10, 64); err == nil {
+			c.dropCustomer(id)
+		}
+	case "k":
+		c.dropKey(arg)
+	}
+}
+
+func (c *Cache) dropCustomer(customerID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.found.removeIf(func(cred *model.Credential) bool { return cred.CustomerID == customerID })
+}
+
+func (c *Cache) dropKey(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.found.remove(prefix)
+	c.notFound.remove(prefix)
+}
+
+func (c *Cache) dropAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.found = newLRU(c.found.size)
+	c.notFound = newLRU(c.notFound.size)
+}
+
+// lru is a size-bounded map with per-entry expiry. Not safe for concurrent use.
+type lru struct {
+	size  int
+	order *list.List // front = most recently used
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	cred    *model.Credential
+	expires time.Time
+}
+
+func newLRU(size int) *lru {
+	return &lru{size: size, order: list.New(), items: map[string]*list.Element{}}
+}
+
+func (l *lru) get(key string, now time.Time) (*model.Credential, bool) {
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*lruEntry)
+	if !now.Before(e.expires) {
+		l.order.Remove(el)
+		delete(l.items, key)
+		return nil, false
+	}
+	l.order.MoveToFront(el)
+	return e.cred, true
+}
+
+func (l *lru) put(key string, cred *model.Credential, expires time.Time) {
+	if el, ok := l.items[key]; ok {
+		el.Value = &lruEntry{key: key, cred: cred, expires: expires}
+		l.order.MoveToFront(el)
+		return
+	}
+	l.items[key] = l.order.PushFront(&lruEntry{key: key, cred: cred, expires: expires})
+	for l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (l *lru) remove(key string) {
+	if el, ok := l.items[key]; ok {
+		l.order.Remove(el)
+		delete(l.items, key)
+	}
+}
+
+func (l *lru) removeIf(match func(*model.Credential) bool) {
+	for key, el := range l.items {
+		if e := el.Value.(*lruEntry); e.cred != nil && match(e.cred) {
+			l.order.Remove(el)
+			delete(l.items, key)
+		}
+	}
+}
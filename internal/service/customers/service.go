@@ -0,0 +1,493 @@
+package customers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmehdipour/sms-gateway/internal/util"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	StatusActive    = "active"
+	StatusSuspended = "suspended"
+)
+
+// maxRotateGrace bounds how long a rotated key keeps working next to its replacement.
+const maxRotateGrace = 7 * 24 * time.Hour
+
+var (
+	ErrNotFound      = errors.New("customer not found")
+	ErrKeyNotFound   = errors.New("api key not found")
+	ErrKeyRevoked    = errors.New("api key already revoked")
+	ErrInvalidStatus = errors.New("invalid status transition")
+	ErrInvalidKey    = errors.New("invalid api key spec")
+)
+
+// Actor identifies the operator behind an admin change.
+type Actor struct {
+	Name     string
+	RemoteIP string
+}
+
+// Update carries the admin-editable fields; nil leaves a field unchanged.
+type Update struct {
+	Name *string
+	// RateLimitRPS <= 0 clears the override and falls back to rate_limit.rps;
+	// RateLimitBurst likewise.
+	RateLimitRPS   *int
+	RateLimitBurst *int
+	Timezone       *string
+	// Caps <= 0 removes the cap.
+	DailyMessages   *int64
+	MonthlyMessages *int64
+	DailySpend      *int64
+	MonthlySpend    *int64
+}
+
+// KeySpec describes a new API key. Empty Scopes grants all; empty AllowedIPs allows any address.
+type KeySpec struct {
+	Label      string
+	Scopes     []string
+	AllowedIPs []string // IPs or CIDRs
+	ExpiresAt  *time.Time
+}
+
+// Notifier is told after a change commits so API nodes drop cached credentials.
+type Notifier interface {
+	CustomerChanged(ctx context.Context, customerID int64)
+	KeyChanged(ctx context.Context, prefix string)
+}
+
+// Service manages the customer lifecycle. Every change is written together with its
+// admin_audit_log entry in one transaction.
+type Service struct {
+	db        *sqlx.DB
+	customers repository.CustomersRepository
+	wallet    repository.WalletRepository
+	keys      repository.APIKeysRepository
+	audit     repository.AuditRepository
+	notify    Notifier
+}
+
+// New constructs the customer lifecycle service.
+func New(
+	db *sqlx.DB,
+	customersRepo repository.CustomersRepository,
+	walletRepo repository.WalletRepository,
+	keysRepo repository.APIKeysRepository,
+	auditRepo repository.AuditRepository,
+	notify Notifier,
+) *Service {
+	return &Service{db: db, customers: customersRepo, wallet: walletRepo, keys: keysRepo, audit: auditRepo, notify: notify}
+}
+
+// Create registers an active customer with an empty wallet and a "default" key with all
+// scopes. The key is returned once; only its hash is kept.
+func (s *Service) Create(ctx context.Context, actor Actor, name string, rps, burst *int) (*model.Customer, string, error) {
+	c := model.Customer{Name: name, Status: StatusActive, RateLimitRPS: normLimit(rps), RateLimitBurst: normLimit(burst)}
+	var key string
+
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		id, err := s.customers.Create(ctx, tx, c)
+		if err != nil {
+			return err
+		}
+		c.ID = id
+		if err := s.wallet.UpsertAccount(ctx, tx, id); err != nil {
+			return err
+		}
+		if err := s.record(ctx, tx, actor, "customer.create", id, map[string]any{
+			"name":             c.Name,
+			"rate_limit_rps":   c.RateLimitRPS,
+			"rate_limit_burst": c.RateLimitBurst,
+		}); err != nil {
+			return err
+		}
+		key, _, err = s.issueKey(ctx, tx, actor, id, KeySpec{Label: "default"})
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	cust, err := s.customers.GetByID(ctx, c.ID)
+	return cust, key, err
+}
+
+// Update changes name, rate limits, timezone and caps. Only fields that actually change are audited.
+func (s *Service) Update(ctx context.Context, actor Actor, id int64, u Update) (*model.Customer, error) {
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		c, err := s.customers.GetForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if c == nil {
+			return ErrNotFound
+		}
+
+		changes := map[string]any{}
+		if u.Name != nil && *u.Name != c.Name {
+			changes["name"] = change(c.Name, *u.Name)
+			c.Name = *u.Name
+		}
+		if u.RateLimitRPS != nil {
+			next := normLimit(u.RateLimitRPS)
+			if !sameLimit(c.RateLimitRPS, next) {
+				changes["rate_limit_rps"] = change(c.RateLimitRPS, next)
+				c.RateLimitRPS = next
+			}
+		}
+		if u.RateLimitBurst != nil {
+			next := normLimit(u.RateLimitBurst)
+			if !sameLimit(c.RateLimitBurst, next) {
+				changes["rate_limit_burst"] = change(c.RateLimitBurst, next)
+				c.RateLimitBurst = next
+			}
+		}
+		if u.Timezone != nil && *u.Timezone != c.Timezone {
+			changes["timezone"] = change(c.Timezone, *u.Timezone)
+			c.Timezone = *u.Timezone
+		}
+		caps := []struct {
+			name string
+			cur  **int64
+			next *int64
+		}{
+			{"daily_message_cap", &c.DailyMessages, u.DailyMessages},
+			{"monthly_message_cap", &c.MonthlyMessages, u.MonthlyMessages},
+			{"daily_spend_cap", &c.DailySpend, u.DailySpend},
+			{"monthly_spend_cap", &c.MonthlySpend, u.MonthlySpend},
+		}
+		for _, cp := range caps {
+			if cp.next == nil {
+				continue
+			}
+			next := normCap(cp.next)
+			if !sameLimit(*cp.cur, next) {
+				changes[cp.name] = change(*cp.cur, next)
+				*cp.cur = next
+			}
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+
+		if err := s.customers.Update(ctx, tx, *c); err != nil {
+			return err
+		}
+		return s.record(ctx, tx, actor, "customer.update", id, changes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.notify.CustomerChanged(ctx, id)
+	return s.customers.GetByID(ctx, id)
+}
+
+// Suspend blocks the customer's API key. Suspending a suspended customer is an error.
+func (s *Service) Suspend(ctx context.Context, actor Actor, id int64, reason string) (*model.Customer, error) {
+	return s.setStatus(ctx, actor, id, StatusActive, StatusSuspended, "customer.suspend", reason)
+}
+
+// Reactivate lifts a suspension.
+func (s *Service) Reactivate(ctx context.Context, actor Actor, id int64, reason string) (*model.Customer, error) {
+	return s.setStatus(ctx, actor, id, StatusSuspended, StatusActive, "customer.reactivate", reason)
+}
+
+func (s *Service) setStatus(ctx context.Context, actor Actor, id int64, from, to, action, reason string) (*model.Customer, error) {
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		c, err := s.customers.GetForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if c == nil {
+			return ErrNotFound
+		}
+		if c.Status != from {
+			return ErrInvalidStatus
+		}
+		if err := s.customers.SetStatus(ctx, tx, id, to); err != nil {
+			return err
+		}
+		details := map[string]any{"status": change(from, to)}
+		if reason != "" {
+			details["reason"] = reason
+		}
+		return s.record(ctx, tx, actor, action, id, details)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.notify.CustomerChanged(ctx, id)
+	return s.customers.GetByID(ctx, id)
+}
+
+// Keys lists a customer's API keys, revoked ones included.
+func (s *Service) Keys(ctx context.Context, customerID int64) ([]model.APIKey, error) {
+	c, err := s.customers.GetByID(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, ErrNotFound
+	}
+	return s.keys.ListByCustomer(ctx, customerID)
+}
+
+// CreateKey issues an additional key. The key is returned once; only its hash is kept.
+func (s *Service) CreateKey(ctx context.Context, actor Actor, customerID int64, spec KeySpec) (string, *model.APIKey, error) {
+	var (
+		key string
+		k   *model.APIKey
+	)
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		c, err := s.customers.GetForUpdate(ctx, tx, customerID)
+		if err != nil {
+			return err
+		}
+		if c == nil {
+			return ErrNotFound
+		}
+		key, k, err = s.issueKey(ctx, tx, actor, customerID, spec)
+		return err
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return key, k, nil
+}
+
+// RotateKey issues a replacement with the same label, scopes, allowlist and expiry. The old
+// key is revoked now, or keeps working for grace so clients can switch without downtime.
+func (s *Service) RotateKey(ctx context.Context, actor Actor, customerID, keyID int64, grace time.Duration) (string, *model.APIKey, error) {
+	if grace < 0 || grace > maxRotateGrace {
+		return "", nil, ErrInvalidKey
+	}
+	var (
+		key, oldPrefix string
+		k              *model.APIKey
+	)
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		old, err := s.keys.GetForUpdate(ctx, tx, customerID, keyID)
+		if err != nil {
+			return err
+		}
+		if old == nil {
+			return ErrKeyNotFound
+		}
+		if old.RevokedAt != nil {
+			return ErrKeyRevoked
+		}
+		oldPrefix = old.Prefix
+
+		spec := KeySpec{Label: old.Label, Scopes: old.ScopeList(), AllowedIPs: old.AllowedIPList(), ExpiresAt: old.ExpiresAt}
+		if key, k, err = s.issueKey(ctx, tx, actor, customerID, spec); err != nil {
+			return err
+		}
+
+		details := map[string]any{"prefix": old.Prefix, "replaced_by": k.Prefix}
+		if grace > 0 {
+			until := time.Now().Add(grace)
+			if old.ExpiresAt == nil || until.Before(*old.ExpiresAt) {
+				if err := s.keys.SetExpiry(ctx, tx, old.ID, until); err != nil {
+					return err
+				}
+			}
+			details["grace_until"] = until
+		} else if err := s.keys.Revoke(ctx, tx, old.ID); err != nil {
+			return err
+		}
+		return s.record(ctx, tx, actor, "api_key.rotate", customerID, details)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	s.notify.KeyChanged(ctx, oldPrefix)
+	return key, k, nil
+}
+
+// RevokeKey disables a key immediately.
+func (s *Service) RevokeKey(ctx context.Context, actor Actor, customerID, keyID int64) error {
+	var prefix string
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		k, err := s.keys.GetForUpdate(ctx, tx, customerID, keyID)
+		if err != nil {
+			return err
+		}
+		if k == nil {
+			return ErrKeyNotFound
+		}
+		if k.RevokedAt != nil {
+			return ErrKeyRevoked
+		}
+		prefix = k.Prefix
+		if err := s.keys.Revoke(ctx, tx, k.ID); err != nil {
+			return err
+		}
+		return s.record(ctx, tx, actor, "api_key.revoke", customerID, map[string]any{"prefix": k.Prefix, "label": k.Label})
+	})
+	if err != nil {
+		return err
+	}
+	s.notify.KeyChanged(ctx, prefix)
+	return nil
+}
+
+// issueKey validates spec, stores a new key and audits it. Prefix collisions are retried.
+func (s *Service) issueKey(ctx context.Context, tx *sqlx.Tx, actor Actor, customerID int64, spec KeySpec) (string, *model.APIKey, error) {
+	k, err := keyOf(customerID, spec)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var key string
+	for attempt := 0; ; attempt++ {
+		key, k.Prefix = util.NewAPIKey()
+		k.SecretHash = util.HashAPIKey(key)
+		k.ID, err = s.keys.Create(ctx, tx, k)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, repository.ErrDuplicate) || attempt == 2 {
+			return "", nil, err
+		}
+	}
+
+	details := map[string]any{"prefix": k.Prefix, "label": k.Label, "scopes": k.ScopeList()}
+	if ips := k.AllowedIPList(); len(ips) > 0 {
+		details["allowed_ips"] = ips
+	}
+	if k.ExpiresAt != nil {
+		details["expires_at"] = *k.ExpiresAt
+	}
+	if err := s.record(ctx, tx, actor, "api_key.create", customerID, details); err != nil {
+		return "", nil, err
+	}
+	k.CreatedAt = time.Now()
+	return key, &k, nil
+}
+
+// keyOf validates spec: known scopes, parseable IPs/CIDRs (stored as CIDRs) and a future expiry.
+func keyOf(customerID int64, spec KeySpec) (model.APIKey, error) {
+	k := model.APIKey{CustomerID: customerID, Label: strings.TrimSpace(spec.Label)}
+	if len(k.Label) > 64 {
+		return k, ErrInvalidKey
+	}
+
+	scopes := spec.Scopes
+	if len(scopes) == 0 {
+		scopes = model.AllScopes
+	}
+	seen := map[string]bool{}
+	var list []string
+	for _, sc := range scopes {
+		if !model.ValidScope(sc) {
+			return k, ErrInvalidKey
+		}
+		if !seen[sc] {
+			seen[sc] = true
+			list = append(list, sc)
+		}
+	}
+	k.Scopes = strings.Join(list, ",")
+
+	if len(spec.AllowedIPs) > 0 {
+		cidrs := make([]string, 0, len(spec.AllowedIPs))
+		for _, v := range spec.AllowedIPs {
+			p, err := parseCIDR(strings.TrimSpace(v))
+			if err != nil {
+				return k, ErrInvalidKey
+			}
+			cidrs = append(cidrs, p.String())
+		}
+		joined := strings.Join(cidrs, ",")
+		if len(joined) > 1024 {
+			return k, ErrInvalidKey
+		}
+		k.AllowedIPs = &joined
+	}
+
+	if spec.ExpiresAt != nil {
+		if !spec.ExpiresAt.After(time.Now()) {
+			return k, ErrInvalidKey
+		}
+		at := spec.ExpiresAt.UTC().Truncate(time.Second)
+		k.ExpiresAt = &at
+	}
+	return k, nil
+}
+
+// parseCIDR accepts "10.0.0.0/8" or a bare address, which becomes a single-host prefix.
+func parseCIDR(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return p.Masked(), nil
+	}
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	a = a.Unmap()
+	return netip.PrefixFrom(a, a.BitLen()), nil
+}
+
+func (s *Service) inTx(ctx context.Context, fn func(*sqlx.Tx) error) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Service) record(ctx context.Context, tx *sqlx.Tx, actor Actor, action string, customerID int64, details map[string]any) error {
+	e := model.AuditEntry{Actor: actor.Name, RemoteIP: actor.RemoteIP, Action: action, CustomerID: &customerID}
+	if len(details) > 0 {
+		b, err := json.Marshal(details)
+		if err != nil {
+			return err
+		}
+		d := string(b)
+		e.Details = &d
+	}
+	return s.audit.Insert(ctx, tx, e)
+}
+
+func change(from, to any) map[string]any { return map[string]any{"from": from, "to": to} }
+
+// normLimit maps a non-positive limit to nil (no per-customer override).
+func normLimit(n *int) *int {
+	if n == nil || *n <= 0 {
+		return nil
+	}
+	v := *n
+	return &v
+}
+
+// normCap maps a non-positive cap to nil (no cap).
+func normCap(n *int64) *int64 {
+	if n == nil || *n <= 0 {
+		return nil
+	}
+	v := *n
+	return &v
+}
+
+func sameLimit[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
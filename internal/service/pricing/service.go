@@ -0,0 +1,206 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+// Channel carries price table invalidations between nodes; every message drops the table.
+const Channel = "pricing:invalidate"
+
+// ErrUnavailable means the price table has never loaded. Pricing from the lane defaults
+// instead would undercharge every customer with an override, so callers must not proceed.
+var ErrUnavailable = errors.New("pricing: price table not loaded")
+
+type ruleKey struct {
+	customerID int64
+	lane       model.SMSType
+	prefix     string
+}
+
+// Service resolves the per-segment price of a destination by longest-prefix match over
+// the price_rules table. A customer's own rules are tried before the global ones; when
+// nothing matches, the lane default from config applies. Rules are cached in memory and
+// reloaded every refresh interval; admin changes are published on Channel so every node
+// reloads on its next lookup.
+type Service struct {
+	repo         repository.PricesRepository
+	rdb          *redis.Client
+	priceNormal  int64
+	priceExpress int64
+	refresh      time.Duration
+
+	load sync.Mutex // one reload at a time, taken without mu so lookups never wait on the DB
+
+	mu       sync.RWMutex
+	rules    map[ruleKey]int64
+	loadedAt time.Time
+	gen      uint64 // bumped by every invalidation; a load that straddles one is not trusted
+}
+
+// New constructs the pricing service. Defaults are the lane prices used when no rule matches.
+// rdb may be nil, in which case invalidations only apply to this process.
+func New(repo repository.PricesRepository, rdb *redis.Client, priceNormal, priceExpress int64, refresh time.Duration) *Service {
+	if refresh <= 0 {
+		refresh = 30 * time.Second
+	}
+	return &Service{
+		repo:         repo,
+		rdb:          rdb,
+		priceNormal:  priceNormal,
+		priceExpress: priceExpress,
+		refresh:      refresh,
+	}
+}
+
+// PerSegment returns the price of one segment to phone on lane for customerID.
+func (s *Service) PerSegment(ctx context.Context, customerID int64, phone string, lane model.SMSType) (int64, error) {
+	rules, err := s.table(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	owners := []int64{0}
+	if customerID != 0 {
+		owners = []int64{customerID, 0}
+	}
+	for _, cust := range owners {
+		for l := len(phone); l > 0; l-- {
+			if p, ok := rules[ruleKey{customerID: cust, lane: lane, prefix: phone[:l]}]; ok {
+				return p, nil
+			}
+		}
+	}
+
+	if lane == model.SMSTypeExpress {
+		return s.priceExpress, nil
+	}
+	return s.priceNormal, nil
+}
+
+// PriceOf returns the price of a whole message: per-segment price times segments.
+func (s *Service) PriceOf(ctx context.Context, customerID int64, phone string, lane model.SMSType, segments int) (int64, error) {
+	p, err := s.PerSegment(ctx, customerID, phone, lane)
+	return p * int64(segments), err
+}
+
+// Invalidate forces the next lookup to reload rules, here and on every other node
+// (after admin changes).
+func (s *Service) Invalidate(ctx context.Context) {
+	s.drop()
+	if s.rdb == nil {
+		return
+	}
+	if err := s.rdb.Publish(ctx, Channel, "all").Err(); err != nil {
+		log.Printf("[pricing] publish invalidation failed, other nodes catch up within %s: %v", s.refresh, err)
+	}
+}
+
+// Run applies invalidations from other nodes until ctx is done. Whenever the subscription
+// is (re)established the table is dropped, since messages sent meanwhile are lost.
+func (s *Service) Run(ctx context.Context) {
+	if s.rdb == nil {
+		return
+	}
+	ps := s.rdb.Subscribe(ctx, Channel)
+	defer func() { _ = ps.Close() }()
+
+	for {
+		msg, err := ps.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[pricing] subscription error: %v", err)
+			s.drop()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			if m.Kind == "subscribe" {
+				s.drop()
+			}
+		case *redis.Message:
+			s.drop()
+		}
+	}
+}
+
+func (s *Service) drop() {
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.gen++
+	s.mu.Unlock()
+}
+
+// table returns the cached rules, reloading them when stale. The rules are read outside
+// mu and swapped in, so lookups with a fresh table never wait on the DB. On load errors the
+// previous table is kept so pricing never blocks on a DB hiccup; ErrUnavailable is only
+// returned while no table has loaded at all.
+func (s *Service) table(ctx context.Context) (map[ruleKey]int64, error) {
+	if rules, fresh := s.cached(); fresh {
+		return rules, tableErr(rules)
+	}
+
+	s.load.Lock()
+	defer s.load.Unlock()
+	rules, fresh := s.cached()
+	if fresh { // another goroutine reloaded meanwhile
+		return rules, tableErr(rules)
+	}
+
+	s.mu.RLock()
+	gen := s.gen
+	s.mu.RUnlock()
+
+	rows, err := s.repo.ListAll(ctx)
+	if err != nil {
+		log.Printf("[pricing] reload err: %v", err)
+		s.mu.Lock()
+		if s.gen == gen {
+			s.loadedAt = time.Now().Add(-s.refresh + time.Second) // retry in ~1s
+		}
+		s.mu.Unlock()
+		return rules, tableErr(rules)
+	}
+
+	next := make(map[ruleKey]int64, len(rows))
+	for _, r := range rows {
+		next[ruleKey{customerID: r.CustomerID, lane: r.Lane, prefix: r.Prefix}] = r.Price
+	}
+
+	s.mu.Lock()
+	s.rules = next
+	if s.gen == gen { // an invalidation during the load means it may have missed the change
+		s.loadedAt = time.Now()
+	}
+	s.mu.Unlock()
+	return next, nil
+}
+
+// cached returns the current table and whether it is within the refresh interval.
+func (s *Service) cached() (map[ruleKey]int64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rules, time.Since(s.loadedAt) < s.refresh
+}
+
+func tableErr(rules map[ruleKey]int64) error {
+	if rules == nil {
+		return ErrUnavailable
+	}
+	return nil
+}
@@ -2,12 +2,16 @@ package queue
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jmehdipour/sms-gateway/internal/model"
 	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmehdipour/sms-gateway/internal/service/pricing"
 	"github.com/jmehdipour/sms-gateway/internal/util"
 	"github.com/jmoiron/sqlx"
 )
@@ -15,20 +19,46 @@ import (
 const (
 	NormalSMSKafkaTopic  = "sms.normal"
 	ExpressSMSKafkaTopic = "sms.express"
+
+	// bulkChunkSize bounds rows per multi-row INSERT (messages/outbox) in EnqueueBulk.
+	bulkChunkSize = 500
 )
 
-var ErrInsufficientFunds = errors.New("insufficient funds")
+var (
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrMessageNotFound   = errors.New("message not found")
+	ErrNotScheduled      = errors.New("message is not scheduled")
+	// ErrIdempotencyConflict means the idempotency key was already used for a different request.
+	ErrIdempotencyConflict = errors.New("idempotency key reused with a different request")
+	// ErrCapExceeded is matched by every *CapError.
+	ErrCapExceeded = errors.New("usage cap exceeded")
+)
+
+// CapError rejects a send that would take the customer past one of its caps.
+type CapError struct {
+	Cap      string // model.CapDailyMessages, ...
+	Limit    int64
+	Used     int64
+	ResetsAt time.Time
+}
+
+func (e *CapError) Error() string {
+	return fmt.Sprintf("%s cap of %d reached (used %d)", e.Cap, e.Limit, e.Used)
+}
+
+func (e *CapError) Is(target error) bool { return target == ErrCapExceeded }
 
 // Service atomically persists messages, wallet reserve, ledger events, and outbox events.
 type Service struct {
-	db     *sqlx.DB
-	msgs   repository.MessagesRepository
-	outbox repository.OutboxRepository
-	wallet repository.WalletRepository
-	ledger repository.LedgerRepository
-
-	priceNormal  int64
-	priceExpress int64
+	db        *sqlx.DB
+	msgs      repository.MessagesRepository
+	outbox    repository.OutboxRepository
+	wallet    repository.WalletRepository
+	ledger    repository.LedgerRepository
+	customers repository.CustomersRepository
+	usage     repository.UsageRepository
+	webhooks  repository.WebhooksRepository
+	prices    *pricing.Service
 }
 
 // New constructs the queue service.
@@ -38,33 +68,184 @@ func New(
 	outboxRepo repository.OutboxRepository,
 	walletRepo repository.WalletRepository,
 	ledgerRepo repository.LedgerRepository,
-	priceNormal int64,
-	priceExpress int64,
+	customersRepo repository.CustomersRepository,
+	usageRepo repository.UsageRepository,
+	webhooksRepo repository.WebhooksRepository,
+	prices *pricing.Service,
 ) *Service {
 	return &Service{
-		db:           db,
-		msgs:         messagesRepo,
-		outbox:       outboxRepo,
-		wallet:       walletRepo,
-		ledger:       ledgerRepo,
-		priceNormal:  priceNormal,
-		priceExpress: priceExpress,
+		db:        db,
+		msgs:      messagesRepo,
+		outbox:    outboxRepo,
+		wallet:    walletRepo,
+		ledger:    ledgerRepo,
+		customers: customersRepo,
+		usage:     usageRepo,
+		webhooks:  webhooksRepo,
+		prices:    prices,
 	}
 }
 
-func (s *Service) priceOf(t model.SMSType) int64 {
+// TopicOf is the Kafka topic of a lane.
+func TopicOf(t model.SMSType) string {
 	if t == model.SMSTypeExpress {
-		return s.priceExpress
+		return ExpressSMSKafkaTopic
 	}
-	return s.priceNormal
+	return NormalSMSKafkaTopic
+}
+
+// EnqueueOptions carries optional per-message settings.
+type EnqueueOptions struct {
+	CallbackURL string    // per-message status webhook; empty uses the account webhook
+	SendAt      time.Time // future release time; zero sends now
+	ClientRef   string    // customer idempotency key; a repeat returns the original message
+}
+
+// normalize drops a SendAt that is not after now, so the message is sent right away. Enqueue
+// calls it once: the message row and the outbox must agree on whether the message is held.
+func (o *EnqueueOptions) normalize(now time.Time) {
+	if !o.SendAt.After(now) {
+		o.SendAt = time.Time{}
+	}
+}
+
+// scheduled reports whether the message must be held until SendAt; opts must be normalized.
+func (o EnqueueOptions) scheduled() bool {
+	return !o.SendAt.IsZero()
+}
+
+// apply sets per-message options on a new message row.
+func (o EnqueueOptions) apply(m *model.Message) {
+	if o.CallbackURL != "" {
+		m.CallbackURL = &o.CallbackURL
+	}
+	if o.scheduled() {
+		at := o.SendAt
+		m.Status = model.StatusScheduled
+		m.SendAt = &at
+	}
+}
+
+// requestHash fingerprints what a send request asks for, to tell a retry from key reuse.
+func requestHash(sms model.SMS, opts EnqueueOptions) string {
+	var sendAt string
+	if !opts.SendAt.IsZero() {
+		sendAt = opts.SendAt.UTC().Format(time.RFC3339Nano)
+	}
+	b, _ := json.Marshal([]string{sms.Phone, sms.Text, sms.Type.String(), opts.CallbackURL, sendAt})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// admit checks messages and spend against the customer's daily and monthly caps and counts
+// them as used in the periods it returns; store them on the messages so a refund goes back
+// to the same periods. Callers hold the customer's wallet row lock, which serializes admit with every
+// other enqueue of the customer, so check and count cannot interleave. The caps are read under
+// a shared lock in tx, so an admin change to them waits for the enqueue or is seen by it whole.
+func (s *Service) admit(ctx context.Context, tx *sqlx.Tx, customerID, messages, spend int64) (model.Periods, error) {
+	cust, err := s.customers.GetShared(ctx, tx, customerID)
+	if err != nil {
+		return model.Periods{}, fmt.Errorf("get customer: %w", err)
+	}
+	var caps model.Caps
+	var tz string
+	if cust != nil {
+		caps, tz = cust.Caps, cust.Timezone
+	}
+	p := model.PeriodsAt(model.LocationOf(tz), time.Now())
+
+	if caps.Any() {
+		u, err := s.usage.Get(ctx, tx, customerID, p)
+		if err != nil {
+			return model.Periods{}, fmt.Errorf("get usage: %w", err)
+		}
+		if name, limit, used, exceeded := caps.Check(u, messages, spend); exceeded {
+			resets := p.DayEnds
+			if name == model.CapMonthlyMessages || name == model.CapMonthlySpend {
+				resets = p.MonthEnds
+			}
+			return model.Periods{}, &CapError{Cap: name, Limit: limit, Used: used, ResetsAt: resets}
+		}
+	}
+
+	if err := s.usage.Add(ctx, tx, customerID, p, messages, spend); err != nil {
+		return model.Periods{}, fmt.Errorf("add usage: %w", err)
+	}
+	return p, nil
+}
+
+// Accepted is how Enqueue stored a message. It is built from the stored row only, so a
+// replay of the request answers exactly what the first call did.
+type Accepted struct {
+	ID       string
+	Type     model.SMSType
+	Encoding model.Encoding
+	Segments int
+	Price    int64               // reserved at enqueue
+	Status   model.MessageStatus // queued, or scheduled until SendAt
+	SendAt   *time.Time
+	Replayed bool // an earlier request with the same client ref created the message
 }
 
-// Enqueue validates the SMS, reserves wallet funds, generates a ULID,
-// and writes into `wallet_ledger(reserve)`, `messages` and `outbox` within a single transaction.
-// Returns the generated message ID.
-func (s *Service) Enqueue(ctx context.Context, customerID int64, sms model.SMS) (string, error) {
+// acceptedOf describes m as it was inserted; later status changes do not show.
+func acceptedOf(m model.Message) Accepted {
+	a := Accepted{
+		ID:       m.ID,
+		Type:     m.Type,
+		Encoding: m.Encoding,
+		Segments: m.Segments,
+		Price:    m.Price,
+		Status:   model.StatusQueued,
+	}
+	if m.SendAt != nil {
+		a.Status, a.SendAt = model.StatusScheduled, m.SendAt
+	}
+	return a
+}
+
+// replay returns the message already created under clientRef (zero ID if none),
+// or ErrIdempotencyConflict when it was created from a different request.
+func (s *Service) replay(ctx context.Context, customerID int64, clientRef, hash string) (Accepted, error) {
+	m, err := s.msgs.GetByClientRef(ctx, customerID, clientRef)
+	if err != nil {
+		return Accepted{}, fmt.Errorf("get by client ref: %w", err)
+	}
+	if m == nil {
+		return Accepted{}, nil
+	}
+	if m.RequestHash == nil || *m.RequestHash != hash {
+		return Accepted{}, ErrIdempotencyConflict
+	}
+	a := acceptedOf(*m)
+	a.Replayed = true
+	return a, nil
+}
+
+// Enqueue validates the SMS, checks the customer's caps, reserves wallet funds, generates a
+// ULID, and writes into `wallet_ledger(reserve)`, `messages` and `outbox` within a single transaction.
+// Scheduled messages (opts.SendAt in the future) are reserved now but get no outbox row;
+// ReleaseDue publishes them later. Returns the message as stored.
+//
+// With opts.ClientRef set, a repeat of the same request returns the original message as
+// it was accepted (price, segments, schedule) without reserving again, and a different request under the same key fails with
+// ErrIdempotencyConflict. The unique (customer_id, client_ref) key settles concurrent repeats.
+func (s *Service) Enqueue(ctx context.Context, customerID int64, sms model.SMS, opts EnqueueOptions) (Accepted, error) {
+	var hash string
+	if opts.ClientRef != "" {
+		hash = requestHash(sms, opts)
+		if a, err := s.replay(ctx, customerID, opts.ClientRef, hash); err != nil || a.ID != "" {
+			return a, err
+		}
+	}
+	opts.normalize(time.Now())
+
 	// Generate message ID (ULID)
 	msgID := util.New()
+	enc, segments := util.CountSegments(sms.Text)
+	price, err := s.prices.PriceOf(ctx, customerID, sms.Phone, sms.Type, segments)
+	if err != nil {
+		return Accepted{}, err
+	}
 
 	// Normalize and build the message row
 	msg := model.Message{
@@ -74,63 +255,300 @@ func (s *Service) Enqueue(ctx context.Context, customerID int64, sms model.SMS)
 		Text:       sms.Text,
 		Type:       sms.Type,
 		Status:     model.StatusQueued,
+		Encoding:   enc,
+		Segments:   segments,
+		Price:      price,
+	}
+	opts.apply(&msg)
+	if opts.ClientRef != "" {
+		msg.ClientRef = &opts.ClientRef
+		msg.RequestHash = &hash
 	}
 
 	// Outbox envelope
 	env := model.Envelope{
-		ID:     msgID,
-		UserID: customerID,
-		SMS:    sms,
+		ID:       msgID,
+		UserID:   customerID,
+		Segments: segments,
+		Price:    price,
+		SMS:      sms,
+		QueuedAt: time.Now().UTC(),
 	}
 	payload, err := json.Marshal(env)
 	if err != nil {
-		return "", fmt.Errorf("marshal envelope: %w", err)
+		return Accepted{}, fmt.Errorf("marshal envelope: %w", err)
 	}
 
-	price := s.priceOf(sms.Type)
-
 	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
-		return "", err
+		return Accepted{}, err
 	}
 	defer func() { _ = tx.Rollback() }()
 
 	if err := s.wallet.UpsertAccount(ctx, tx, customerID); err != nil {
-		return "", fmt.Errorf("wallet upsert: %w", err)
+		return Accepted{}, fmt.Errorf("wallet upsert: %w", err)
 	}
 
 	bal, _, err := s.wallet.GetForUpdate(ctx, tx, customerID)
 	if err != nil {
-		return "", fmt.Errorf("wallet get for update: %w", err)
+		return Accepted{}, fmt.Errorf("wallet get for update: %w", err)
 	}
-	
+
 	if bal < price {
-		return "", ErrInsufficientFunds
+		return Accepted{}, ErrInsufficientFunds
+	}
+
+	p, err := s.admit(ctx, tx, customerID, 1, price)
+	if err != nil {
+		return Accepted{}, err
 	}
+	msg.UsageDay = p.Day
 
 	if err := s.wallet.Adjust(ctx, tx, customerID, -price, +price); err != nil {
-		return "", fmt.Errorf("wallet reserve adjust: %w", err)
+		return Accepted{}, fmt.Errorf("wallet reserve adjust: %w", err)
 	}
 
 	if err := s.ledger.InsertReserve(ctx, tx, customerID, price, msgID, "reserve-"+msgID); err != nil {
-		return "", fmt.Errorf("ledger reserve: %w", err)
+		return Accepted{}, fmt.Errorf("ledger reserve: %w", err)
 	}
 
 	if err := s.msgs.InsertQueued(ctx, tx, msg); err != nil {
-		return "", fmt.Errorf("insert message queued: %w", err)
+		if opts.ClientRef != "" && errors.Is(err, repository.ErrDuplicate) {
+			// a concurrent repeat won the race; drop our reservation and answer with theirs
+			_ = tx.Rollback()
+			a, rerr := s.replay(ctx, customerID, opts.ClientRef, hash)
+			if rerr == nil && a.ID == "" {
+				rerr = fmt.Errorf("client ref %q: duplicate without row", opts.ClientRef)
+			}
+			return a, rerr
+		}
+		return Accepted{}, fmt.Errorf("insert message queued: %w", err)
+	}
+
+	if !opts.scheduled() {
+		if err := s.outbox.Insert(ctx, tx, "message", msgID, TopicOf(sms.Type), payload); err != nil {
+			return Accepted{}, fmt.Errorf("insert outbox: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Accepted{}, err
 	}
+	return acceptedOf(msg), nil
+}
 
-	topic := NormalSMSKafkaTopic
-	if sms.Type == model.SMSTypeExpress {
-		topic = ExpressSMSKafkaTopic
+// EnqueueBulk is Enqueue for many messages of one customer: the total cost is reserved with a
+// single wallet lock and one ledger(reserve) row, caps apply to the batch as a whole, and messages/outbox are written in chunks,
+// all in one transaction. Returns the batch ID and the message IDs in input order.
+func (s *Service) EnqueueBulk(ctx context.Context, customerID int64, items []model.SMS, opts EnqueueOptions) (string, []string, error) {
+	if len(items) == 0 {
+		return "", nil, nil
 	}
 
-	if err := s.outbox.Insert(ctx, tx, "message", msgID, topic, payload); err != nil {
-		return "", fmt.Errorf("insert outbox: %w", err)
+	opts.normalize(time.Now())
+	batchID := util.New()
+	ids := make([]string, len(items))
+	msgs := make([]model.Message, len(items))
+	events := make([]repository.OutboxRow, len(items))
+
+	var total int64
+	for i, sms := range items {
+		ids[i] = util.New()
+		enc, segments := util.CountSegments(sms.Text)
+		price, err := s.prices.PriceOf(ctx, customerID, sms.Phone, sms.Type, segments)
+		if err != nil {
+			return "", nil, err
+		}
+		msgs[i] = model.Message{
+			ID:         ids[i],
+			CustomerID: customerID,
+			Phone:      sms.Phone,
+			Text:       sms.Text,
+			Type:       sms.Type,
+			Status:     model.StatusQueued,
+			Encoding:   enc,
+			Segments:   segments,
+			Price:      price,
+			BatchID:    &batchID,
+		}
+		opts.apply(&msgs[i])
+
+		payload, err := json.Marshal(model.Envelope{
+			ID:       ids[i],
+			UserID:   customerID,
+			Segments: segments,
+			Price:    price,
+			SMS:      sms,
+			QueuedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			return "", nil, fmt.Errorf("marshal envelope: %w", err)
+		}
+		events[i] = repository.OutboxRow{
+			Aggregate:   "message",
+			AggregateID: ids[i],
+			Topic:       TopicOf(sms.Type),
+			Payload:     payload,
+		}
+
+		total += price
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := s.wallet.UpsertAccount(ctx, tx, customerID); err != nil {
+		return "", nil, fmt.Errorf("wallet upsert: %w", err)
+	}
+
+	bal, _, err := s.wallet.GetForUpdate(ctx, tx, customerID)
+	if err != nil {
+		return "", nil, fmt.Errorf("wallet get for update: %w", err)
+	}
+
+	if bal < total {
+		return "", nil, ErrInsufficientFunds
+	}
+
+	p, err := s.admit(ctx, tx, customerID, int64(len(items)), total)
+	if err != nil {
+		return "", nil, err
+	}
+	for i := range msgs {
+		msgs[i].UsageDay = p.Day
+	}
+
+	if err := s.wallet.Adjust(ctx, tx, customerID, -total, +total); err != nil {
+		return "", nil, fmt.Errorf("wallet reserve adjust: %w", err)
+	}
+
+	if err := s.ledger.InsertBulkReserve(ctx, tx, customerID, total, batchID); err != nil {
+		return "", nil, fmt.Errorf("ledger bulk reserve: %w", err)
+	}
+
+	for start := 0; start < len(items); start += bulkChunkSize {
+		end := min(start+bulkChunkSize, len(items))
+
+		if err := s.msgs.InsertQueuedBatch(ctx, tx, msgs[start:end]); err != nil {
+			return "", nil, fmt.Errorf("insert messages queued: %w", err)
+		}
+		if opts.scheduled() {
+			continue
+		}
+		if err := s.outbox.InsertBatch(ctx, tx, events[start:end]); err != nil {
+			return "", nil, fmt.Errorf("insert outbox: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", nil, err
+	}
+	return batchID, ids, nil
+}
+
+// ReleaseDue moves up to limit due scheduled messages (oldest send_at first) to queued and
+// writes their outbox events, in one transaction. Safe to run from several replicas.
+// Returns the number of released messages.
+func (s *Service) ReleaseDue(ctx context.Context, limit int) (int, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	due, err := s.msgs.ClaimDueScheduled(ctx, tx, limit)
+	if err != nil {
+		return 0, fmt.Errorf("claim due scheduled: %w", err)
+	}
+	if len(due) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]string, len(due))
+	events := make([]repository.OutboxRow, len(due))
+	for i, m := range due {
+		payload, err := json.Marshal(model.Envelope{
+			ID:       m.ID,
+			UserID:   m.CustomerID,
+			Segments: m.Segments,
+			Price:    m.Price,
+			SMS:      model.SMS{Phone: m.Phone, Text: m.Text, Type: m.Type},
+			QueuedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			return 0, fmt.Errorf("marshal envelope: %w", err)
+		}
+		ids[i] = m.ID
+		events[i] = repository.OutboxRow{
+			Aggregate:   "message",
+			AggregateID: m.ID,
+			Topic:       TopicOf(m.Type),
+			Payload:     payload,
+		}
+	}
+
+	if err := s.outbox.InsertBatch(ctx, tx, events); err != nil {
+		return 0, fmt.Errorf("insert outbox: %w", err)
+	}
+	if err := s.msgs.BatchUpdateStatus(ctx, tx, ids, model.StatusQueued); err != nil {
+		return 0, fmt.Errorf("mark queued: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		return "", err
+		return 0, err
+	}
+	return len(due), nil
+}
+
+// CancelScheduled cancels a customer's scheduled message and refunds its reservation and usage.
+func (s *Service) CancelScheduled(ctx context.Context, customerID int64, id string) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	m, err := s.msgs.GetForUpdate(ctx, tx, customerID, id)
+	if err != nil {
+		return fmt.Errorf("get message: %w", err)
+	}
+	if m == nil {
+		return ErrMessageNotFound
+	}
+	if m.Status != model.StatusScheduled {
+		return ErrNotScheduled
+	}
+
+	// refund exactly what was reserved at enqueue time
+	price := m.Price
+
+	if err := s.ledger.InsertRefundBatch(ctx, tx, []repository.LedgerRow{{
+		CustomerID: customerID,
+		Amount:     price,
+		MessageID:  m.ID,
+	}}); err != nil {
+		return fmt.Errorf("ledger refund: %w", err)
+	}
+
+	if err := s.wallet.Adjust(ctx, tx, customerID, +price, -price); err != nil {
+		return fmt.Errorf("wallet refund adjust: %w", err)
+	}
+
+	// give the quota back to the day and month it was taken from
+	if err := s.usage.Refund(ctx, tx, []string{m.ID}); err != nil {
+		return fmt.Errorf("usage refund: %w", err)
+	}
+
+	if err := s.msgs.BatchUpdateStatus(ctx, tx, []string{m.ID}, model.StatusCanceled); err != nil {
+		return fmt.Errorf("mark canceled: %w", err)
+	}
+
+	if err := s.webhooks.EnqueueForMessages(ctx, tx, []string{m.ID}); err != nil {
+		return fmt.Errorf("enqueue webhook: %w", err)
 	}
-	return msgID, nil
+
+	return tx.Commit()
 }
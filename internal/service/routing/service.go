@@ -0,0 +1,95 @@
+package routing
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+)
+
+type ruleKey struct {
+	lane   model.SMSType
+	prefix string
+}
+
+// Service resolves which providers may carry a destination by longest-prefix match over
+// the routing_rules table. Rules are cached in memory and reloaded every refresh interval.
+type Service struct {
+	repo    repository.RoutesRepository
+	refresh time.Duration
+
+	mu       sync.RWMutex
+	rules    map[ruleKey]model.RouteRule
+	loadedAt time.Time
+}
+
+// New constructs the routing rules service.
+func New(repo repository.RoutesRepository, refresh time.Duration) *Service {
+	if refresh <= 0 {
+		refresh = 30 * time.Second
+	}
+	return &Service{repo: repo, refresh: refresh}
+}
+
+// Match returns the rule for phone on lane, if any.
+func (s *Service) Match(ctx context.Context, phone string, lane model.SMSType) (model.RouteRule, bool) {
+	rules := s.table(ctx)
+	for l := len(phone); l > 0; l-- {
+		if r, ok := rules[ruleKey{lane: lane, prefix: phone[:l]}]; ok {
+			return r, true
+		}
+	}
+	return model.RouteRule{}, false
+}
+
+// Route returns the ordered providers allowed for phone on lane; ok is false when no rule
+// matches and the default pool applies.
+func (s *Service) Route(ctx context.Context, phone string, lane model.SMSType) ([]string, bool) {
+	r, ok := s.Match(ctx, phone, lane)
+	if !ok {
+		return nil, false
+	}
+	return r.ProviderList(), true
+}
+
+// Invalidate forces the next lookup to reload rules (after admin changes on this node).
+func (s *Service) Invalidate() {
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+// table returns the cached rules, reloading them when stale. On load errors the
+// previous table is kept so routing never blocks on a DB hiccup.
+func (s *Service) table(ctx context.Context) map[ruleKey]model.RouteRule {
+	s.mu.RLock()
+	rules, fresh := s.rules, time.Since(s.loadedAt) < s.refresh
+	s.mu.RUnlock()
+	if fresh {
+		return rules
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.loadedAt) < s.refresh { // another goroutine reloaded meanwhile
+		return s.rules
+	}
+
+	rows, err := s.repo.ListAll(ctx)
+	if err != nil {
+		log.Printf("[routing] reload err: %v", err)
+		s.loadedAt = time.Now().Add(-s.refresh + time.Second) // retry in ~1s
+		return s.rules
+	}
+
+	next := make(map[ruleKey]model.RouteRule, len(rows))
+	for _, r := range rows {
+		next[ruleKey{lane: r.Lane, prefix: r.Prefix}] = r
+	}
+	s.rules = next
+	s.loadedAt = time.Now()
+	return next
+}
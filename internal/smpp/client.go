@@ -0,0 +1,153 @@
+package smpp
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNotBound is returned by Submit while no session is bound.
+var ErrNotBound = errors.New("smpp: no bound session")
+
+// Config describes an SMSC account.
+type Config struct {
+	Addr           string // host:port
+	SystemID       string
+	Password       string
+	SystemType     string
+	ServiceType    string
+	SourceAddr     string
+	SourceTON      byte
+	SourceNPI      byte
+	DestTON        byte
+	DestNPI        byte
+	Sessions       int           // parallel binds (default 1)
+	Window         int           // outstanding requests per session (default 10)
+	EnquireLink    time.Duration // keepalive interval (default 30s)
+	ReconnectDelay time.Duration // first delay after a dropped/failed bind, doubled up to 1m (default 2s)
+	Timeout        time.Duration // bind and response timeout (default 10s)
+}
+
+func (c Config) withDefaults() Config {
+	if c.Sessions <= 0 {
+		c.Sessions = 1
+	}
+	if c.Window <= 0 {
+		c.Window = 10
+	}
+	if c.EnquireLink <= 0 {
+		c.EnquireLink = 30 * time.Second
+	}
+	if c.ReconnectDelay <= 0 {
+		c.ReconnectDelay = 2 * time.Second
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	return c
+}
+
+// Client keeps cfg.Sessions transceiver sessions bound, rebinding with backoff when one drops,
+// and spreads submits over the bound ones.
+type Client struct {
+	cfg     Config
+	handler DeliverHandler
+
+	mu       sync.RWMutex
+	sessions []*Session
+	next     atomic.Uint64
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Dial starts binding in the background; Bound reports when a session is up.
+func Dial(cfg Config, handler DeliverHandler) *Client {
+	cfg = cfg.withDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Client{cfg: cfg, handler: handler, cancel: cancel}
+	for i := 0; i < cfg.Sessions; i++ {
+		c.wg.Add(1)
+		go c.keep(ctx, i)
+	}
+	return c
+}
+
+// Bound reports whether at least one session is bound.
+func (c *Client) Bound() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.sessions) > 0
+}
+
+// Submit sends m on the next bound session.
+func (c *Client) Submit(ctx context.Context, m SubmitSM) (string, error) {
+	c.mu.RLock()
+	if len(c.sessions) == 0 {
+		c.mu.RUnlock()
+		return "", ErrNotBound
+	}
+	s := c.sessions[int((c.next.Add(1)-1)%uint64(len(c.sessions)))]
+	c.mu.RUnlock()
+	return s.Submit(ctx, m)
+}
+
+// Close unbinds every session and stops reconnecting.
+func (c *Client) Close() {
+	c.cancel()
+	c.wg.Wait()
+}
+
+// keep holds one session slot bound until ctx ends.
+func (c *Client) keep(ctx context.Context, slot int) {
+	defer c.wg.Done()
+	delay := c.cfg.ReconnectDelay
+	for {
+		bctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+		s, err := Bind(bctx, c.cfg, c.handler)
+		cancel()
+		if err != nil {
+			log.Printf("[smpp] %s slot=%d bind err: %v (retry in %s)", c.cfg.Addr, slot, err, delay)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, time.Minute)
+			continue
+		}
+
+		delay = c.cfg.ReconnectDelay
+		log.Printf("[smpp] %s slot=%d bound as %s", c.cfg.Addr, slot, c.cfg.SystemID)
+		c.add(s)
+		select {
+		case <-ctx.Done():
+			c.remove(s)
+			_ = s.Close()
+			return
+		case <-s.Done():
+			c.remove(s)
+			log.Printf("[smpp] %s slot=%d session lost: %v", c.cfg.Addr, slot, s.Err())
+		}
+	}
+}
+
+func (c *Client) add(s *Session) {
+	c.mu.Lock()
+	c.sessions = append(c.sessions, s)
+	c.mu.Unlock()
+}
+
+func (c *Client) remove(s *Session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, x := range c.sessions {
+		if x == s {
+			c.sessions = append(c.sessions[:i], c.sessions[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package smpp_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jmehdipour/sms-gateway/internal/smpp"
+	"github.com/jmehdipour/sms-gateway/internal/smpp/smpptest"
+)
+
+func TestClientRebindsAfterDrop(t *testing.T) {
+	srv := smpptest.NewServer()
+	defer srv.Close()
+
+	c := smpp.Dial(testConfig(srv.Addr), nil)
+	defer c.Close()
+	eventually(t, "bind", c.Bound)
+
+	srv.Drop()
+	eventually(t, "rebind", func() bool { return srv.Binds() == 2 && c.Bound() })
+
+	id, err := c.Submit(context.Background(), smpp.SubmitSM{Dest: "989121234567", ShortMessage: []byte("after")})
+	if err != nil || id == "" {
+		t.Fatalf("Submit after rebind = %q, %v", id, err)
+	}
+}
+
+func TestClientNotBoundWhileBindFails(t *testing.T) {
+	srv := smpptest.NewServer()
+	defer srv.Close()
+	srv.SetBindStatus(smpp.StatusBindFail)
+
+	c := smpp.Dial(testConfig(srv.Addr), nil)
+	defer c.Close()
+
+	if _, err := c.Submit(context.Background(), smpp.SubmitSM{Dest: "989121234567"}); !errors.Is(err, smpp.ErrNotBound) {
+		t.Fatalf("Submit err = %v, want ErrNotBound", err)
+	}
+
+	srv.SetBindStatus(smpp.StatusOK)
+	eventually(t, "bind once accepted", c.Bound)
+}
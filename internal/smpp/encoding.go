@@ -0,0 +1,103 @@
+package smpp
+
+import (
+	"unicode/utf16"
+)
+
+// Data codings.
+const (
+	CodingDefault byte = 0x00 // SMSC default alphabet (GSM 03.38, one septet per octet)
+	CodingUCS2    byte = 0x08
+)
+
+// gsm7 is the GSM 03.38 default alphabet in code order; index 0x1B is the escape.
+const gsm7 = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞ\x1bÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// gsm7Ext maps extension-table characters to their code after the escape.
+var gsm7Ext = map[rune]byte{
+	'\f': 0x0A, '^': 0x14, '{': 0x28, '}': 0x29, '\\': 0x2F,
+	'[': 0x3C, '~': 0x3D, ']': 0x3E, '|': 0x40, '€': 0x65,
+}
+
+var gsm7Code = map[rune]byte{}
+
+func init() {
+	i := 0
+	for _, r := range gsm7 {
+		if r != 0x1b {
+			gsm7Code[r] = byte(i)
+		}
+		i++
+	}
+}
+
+// Octet budgets per part: whole message, and each part once a concatenation header is needed.
+const (
+	gsm7Single = 160
+	gsm7Multi  = 153
+	ucs2Single = 140
+	ucs2Multi  = 134
+)
+
+// Encode returns text's data coding and its parts, split so that no escape pair or
+// surrogate pair straddles two parts. One part needs no concatenation.
+func Encode(text string) (byte, [][]byte) {
+	units, ok := encodeGSM7(text)
+	dc, single, multi := CodingDefault, gsm7Single, gsm7Multi
+	if !ok {
+		units = encodeUCS2(text)
+		dc, single, multi = CodingUCS2, ucs2Single, ucs2Multi
+	}
+
+	total := 0
+	for _, u := range units {
+		total += len(u)
+	}
+	if total <= single {
+		out := make([]byte, 0, total)
+		for _, u := range units {
+			out = append(out, u...)
+		}
+		return dc, [][]byte{out}
+	}
+
+	var parts [][]byte
+	var cur []byte
+	for _, u := range units {
+		if len(cur)+len(u) > multi {
+			parts = append(parts, cur)
+			cur = nil
+		}
+		cur = append(cur, u...)
+	}
+	return dc, append(parts, cur)
+}
+
+// encodeGSM7 maps each rune to its unpacked septets; ok is false if any rune is outside GSM 03.38.
+func encodeGSM7(text string) ([][]byte, bool) {
+	var units [][]byte
+	for _, r := range text {
+		if c, ok := gsm7Code[r]; ok {
+			units = append(units, []byte{c})
+		} else if c, ok := gsm7Ext[r]; ok {
+			units = append(units, []byte{0x1b, c})
+		} else {
+			return nil, false
+		}
+	}
+	return units, true
+}
+
+// encodeUCS2 maps each rune to its UTF-16BE code units.
+func encodeUCS2(text string) [][]byte {
+	var units [][]byte
+	for _, r := range text {
+		var u []byte
+		for _, c := range utf16.Encode([]rune{r}) {
+			u = append(u, byte(c>>8), byte(c))
+		}
+		units = append(units, u)
+	}
+	return units
+}
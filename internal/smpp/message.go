@@ -0,0 +1,98 @@
+package smpp
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// SubmitSM is the part of a submit_sm the caller controls; addressing comes from Config.
+type SubmitSM struct {
+	Source             string // empty uses Config.SourceAddr
+	Dest               string // E.164 digits, no '+'
+	ESMClass           byte
+	RegisteredDelivery byte // 1 = ask for an SMSC delivery receipt
+	DataCoding         byte
+	ShortMessage       []byte
+	TLVs               []TLV
+}
+
+func (m SubmitSM) body(cfg Config) []byte {
+	src := m.Source
+	if src == "" {
+		src = cfg.SourceAddr
+	}
+	var w bodyWriter
+	w.cstring(cfg.ServiceType)
+	w.u8(cfg.SourceTON)
+	w.u8(cfg.SourceNPI)
+	w.cstring(src)
+	w.u8(cfg.DestTON)
+	w.u8(cfg.DestNPI)
+	w.cstring(strings.TrimPrefix(m.Dest, "+"))
+	w.u8(m.ESMClass)
+	w.u8(0) // protocol_id
+	w.u8(0) // priority_flag
+	w.cstring("")
+	w.cstring("")
+	w.u8(m.RegisteredDelivery)
+	w.u8(0) // replace_if_present_flag
+	w.u8(m.DataCoding)
+	w.u8(0) // sm_default_msg_id
+	w.u8(byte(len(m.ShortMessage)))
+	w.octets(m.ShortMessage)
+	for _, t := range m.TLVs {
+		w.tlv(t)
+	}
+	return w.b
+}
+
+// DeliverSM is an SMSC-originated message: a delivery receipt or a mobile-originated SMS.
+type DeliverSM struct {
+	Source       string
+	Dest         string
+	ESMClass     byte
+	DataCoding   byte
+	ShortMessage []byte
+	TLVs         map[uint16][]byte
+}
+
+// IsReceipt reports whether d carries a delivery receipt rather than an MO message.
+func (d DeliverSM) IsReceipt() bool { return d.ESMClass&0x3C == ESMClassReceipt }
+
+func parseDeliverSM(b []byte) (DeliverSM, error) {
+	r := bodyReader{b: b}
+	var d DeliverSM
+	r.cstring() // service_type
+	r.u8()
+	r.u8()
+	d.Source = r.cstring()
+	r.u8()
+	r.u8()
+	d.Dest = r.cstring()
+	d.ESMClass = r.u8()
+	r.u8() // protocol_id
+	r.u8() // priority_flag
+	r.cstring()
+	r.cstring()
+	r.u8() // registered_delivery
+	r.u8() // replace_if_present_flag
+	d.DataCoding = r.u8()
+	r.u8() // sm_default_msg_id
+	d.ShortMessage = r.octets(int(r.u8()))
+	d.TLVs = r.tlvs()
+	return d, r.err
+}
+
+// UDH returns the 8-bit-reference concatenation header for part seq (1-based) of total.
+func UDH(ref byte, total, seq int) []byte {
+	return []byte{0x05, 0x00, 0x03, ref, byte(total), byte(seq)}
+}
+
+// SarTLVs returns the SAR optional parameters for part seq (1-based) of total.
+func SarTLVs(ref uint16, total, seq int) []TLV {
+	return []TLV{
+		{Tag: TagSarMsgRefNum, Value: binary.BigEndian.AppendUint16(nil, ref)},
+		{Tag: TagSarTotalSegments, Value: []byte{byte(total)}},
+		{Tag: TagSarSegmentSeqnum, Value: []byte{byte(seq)}},
+	}
+}
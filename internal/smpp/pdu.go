@@ -0,0 +1,180 @@
+// Package smpp is a minimal SMPP 3.4 ESME client: transceiver binds, submit_sm with a
+// per-session window, enquire_link keepalive and deliver_sm (receipt) handling.
+package smpp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Command IDs.
+const (
+	cmdGenericNack         uint32 = 0x80000000
+	cmdBindTransceiver     uint32 = 0x00000009
+	cmdBindTransceiverResp uint32 = 0x80000009
+	cmdSubmitSM            uint32 = 0x00000004
+	cmdSubmitSMResp        uint32 = 0x80000004
+	cmdDeliverSM           uint32 = 0x00000005
+	cmdDeliverSMResp       uint32 = 0x80000005
+	cmdUnbind              uint32 = 0x00000006
+	cmdUnbindResp          uint32 = 0x80000006
+	cmdEnquireLink         uint32 = 0x00000015
+	cmdEnquireLinkResp     uint32 = 0x80000015
+)
+
+// Command status codes the gateway acts on.
+const (
+	StatusOK         uint32 = 0x00000000
+	StatusInvMsgLen  uint32 = 0x00000001 // ESME_RINVMSGLEN
+	StatusInvCmdID   uint32 = 0x00000003 // ESME_RINVCMDID
+	StatusInvBndSts  uint32 = 0x00000004 // ESME_RINVBNDSTS: not bound
+	StatusSysErr     uint32 = 0x00000008 // ESME_RSYSERR
+	StatusInvSrcAdr  uint32 = 0x0000000A // ESME_RINVSRCADR
+	StatusInvDstAdr  uint32 = 0x0000000B // ESME_RINVDSTADR
+	StatusBindFail   uint32 = 0x0000000D // ESME_RBINDFAIL
+	StatusInvPaswd   uint32 = 0x0000000E // ESME_RINVPASWD
+	StatusInvSysID   uint32 = 0x0000000F // ESME_RINVSYSID
+	StatusMsgQFul    uint32 = 0x00000014 // ESME_RMSGQFUL
+	StatusSubmitFail uint32 = 0x00000045 // ESME_RSUBMITFAIL
+	StatusThrottled  uint32 = 0x00000058 // ESME_RTHROTTLED
+	StatusRxTAppn    uint32 = 0x00000064 // ESME_RX_T_APPN: temporary ESME error, SMSC redelivers later
+)
+
+// Optional parameter tags.
+const (
+	TagReceiptedMessageID uint16 = 0x001E
+	TagSarMsgRefNum       uint16 = 0x020C
+	TagSarTotalSegments   uint16 = 0x020E
+	TagSarSegmentSeqnum   uint16 = 0x020F
+	TagMessageState       uint16 = 0x0427
+)
+
+// esm_class bits.
+const (
+	ESMClassUDHI    byte = 0x40 // short_message starts with a user data header
+	ESMClassReceipt byte = 0x04 // deliver_sm carries an SMSC delivery receipt
+)
+
+const (
+	headerLen = 16
+	maxPDULen = 64 << 10
+)
+
+// PDU is one SMPP protocol data unit; Body is everything after the 16-byte header.
+type PDU struct {
+	ID     uint32
+	Status uint32
+	Seq    uint32
+	Body   []byte
+}
+
+// StatusError is a non-zero command_status in a response.
+type StatusError uint32
+
+func (e StatusError) Error() string { return fmt.Sprintf("smpp: command_status 0x%08X", uint32(e)) }
+
+func (p PDU) marshal() []byte {
+	b := make([]byte, headerLen, headerLen+len(p.Body))
+	binary.BigEndian.PutUint32(b[0:], uint32(headerLen+len(p.Body)))
+	binary.BigEndian.PutUint32(b[4:], p.ID)
+	binary.BigEndian.PutUint32(b[8:], p.Status)
+	binary.BigEndian.PutUint32(b[12:], p.Seq)
+	return append(b, p.Body...)
+}
+
+func readPDU(r io.Reader) (PDU, error) {
+	var h [headerLen]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return PDU{}, err
+	}
+	n := binary.BigEndian.Uint32(h[0:])
+	if n < headerLen || n > maxPDULen {
+		return PDU{}, fmt.Errorf("smpp: bad command_length %d", n)
+	}
+	p := PDU{
+		ID:     binary.BigEndian.Uint32(h[4:]),
+		Status: binary.BigEndian.Uint32(h[8:]),
+		Seq:    binary.BigEndian.Uint32(h[12:]),
+		Body:   make([]byte, n-headerLen),
+	}
+	if _, err := io.ReadFull(r, p.Body); err != nil {
+		return PDU{}, err
+	}
+	return p, nil
+}
+
+// TLV is an optional parameter.
+type TLV struct {
+	Tag   uint16
+	Value []byte
+}
+
+type bodyWriter struct{ b []byte }
+
+func (w *bodyWriter) cstring(s string) { w.b = append(append(w.b, s...), 0) }
+func (w *bodyWriter) u8(v byte)        { w.b = append(w.b, v) }
+func (w *bodyWriter) octets(v []byte)  { w.b = append(w.b, v...) }
+
+func (w *bodyWriter) tlv(t TLV) {
+	w.b = binary.BigEndian.AppendUint16(w.b, t.Tag)
+	w.b = binary.BigEndian.AppendUint16(w.b, uint16(len(t.Value)))
+	w.b = append(w.b, t.Value...)
+}
+
+type bodyReader struct {
+	b   []byte
+	err error
+}
+
+func (r *bodyReader) fail() {
+	if r.err == nil {
+		r.err = io.ErrUnexpectedEOF
+	}
+}
+
+func (r *bodyReader) cstring() string {
+	for i, c := range r.b {
+		if c == 0 {
+			s := string(r.b[:i])
+			r.b = r.b[i+1:]
+			return s
+		}
+	}
+	r.fail()
+	r.b = nil
+	return ""
+}
+
+func (r *bodyReader) u8() byte {
+	if len(r.b) < 1 {
+		r.fail()
+		return 0
+	}
+	v := r.b[0]
+	r.b = r.b[1:]
+	return v
+}
+
+func (r *bodyReader) octets(n int) []byte {
+	if len(r.b) < n {
+		r.fail()
+		r.b = nil
+		return nil
+	}
+	v := r.b[:n:n]
+	r.b = r.b[n:]
+	return v
+}
+
+// tlvs reads the remaining body as optional parameters.
+func (r *bodyReader) tlvs() map[uint16][]byte {
+	out := map[uint16][]byte{}
+	for len(r.b) >= 4 && r.err == nil {
+		tag := binary.BigEndian.Uint16(r.b)
+		n := int(binary.BigEndian.Uint16(r.b[2:]))
+		r.b = r.b[4:]
+		out[tag] = r.octets(n)
+	}
+	return out
+}
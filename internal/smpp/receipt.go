@@ -0,0 +1,64 @@
+package smpp
+
+import (
+	"strings"
+	"time"
+)
+
+// Receipt is an SMSC delivery receipt.
+type Receipt struct {
+	MessageID string
+	Stat      string // DELIVRD | EXPIRED | DELETED | UNDELIV | ACCEPTD | UNKNOWN | REJECTD | ENROUTE
+	DoneAt    time.Time
+}
+
+// message_state values (TLV 0x0427) as receipt stat words.
+var messageStates = map[byte]string{
+	1: "ENROUTE", 2: "DELIVRD", 3: "EXPIRED", 4: "DELETED",
+	5: "UNDELIV", 6: "ACCEPTD", 7: "UNKNOWN", 8: "REJECTD",
+}
+
+// ParseReceipt reads the receipt in d: the de-facto text format
+// "id:<id> sub:001 dlvrd:001 submit date:YYMMDDhhmm done date:YYMMDDhhmm stat:DELIVRD err:000 text:..."
+// with receipted_message_id / message_state TLVs taking precedence when present.
+func ParseReceipt(d DeliverSM) (Receipt, bool) {
+	if !d.IsReceipt() {
+		return Receipt{}, false
+	}
+	text := string(d.ShortMessage)
+
+	var rc Receipt
+	rc.MessageID = receiptField(text, "id:")
+	rc.Stat = strings.ToUpper(receiptField(text, "stat:"))
+	if done := receiptField(text, "done date:"); done != "" {
+		for _, layout := range []string{"0601021504", "060102150405"} {
+			if t, err := time.ParseInLocation(layout, done, time.UTC); err == nil {
+				rc.DoneAt = t
+				break
+			}
+		}
+	}
+
+	if v, ok := d.TLVs[TagReceiptedMessageID]; ok {
+		rc.MessageID = strings.TrimRight(string(v), "\x00")
+	}
+	if v, ok := d.TLVs[TagMessageState]; ok && len(v) == 1 {
+		if s, ok := messageStates[v[0]]; ok {
+			rc.Stat = s
+		}
+	}
+	return rc, rc.MessageID != ""
+}
+
+// receiptField returns the value after key up to the next space ("done date:" values have none).
+func receiptField(text, key string) string {
+	i := strings.Index(strings.ToLower(text), key)
+	if i < 0 {
+		return ""
+	}
+	v := text[i+len(key):]
+	if j := strings.IndexByte(v, ' '); j >= 0 {
+		v = v[:j]
+	}
+	return v
+}
@@ -0,0 +1,261 @@
+package smpp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrClosed is returned for requests on a session that has been closed.
+var ErrClosed = errors.New("smpp: session closed")
+
+// DeliverHandler handles a deliver_sm and returns the command_status to answer it with.
+type DeliverHandler func(DeliverSM) uint32
+
+// Session is one bound transceiver connection. Requests are matched to responses by
+// sequence number; at most Config.Window requests are outstanding at a time.
+type Session struct {
+	cfg     Config
+	conn    net.Conn
+	handler DeliverHandler
+
+	seq     atomic.Uint32
+	window  chan struct{}
+	wmu     sync.Mutex // serializes writes
+	mu      sync.Mutex
+	pending map[uint32]chan PDU
+
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+// Bind dials cfg.Addr and binds as a transceiver. The session then reads in the
+// background and sends enquire_link every cfg.EnquireLink until closed.
+func Bind(ctx context.Context, cfg Config, handler DeliverHandler) (*Session, error) {
+	cfg = cfg.withDefaults()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{
+		cfg:     cfg,
+		conn:    conn,
+		handler: handler,
+		window:  make(chan struct{}, cfg.Window),
+		pending: map[uint32]chan PDU{},
+		done:    make(chan struct{}),
+	}
+
+	// bind synchronously before the reader starts
+	var w bodyWriter
+	w.cstring(cfg.SystemID)
+	w.cstring(cfg.Password)
+	w.cstring(cfg.SystemType)
+	w.u8(0x34) // interface_version
+	w.u8(0)    // addr_ton
+	w.u8(0)    // addr_npi
+	w.cstring("")
+	deadline := time.Now().Add(cfg.Timeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	_ = conn.SetDeadline(deadline)
+	if _, err := conn.Write(PDU{ID: cmdBindTransceiver, Seq: s.nextSeq(), Body: w.b}.marshal()); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp, err := readPDU(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp.ID != cmdBindTransceiverResp {
+		conn.Close()
+		return nil, fmt.Errorf("smpp: unexpected bind response 0x%08X", resp.ID)
+	}
+	if resp.Status != StatusOK {
+		conn.Close()
+		return nil, StatusError(resp.Status)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	go s.readLoop()
+	go s.keepalive()
+	return s, nil
+}
+
+// Done is closed when the session ends; Err then tells why.
+func (s *Session) Done() <-chan struct{} { return s.done }
+
+func (s *Session) Err() error {
+	<-s.done
+	return s.err
+}
+
+// Submit sends a submit_sm and returns the SMSC message id.
+func (s *Session) Submit(ctx context.Context, m SubmitSM) (string, error) {
+	resp, err := s.request(ctx, cmdSubmitSM, m.body(s.cfg))
+	if err != nil {
+		return "", err
+	}
+	if resp.Status != StatusOK {
+		return "", StatusError(resp.Status)
+	}
+	r := bodyReader{b: resp.Body}
+	return r.cstring(), nil
+}
+
+// Close unbinds (best effort) and closes the connection.
+func (s *Session) Close() error {
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, _ = s.request(ctx, cmdUnbind, nil)
+	s.close(ErrClosed)
+	return nil
+}
+
+func (s *Session) nextSeq() uint32 {
+	n := s.seq.Add(1)
+	if n > 0x7FFFFFFF { // sequence numbers are 1..0x7FFFFFFF
+		s.seq.Store(1)
+		n = 1
+	}
+	return n
+}
+
+func (s *Session) close(err error) {
+	s.closeOnce.Do(func() {
+		s.err = err
+		close(s.done)
+		_ = s.conn.Close()
+	})
+}
+
+func (s *Session) write(p PDU) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	_ = s.conn.SetWriteDeadline(time.Now().Add(s.cfg.Timeout))
+	_, err := s.conn.Write(p.marshal())
+	if err != nil {
+		s.close(err)
+	}
+	return err
+}
+
+// request sends a PDU within the window and waits for its response.
+func (s *Session) request(ctx context.Context, id uint32, body []byte) (PDU, error) {
+	select {
+	case s.window <- struct{}{}:
+	case <-s.done:
+		return PDU{}, ErrClosed
+	case <-ctx.Done():
+		return PDU{}, ctx.Err()
+	}
+	defer func() { <-s.window }()
+
+	seq := s.nextSeq()
+	ch := make(chan PDU, 1)
+	s.mu.Lock()
+	s.pending[seq] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, seq)
+		s.mu.Unlock()
+	}()
+
+	if err := s.write(PDU{ID: id, Seq: seq, Body: body}); err != nil {
+		return PDU{}, err
+	}
+
+	timer := time.NewTimer(s.cfg.Timeout)
+	defer timer.Stop()
+	select {
+	case p := <-ch:
+		if p.ID == cmdGenericNack {
+			return PDU{}, StatusError(p.Status)
+		}
+		return p, nil
+	case <-s.done:
+		return PDU{}, ErrClosed
+	case <-ctx.Done():
+		return PDU{}, ctx.Err()
+	case <-timer.C:
+		return PDU{}, fmt.Errorf("smpp: no response to 0x%08X seq=%d within %s", id, seq, s.cfg.Timeout)
+	}
+}
+
+func (s *Session) readLoop() {
+	for {
+		p, err := readPDU(s.conn)
+		if err != nil {
+			s.close(err)
+			return
+		}
+
+		switch {
+		case p.ID&0x80000000 != 0: // a response, including generic_nack
+			s.mu.Lock()
+			ch := s.pending[p.Seq]
+			s.mu.Unlock()
+			if ch != nil {
+				ch <- p
+			}
+		case p.ID == cmdEnquireLink:
+			_ = s.write(PDU{ID: cmdEnquireLinkResp, Seq: p.Seq})
+		case p.ID == cmdUnbind:
+			_ = s.write(PDU{ID: cmdUnbindResp, Seq: p.Seq})
+			s.close(ErrClosed)
+			return
+		case p.ID == cmdDeliverSM:
+			go s.deliver(p)
+		default:
+			_ = s.write(PDU{ID: cmdGenericNack, Status: StatusInvCmdID, Seq: p.Seq})
+		}
+	}
+}
+
+// deliver runs the handler off the read loop so a slow receipt write never stalls responses.
+func (s *Session) deliver(p PDU) {
+	status := StatusOK
+	d, err := parseDeliverSM(p.Body)
+	switch {
+	case err != nil:
+		status = StatusInvMsgLen
+	case s.handler != nil:
+		status = s.handler(d)
+	}
+	_ = s.write(PDU{ID: cmdDeliverSMResp, Status: status, Seq: p.Seq, Body: []byte{0}})
+}
+
+// keepalive sends enquire_link every interval and drops the session when one goes unanswered.
+func (s *Session) keepalive() {
+	t := time.NewTicker(s.cfg.EnquireLink)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-t.C:
+			ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+			_, err := s.request(ctx, cmdEnquireLink, nil)
+			cancel()
+			if err != nil {
+				s.close(fmt.Errorf("smpp: enquire_link: %w", err))
+				return
+			}
+		}
+	}
+}
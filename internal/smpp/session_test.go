@@ -0,0 +1,168 @@
+package smpp_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/smpp"
+	"github.com/jmehdipour/sms-gateway/internal/smpp/smpptest"
+)
+
+// eventually polls cond until it holds or a second passes.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func testConfig(addr string) smpp.Config {
+	return smpp.Config{
+		Addr:           addr,
+		SystemID:       "gw",
+		Password:       "secret",
+		SourceAddr:     "98100020",
+		Timeout:        2 * time.Second,
+		ReconnectDelay: 10 * time.Millisecond,
+	}
+}
+
+func TestBindFailureStatus(t *testing.T) {
+	srv := smpptest.NewServer()
+	defer srv.Close()
+	srv.SetBindStatus(smpp.StatusInvPaswd)
+
+	_, err := smpp.Bind(context.Background(), testConfig(srv.Addr), nil)
+	var st smpp.StatusError
+	if !errors.As(err, &st) || uint32(st) != smpp.StatusInvPaswd {
+		t.Fatalf("Bind err = %v, want ESME_RINVPASWD", err)
+	}
+	if srv.Binds() != 0 {
+		t.Fatalf("binds = %d, want 0", srv.Binds())
+	}
+}
+
+func TestSubmitReturnsMessageID(t *testing.T) {
+	srv := smpptest.NewServer()
+	defer srv.Close()
+
+	s, err := smpp.Bind(context.Background(), testConfig(srv.Addr), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	id, err := s.Submit(context.Background(), smpp.SubmitSM{Dest: "+989121234567", ShortMessage: []byte("hi")})
+	if err != nil || id != "msg-1" {
+		t.Fatalf("Submit = %q, %v", id, err)
+	}
+	got := srv.Submits()[0]
+	if got.Dest != "989121234567" || got.Source != "98100020" || string(got.ShortMessage) != "hi" {
+		t.Fatalf("submit_sm = %+v", got)
+	}
+
+	srv.SetSubmitStatus(smpp.StatusInvDstAdr)
+	_, err = s.Submit(context.Background(), smpp.SubmitSM{Dest: "+1", ShortMessage: []byte("hi")})
+	var st smpp.StatusError
+	if !errors.As(err, &st) || uint32(st) != smpp.StatusInvDstAdr {
+		t.Fatalf("Submit err = %v, want ESME_RINVDSTADR", err)
+	}
+}
+
+func TestWindowLimitsOutstandingSubmits(t *testing.T) {
+	srv := smpptest.NewServer()
+	defer srv.Close()
+
+	cfg := testConfig(srv.Addr)
+	cfg.Window = 2
+	s, err := smpp.Bind(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	srv.Hold()
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Submit(context.Background(), smpp.SubmitSM{Dest: "989121234567", ShortMessage: []byte("x")})
+			errs <- err
+		}()
+	}
+
+	eventually(t, "two submits", func() bool { return len(srv.Submits()) == 2 })
+	time.Sleep(50 * time.Millisecond)
+	if n := len(srv.Submits()); n != 2 {
+		t.Fatalf("%d submits outstanding, window is 2", n)
+	}
+
+	srv.Release()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+	}
+	if n := len(srv.Submits()); n != 5 {
+		t.Fatalf("submits = %d, want 5", n)
+	}
+}
+
+func TestEnquireLinkKeepsSessionUp(t *testing.T) {
+	srv := smpptest.NewServer()
+	defer srv.Close()
+
+	cfg := testConfig(srv.Addr)
+	cfg.EnquireLink = 10 * time.Millisecond
+	s, err := smpp.Bind(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	eventually(t, "enquire_link", func() bool { return srv.EnquireLinks() >= 3 })
+	select {
+	case <-s.Done():
+		t.Fatalf("session ended: %v", s.Err())
+	default:
+	}
+}
+
+func TestDeliverHandlerStatusIsAnswered(t *testing.T) {
+	srv := smpptest.NewServer()
+	defer srv.Close()
+
+	got := make(chan smpp.Receipt, 1)
+	s, err := smpp.Bind(context.Background(), testConfig(srv.Addr), func(d smpp.DeliverSM) uint32 {
+		rc, _ := smpp.ParseReceipt(d)
+		got <- rc
+		return smpp.StatusRxTAppn
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	st, err := srv.Receipt("abc123", "DELIVRD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st != smpp.StatusRxTAppn {
+		t.Fatalf("deliver_sm_resp status = 0x%08X, want ESME_RX_T_APPN", st)
+	}
+	rc := <-got
+	if rc.MessageID != "abc123" || rc.Stat != "DELIVRD" || rc.DoneAt.IsZero() {
+		t.Fatalf("receipt = %+v", rc)
+	}
+}
@@ -0,0 +1,420 @@
+// Package smpptest is an in-process SMSC for tests. It answers bind_transceiver, submit_sm,
+// enquire_link and unbind, records every submit_sm, can hold submit responses back to
+// exercise the client window, drop sessions, and push deliver_sm receipts.
+// It has its own PDU codec so tests do not check the client against itself.
+package smpptest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	cmdGenericNack         uint32 = 0x80000000
+	cmdBindTransceiver     uint32 = 0x00000009
+	cmdBindTransceiverResp uint32 = 0x80000009
+	cmdSubmitSM            uint32 = 0x00000004
+	cmdSubmitSMResp        uint32 = 0x80000004
+	cmdDeliverSM           uint32 = 0x00000005
+	cmdDeliverSMResp       uint32 = 0x80000005
+	cmdUnbind              uint32 = 0x00000006
+	cmdUnbindResp          uint32 = 0x80000006
+	cmdEnquireLink         uint32 = 0x00000015
+	cmdEnquireLinkResp     uint32 = 0x80000015
+)
+
+// Submit is a submit_sm the server received and the message id it answered with.
+type Submit struct {
+	Source             string
+	Dest               string
+	ESMClass           byte
+	RegisteredDelivery byte
+	DataCoding         byte
+	ShortMessage       []byte
+	TLVs               map[uint16][]byte
+	MessageID          string
+}
+
+// Server is a stub SMSC listening on a loopback port.
+type Server struct {
+	Addr string
+
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu           sync.Mutex
+	closed       bool
+	bindStatus   uint32
+	submitStatus uint32
+	queued       []uint32 // statuses for the next submit_sm, before submitStatus
+	hold         bool
+	held         []func()
+	all          map[*conn]struct{} // every accepted connection
+	conns        []*conn            // bound sessions, oldest first
+	binds        int
+	enquireLinks int
+	submits      []Submit
+	nextID       int
+}
+
+type conn struct {
+	srv *Server
+	c   net.Conn
+
+	wmu     sync.Mutex
+	seq     uint32
+	mu      sync.Mutex
+	waiting map[uint32]chan uint32 // deliver_sm seq → deliver_sm_resp status
+}
+
+// NewServer starts a stub SMSC. Close it when done.
+func NewServer() *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("smpptest: listen: %v", err))
+	}
+	s := &Server{Addr: ln.Addr().String(), ln: ln, all: map[*conn]struct{}{}}
+	s.wg.Add(1)
+	go s.accept()
+	return s
+}
+
+// Close stops listening and closes every connection.
+func (s *Server) Close() {
+	_ = s.ln.Close()
+	s.mu.Lock()
+	s.closed = true
+	for c := range s.all {
+		_ = c.c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// SetBindStatus makes later binds fail with status (0 accepts them).
+func (s *Server) SetBindStatus(status uint32) {
+	s.mu.Lock()
+	s.bindStatus = status
+	s.mu.Unlock()
+}
+
+// SetSubmitStatus makes later submit_sm fail with status (0 accepts them).
+func (s *Server) SetSubmitStatus(status uint32) {
+	s.mu.Lock()
+	s.submitStatus = status
+	s.mu.Unlock()
+}
+
+// QueueSubmitStatus answers the next submit_sm with statuses, in order (0 accepts); later
+// ones get the SetSubmitStatus status again.
+func (s *Server) QueueSubmitStatus(statuses ...uint32) {
+	s.mu.Lock()
+	s.queued = append(s.queued, statuses...)
+	s.mu.Unlock()
+}
+
+// Hold stops answering submit_sm until Release.
+func (s *Server) Hold() {
+	s.mu.Lock()
+	s.hold = true
+	s.mu.Unlock()
+}
+
+// Release answers the held submit_sm and stops holding.
+func (s *Server) Release() {
+	s.mu.Lock()
+	held := s.held
+	s.hold, s.held = false, nil
+	s.mu.Unlock()
+	for _, f := range held {
+		f()
+	}
+}
+
+// Drop closes every bound session, as an SMSC restart would.
+func (s *Server) Drop() {
+	s.mu.Lock()
+	conns := s.conns
+	s.conns = nil
+	s.mu.Unlock()
+	for _, c := range conns {
+		_ = c.c.Close()
+	}
+}
+
+// Binds is the number of successful binds so far.
+func (s *Server) Binds() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.binds
+}
+
+// EnquireLinks is the number of enquire_link requests answered so far.
+func (s *Server) EnquireLinks() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enquireLinks
+}
+
+// Submits returns the submit_sm received so far, in arrival order.
+func (s *Server) Submits() []Submit {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Submit(nil), s.submits...)
+}
+
+// Receipt pushes a delivery receipt for messageID with stat (DELIVRD, UNDELIV, ...) on the
+// most recently bound session and returns the command_status the client answered with.
+func (s *Server) Receipt(messageID, stat string) (uint32, error) {
+	s.mu.Lock()
+	if len(s.conns) == 0 {
+		s.mu.Unlock()
+		return 0, errors.New("smpptest: no bound session")
+	}
+	c := s.conns[len(s.conns)-1]
+	s.mu.Unlock()
+
+	text := "id:" + messageID + " sub:001 dlvrd:001 submit date:2510181200 done date:2510181201 stat:" + stat + " err:000 text:"
+	var b []byte
+	b = cstring(b, "")              // service_type
+	b = append(b, 1, 1)             // source ton, npi
+	b = cstring(b, "989121234567")  // source_addr
+	b = append(b, 0, 0)             // dest ton, npi
+	b = cstring(b, "")              // destination_addr
+	b = append(b, 0x04, 0, 0)       // esm_class (receipt), protocol_id, priority_flag
+	b = cstring(cstring(b, ""), "") // schedule_delivery_time, validity_period
+	b = append(b, 0, 0, 0, 0)       // registered_delivery, replace_if_present, data_coding, sm_default_msg_id
+	b = append(b, byte(len(text)))  // sm_length
+	b = append(b, text...)          // short_message
+
+	ch := make(chan uint32, 1)
+	c.mu.Lock()
+	c.seq++
+	seq := c.seq
+	c.waiting[seq] = ch
+	c.mu.Unlock()
+
+	if err := c.write(cmdDeliverSM, 0, seq, b); err != nil {
+		return 0, err
+	}
+	select {
+	case st := <-ch:
+		return st, nil
+	case <-time.After(5 * time.Second):
+		return 0, errors.New("smpptest: no deliver_sm_resp")
+	}
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &conn{srv: s, c: nc, seq: 1000, waiting: map[uint32]chan uint32{}}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = nc.Close()
+			return
+		}
+		s.all[c] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go func() {
+			defer s.wg.Done()
+			c.serve()
+		}()
+	}
+}
+
+func (c *conn) write(id, status, seq uint32, body []byte) error {
+	b := make([]byte, 16, 16+len(body))
+	binary.BigEndian.PutUint32(b[0:], uint32(16+len(body)))
+	binary.BigEndian.PutUint32(b[4:], id)
+	binary.BigEndian.PutUint32(b[8:], status)
+	binary.BigEndian.PutUint32(b[12:], seq)
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.c.Write(append(b, body...))
+	return err
+}
+
+func (c *conn) serve() {
+	defer c.c.Close()
+	defer c.srv.remove(c)
+	for {
+		var h [16]byte
+		if _, err := io.ReadFull(c.c, h[:]); err != nil {
+			return
+		}
+		n := binary.BigEndian.Uint32(h[0:])
+		id := binary.BigEndian.Uint32(h[4:])
+		status := binary.BigEndian.Uint32(h[8:])
+		seq := binary.BigEndian.Uint32(h[12:])
+		if n < 16 || n > 64<<10 {
+			return
+		}
+		body := make([]byte, n-16)
+		if _, err := io.ReadFull(c.c, body); err != nil {
+			return
+		}
+
+		switch id {
+		case cmdBindTransceiver:
+			c.srv.mu.Lock()
+			st := c.srv.bindStatus
+			if st == 0 {
+				c.srv.binds++
+				c.srv.conns = append(c.srv.conns, c)
+			}
+			c.srv.mu.Unlock()
+			_ = c.write(cmdBindTransceiverResp, st, seq, cstring(nil, "smpptest"))
+			if st != 0 {
+				return
+			}
+		case cmdSubmitSM:
+			c.submit(seq, body)
+		case cmdEnquireLink:
+			c.srv.mu.Lock()
+			c.srv.enquireLinks++
+			c.srv.mu.Unlock()
+			_ = c.write(cmdEnquireLinkResp, 0, seq, nil)
+		case cmdUnbind:
+			_ = c.write(cmdUnbindResp, 0, seq, nil)
+			return
+		case cmdDeliverSMResp:
+			c.mu.Lock()
+			ch := c.waiting[seq]
+			delete(c.waiting, seq)
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- status
+			}
+		default:
+			if id&0x80000000 == 0 {
+				_ = c.write(cmdGenericNack, 0x03, seq, nil) // ESME_RINVCMDID
+			}
+		}
+	}
+}
+
+func (c *conn) submit(seq uint32, body []byte) {
+	m, err := parseSubmit(body)
+	if err != nil {
+		_ = c.write(cmdSubmitSMResp, 0x01, seq, nil) // ESME_RINVMSGLEN
+		return
+	}
+
+	s := c.srv
+	s.mu.Lock()
+	st := s.submitStatus
+	if len(s.queued) > 0 {
+		st, s.queued = s.queued[0], s.queued[1:]
+	}
+	if st == 0 {
+		s.nextID++
+		m.MessageID = "msg-" + strconv.Itoa(s.nextID)
+	}
+	s.submits = append(s.submits, m)
+	answer := func() {
+		if st != 0 {
+			_ = c.write(cmdSubmitSMResp, st, seq, nil)
+			return
+		}
+		_ = c.write(cmdSubmitSMResp, 0, seq, cstring(nil, m.MessageID))
+	}
+	if s.hold {
+		s.held = append(s.held, answer)
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	answer()
+}
+
+func (s *Server) remove(c *conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.all, c)
+	for i, x := range s.conns {
+		if x == c {
+			s.conns = append(s.conns[:i], s.conns[i+1:]...)
+			return
+		}
+	}
+}
+
+func cstring(b []byte, s string) []byte { return append(append(b, s...), 0) }
+
+// reader walks a PDU body; the first short read sticks in err.
+type reader struct {
+	b   []byte
+	err error
+}
+
+func (r *reader) cstring() string {
+	for i, c := range r.b {
+		if c == 0 {
+			s := string(r.b[:i])
+			r.b = r.b[i+1:]
+			return s
+		}
+	}
+	r.err = io.ErrUnexpectedEOF
+	r.b = nil
+	return ""
+}
+
+func (r *reader) octets(n int) []byte {
+	if len(r.b) < n {
+		r.err = io.ErrUnexpectedEOF
+		r.b = nil
+		return nil
+	}
+	v := append([]byte(nil), r.b[:n]...)
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *reader) u8() byte {
+	v := r.octets(1)
+	if len(v) == 0 {
+		return 0
+	}
+	return v[0]
+}
+
+func parseSubmit(b []byte) (Submit, error) {
+	r := reader{b: b}
+	var m Submit
+	r.cstring() // service_type
+	r.octets(2) // source ton, npi
+	m.Source = r.cstring()
+	r.octets(2) // dest ton, npi
+	m.Dest = r.cstring()
+	m.ESMClass = r.u8()
+	r.octets(2) // protocol_id, priority_flag
+	r.cstring() // schedule_delivery_time
+	r.cstring() // validity_period
+	m.RegisteredDelivery = r.u8()
+	r.u8() // replace_if_present_flag
+	m.DataCoding = r.u8()
+	r.u8() // sm_default_msg_id
+	m.ShortMessage = r.octets(int(r.u8()))
+	m.TLVs = map[uint16][]byte{}
+	for len(r.b) >= 4 && r.err == nil {
+		tag := binary.BigEndian.Uint16(r.b)
+		n := int(binary.BigEndian.Uint16(r.b[2:]))
+		r.b = r.b[4:]
+		m.TLVs[tag] = r.octets(n)
+	}
+	return m, r.err
+}
@@ -0,0 +1,36 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// API keys look like "sk_<12 hex>.<32 hex>". The part before the dot is stored in clear and
+// used for lookup; the full key is only ever stored hashed.
+const (
+	apiKeyTag       = "sk_"
+	apiKeyPrefixLen = len(apiKeyTag) + 12
+)
+
+// NewAPIKey returns a fresh key and its lookup prefix.
+func NewAPIKey() (key, prefix string) {
+	prefix = apiKeyTag + NewSecret(6)
+	return prefix + "." + NewSecret(16), prefix
+}
+
+// APIKeyPrefix returns the lookup prefix of key; ok is false when key is malformed.
+func APIKeyPrefix(key string) (prefix string, ok bool) {
+	prefix, secret, found := strings.Cut(key, ".")
+	if !found || len(prefix) != apiKeyPrefixLen || !strings.HasPrefix(prefix, apiKeyTag) || len(secret) != 32 {
+		return "", false
+	}
+	return prefix, true
+}
+
+// HashAPIKey returns the SHA-256 hex digest stored for key. Keys carry 128 random bits, so a
+// fast unsalted hash is enough; there is nothing to brute-force.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
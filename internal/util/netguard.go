@@ -0,0 +1,58 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+)
+
+// cgnat is the carrier-grade NAT range (RFC 6598), internal like RFC 1918 space.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// PublicAddr reports whether ip is routable on the public internet: not loopback, private,
+// link-local (which includes cloud metadata at 169.254.169.254), unspecified or multicast.
+func PublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsValid() &&
+		!ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified() &&
+		!cgnat.Contains(ip)
+}
+
+// ResolvesPublic resolves host and reports whether it has addresses and all of them are public.
+func ResolvesPublic(ctx context.Context, host string) bool {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return PublicAddr(ip)
+	}
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil || len(ips) == 0 {
+		return false
+	}
+	for _, ip := range ips {
+		if !PublicAddr(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// DenyInternalDial is a net.Dialer Control func that refuses connections to non-public
+// addresses. It runs on the resolved address, so it also covers redirects and DNS answers
+// that changed after a URL was validated.
+func DenyInternalDial(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !PublicAddr(ap.Addr()) {
+		return fmt.Errorf("dial %s %s: address not allowed", network, address)
+	}
+	return nil
+}
@@ -23,3 +23,10 @@ func NormalizePhone(raw string) string {
 	
 	return s
 }
+
+var e164 = regexp.MustCompile(`^\+[1-9]\d{7,14}$`)
+
+// ValidPhone reports whether a normalized number looks like E.164.
+func ValidPhone(s string) bool {
+	return e164.MatchString(s)
+}
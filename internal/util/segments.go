@@ -0,0 +1,84 @@
+package util
+
+import (
+	"github.com/jmehdipour/sms-gateway/internal/model"
+)
+
+// gsm7Basic is the GSM 03.38 default alphabet (one septet each).
+var gsm7Basic = map[rune]bool{}
+
+// gsm7Ext is the GSM 03.38 extension table (escape + char = two septets each).
+var gsm7Ext = map[rune]bool{
+	'\f': true, '^': true, '{': true, '}': true, '\\': true,
+	'[': true, '~': true, ']': true, '|': true, '€': true,
+}
+
+func init() {
+	const basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+		"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	for _, r := range basic {
+		gsm7Basic[r] = true
+	}
+}
+
+const (
+	gsm7Single = 160
+	gsm7Multi  = 153
+	ucs2Single = 70
+	ucs2Multi  = 67
+)
+
+// DetectEncoding returns GSM-7 when every rune is in the GSM 03.38 alphabet
+// (basic or extension table), UCS-2 otherwise.
+func DetectEncoding(text string) model.Encoding {
+	for _, r := range text {
+		if !gsm7Basic[r] && !gsm7Ext[r] {
+			return model.EncodingUCS2
+		}
+	}
+	return model.EncodingGSM7
+}
+
+// CountSegments returns the encoding of text and how many SMS parts it takes.
+// Multi-part messages lose room to the concatenation header, and an extension-table
+// pair (GSM-7) or surrogate pair (UCS-2) is never split across parts.
+func CountSegments(text string) (model.Encoding, int) {
+	enc := DetectEncoding(text)
+
+	// units per rune: septets for GSM-7, UTF-16 code units for UCS-2
+	units := make([]int, 0, len(text))
+	total := 0
+	for _, r := range text {
+		n := 1
+		switch {
+		case enc == model.EncodingGSM7 && gsm7Ext[r]:
+			n = 2
+		case enc == model.EncodingUCS2 && r > 0xFFFF:
+			n = 2
+		}
+		units = append(units, n)
+		total += n
+	}
+
+	single, multi := gsm7Single, gsm7Multi
+	if enc == model.EncodingUCS2 {
+		single, multi = ucs2Single, ucs2Multi
+	}
+
+	if total == 0 {
+		return enc, 0
+	}
+	if total <= single {
+		return enc, 1
+	}
+
+	segments, used := 1, 0
+	for _, n := range units {
+		if used+n > multi {
+			segments++
+			used = 0
+		}
+		used += n
+	}
+	return enc, segments
+}
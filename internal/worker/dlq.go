@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/kafka"
+	"github.com/jmehdipour/sms-gateway/internal/metrics"
+	"github.com/jmehdipour/sms-gateway/internal/model"
+)
+
+// DeadLetterer parks unprocessable records on the dead-letter topic.
+type DeadLetterer struct {
+	Producer *kafka.Producer
+	Topic    string // e.g. sms.dlq
+	Consumer string // group id recorded on each dead letter
+}
+
+// Publish writes m and cause to the dead-letter topic, retrying until the broker acks or
+// ctx ends: the caller commits m right after, so giving up here would lose the record.
+func (d *DeadLetterer) Publish(ctx context.Context, m kafka.Message, cause error) {
+	dl := model.DeadLetter{
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Key:       m.Key,
+		Value:     m.Value,
+		Error:     cause.Error(),
+		Consumer:  d.Consumer,
+		FailedAt:  time.Now().UTC(),
+	}
+	payload, err := json.Marshal(dl)
+	if err != nil {
+		log.Printf("[dlq] marshal %s/%d@%d: %v", m.Topic, m.Partition, m.Offset, err)
+		return
+	}
+
+	backoff := 200 * time.Millisecond
+	for {
+		err := d.Producer.Publish(ctx, d.Topic, m.Key, payload)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[dlq] publish %s/%d@%d err: %v", m.Topic, m.Partition, m.Offset, err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, 10*time.Second)
+	}
+
+	metrics.DeadLettersTotal.WithLabelValues(m.Topic).Inc()
+	log.Printf("[dlq] parked %s/%d@%d: %v", m.Topic, m.Partition, m.Offset, cause)
+}
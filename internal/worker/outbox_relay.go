@@ -0,0 +1,155 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/kafka"
+	"github.com/jmehdipour/sms-gateway/internal/metrics"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+// OutboxRelay publishes outbox rows to Kafka without Debezium:
+// - claims unpublished rows in id order with FOR UPDATE SKIP LOCKED (replicas split the work),
+// - produces each to its topic keyed by aggregate_id, as the EventRouter would,
+// - marks or deletes the rows in the same transaction once the broker acked.
+// A crash between ack and commit republishes the batch; consumers are already at-least-once.
+type OutboxRelay struct {
+	DB       *sqlx.DB
+	Outbox   repository.OutboxRepository
+	Producer *kafka.Producer
+
+	BatchSize       int
+	PollInterval    time.Duration
+	LagInterval     time.Duration // how often lag gauges are refreshed
+	DeletePublished bool          // delete acked rows instead of stamping published_at
+}
+
+// NewOutboxRelay builds a relay with sane defaults.
+func NewOutboxRelay(db *sqlx.DB, outbox repository.OutboxRepository, producer *kafka.Producer) *OutboxRelay {
+	return &OutboxRelay{
+		DB:              db,
+		Outbox:          outbox,
+		Producer:        producer,
+		BatchSize:       500,
+		PollInterval:    200 * time.Millisecond,
+		LagInterval:     5 * time.Second,
+		DeletePublished: true,
+	}
+}
+
+// Run relays until ctx is cancelled.
+func (r *OutboxRelay) Run(ctx context.Context) error {
+	if r.BatchSize <= 0 {
+		r.BatchSize = 500
+	}
+	if r.PollInterval <= 0 {
+		r.PollInterval = 200 * time.Millisecond
+	}
+	if r.LagInterval <= 0 {
+		r.LagInterval = 5 * time.Second
+	}
+
+	go r.runLag(ctx)
+
+	for {
+		n, err := r.relayOnce(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("[outbox-relay] %v", err)
+		}
+		// a full batch means there is likely more: go again without waiting
+		if err == nil && n == r.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
+
+// relayOnce publishes one claimed batch and returns its size.
+func (r *OutboxRelay) relayOnce(ctx context.Context) (int, error) {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := r.Outbox.ClaimUnpublished(ctx, tx, r.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("claim: %w", err)
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	msgs := make([]kafka.Message, len(rows))
+	ids := make([]int64, len(rows))
+	for i, row := range rows {
+		msgs[i] = kafka.Message{Topic: row.Topic, Key: []byte(row.AggregateID), Value: row.Payload}
+		ids[i] = row.ID
+	}
+
+	if perr := r.Producer.PublishBatch(ctx, msgs...); perr != nil {
+		metrics.OutboxPublishedTotal.WithLabelValues("failed").Add(float64(len(rows)))
+		if err := r.Outbox.IncrementAttempts(ctx, tx, ids); err != nil {
+			return 0, fmt.Errorf("publish: %v; count attempts: %w", perr, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, fmt.Errorf("publish: %v; commit attempts: %w", perr, err)
+		}
+		return 0, fmt.Errorf("publish %d events: %w", len(rows), perr)
+	}
+
+	if r.DeletePublished {
+		err = r.Outbox.DeleteByIDs(ctx, tx, ids)
+	} else {
+		err = r.Outbox.MarkPublished(ctx, tx, ids)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ack rows: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+
+	metrics.OutboxPublishedTotal.WithLabelValues("published").Add(float64(len(rows)))
+	return len(rows), nil
+}
+
+// runLag refreshes the lag gauges until ctx is cancelled.
+func (r *OutboxRelay) runLag(ctx context.Context) {
+	tick := time.NewTicker(r.LagInterval)
+	defer tick.Stop()
+
+	for {
+		n, oldest, err := r.Outbox.Lag(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[outbox-relay] lag err: %v", err)
+		} else {
+			metrics.OutboxLagEvents.Set(float64(n))
+			age := 0.0
+			if oldest != nil {
+				age = time.Since(*oldest).Seconds()
+			}
+			metrics.OutboxLagSeconds.Set(age)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
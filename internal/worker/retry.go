@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/kafka"
+	"github.com/jmehdipour/sms-gateway/internal/model"
+)
+
+// RetryPolicy decides whether a failed dispatch gets another round.
+// Delays[i] is the wait before attempt i+2; Validity bounds the whole life of the message
+// from Envelope.QueuedAt (0 = only the number of tiers limits retries).
+type RetryPolicy struct {
+	Delays   []time.Duration
+	Validity time.Duration
+}
+
+// Next returns the retry tier delay for env after a failed attempt, or ok=false when the
+// policy or the message validity is exhausted and the message must fail and be refunded.
+func (p RetryPolicy) Next(env model.Envelope, now time.Time) (time.Duration, bool) {
+	if env.Attempt >= len(p.Delays) {
+		return 0, false
+	}
+	d := p.Delays[env.Attempt]
+	if p.Validity > 0 && !env.QueuedAt.IsZero() && now.Add(d).After(env.QueuedAt.Add(p.Validity)) {
+		return 0, false
+	}
+	return d, true
+}
+
+// RetryTopic names the delay tier topic of a lane topic, e.g. "sms.normal.retry.30s".
+func RetryTopic(laneTopic string, delay time.Duration) string {
+	return laneTopic + ".retry." + shortDuration(delay)
+}
+
+// shortDuration formats d as "30s", "5m", "1h30m" (no zero units).
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// RetryForwarder drains one retry tier: it holds each envelope until its NotBefore time
+// and then republishes it to the lane topic, where the sender picks it up again.
+// Envelopes in a tier share the same delay, so waiting on the head keeps FIFO order.
+type RetryForwarder struct {
+	Consumer *kafka.Consumer
+	Producer *kafka.Producer
+	Target   string // lane topic, e.g. sms.normal
+}
+
+func NewRetryForwarder(consumer *kafka.Consumer, producer *kafka.Producer, target string) *RetryForwarder {
+	return &RetryForwarder{Consumer: consumer, Producer: producer, Target: target}
+}
+
+// Run blocks until ctx is cancelled.
+func (f *RetryForwarder) Run(ctx context.Context) error {
+	if f.Target == "" {
+		return errors.New("retry-forwarder: missing target topic")
+	}
+	for {
+		m, err := f.Consumer.Fetch(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("[retry] kafka fetch err: %v", err)
+			time.Sleep(200 * time.Millisecond)
+			continue
+		}
+
+		// Undecodable payloads are forwarded as-is; the sender deals with poison messages.
+		var env model.Envelope
+		if err := json.Unmarshal(m.Value, &env); err == nil {
+			if wait := time.Until(env.NotBefore); wait > 0 {
+				select {
+				case <-ctx.Done():
+					return nil // uncommitted; redelivered after restart
+				case <-time.After(wait):
+				}
+			}
+		}
+
+		for {
+			err := f.Producer.Publish(ctx, f.Target, m.Key, m.Value)
+			if err == nil {
+				break
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("[retry] publish to %s err: %v", f.Target, err)
+			time.Sleep(time.Second)
+		}
+
+		if err := f.Consumer.Commit(ctx, m); err != nil {
+			log.Printf("[retry] commit err: %v", err)
+		}
+	}
+}
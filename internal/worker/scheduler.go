@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/service/queue"
+)
+
+// Scheduler releases due scheduled messages into the outbox in send_at order.
+type Scheduler struct {
+	// Dependencies
+	Queue *queue.Service
+
+	// Behavior
+	BatchSize    int           // messages released per transaction
+	PollInterval time.Duration // wait when nothing is due
+}
+
+// NewScheduler builds a scheduler with sane defaults.
+func NewScheduler(q *queue.Service) *Scheduler {
+	return &Scheduler{
+		Queue:        q,
+		BatchSize:    500,
+		PollInterval: time.Second,
+	}
+}
+
+// Run releases due messages until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) error {
+	if s.BatchSize <= 0 {
+		s.BatchSize = 500
+	}
+	if s.PollInterval <= 0 {
+		s.PollInterval = time.Second
+	}
+
+	for {
+		n, err := s.Queue.ReleaseDue(ctx, s.BatchSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("[scheduler] release err: %v", err)
+		}
+		if n > 0 {
+			log.Printf("[scheduler] released=%d", n)
+		}
+
+		// full batch: more may be due, keep draining
+		if n == s.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.PollInterval):
+		}
+	}
+}
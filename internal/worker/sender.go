@@ -12,13 +12,17 @@ import (
 	"github.com/jmehdipour/sms-gateway/internal/metrics"
 	"github.com/jmehdipour/sms-gateway/internal/model"
 	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmehdipour/sms-gateway/internal/service/pricing"
+	"github.com/jmehdipour/sms-gateway/internal/service/queue"
 	"github.com/jmoiron/sqlx"
 )
 
 // SenderKafka:
 // - fetches envelopes from Kafka,
 // - dispatches SMS via providers,
-// - batches wallet/ledger/messages updates atomically (Ledger-first).
+// - sends failed envelopes to a delayed retry tier while the lane's RetryPolicy allows,
+// - batches wallet/ledger/messages updates atomically (Ledger-first),
+// - queues customer status webhooks in the same transaction.
 type SenderKafka struct {
 	// Dependencies
 	DB       *sqlx.DB
@@ -26,15 +30,19 @@ type SenderKafka struct {
 	Messages repository.MessagesRepository
 	Wallet   repository.WalletRepository
 	Ledger   repository.LedgerRepository
+	Webhooks repository.WebhooksRepository
+	Usage    repository.UsageRepository // gives failed messages back to the caps; nil skips
 	Dispatch *dispatcher.Dispatcher
+	Pricing  *pricing.Service // reprices envelopes that carry no reserved price
+	Producer *kafka.Producer  // publishes to retry tiers; nil (or no Retry.Delays) disables retries
+	DLQ      *DeadLetterer    // parks undecodable envelopes; nil only logs them
 
 	// Behavior
-	Type         model.SMSType // normal | express (topic-bound worker)
-	Workers      int           // number of goroutines processing messages
-	BatchSize    int           // max buffered updates per flush (items)
-	BatchWait    time.Duration // max time to wait before flush
-	PriceNormal  int64
-	PriceExpress int64
+	Type      model.SMSType // normal | express (topic-bound worker)
+	Workers   int           // number of goroutines processing messages
+	BatchSize int           // max buffered updates per flush (items)
+	BatchWait time.Duration // max time to wait before flush
+	Retry     RetryPolicy   // delay tiers and validity before a failed message is refunded
 }
 
 // NewSenderKafka builds a worker with sane defaults.
@@ -44,31 +52,50 @@ func NewSenderKafka(
 	msgRepo repository.MessagesRepository,
 	walletRepo repository.WalletRepository,
 	ledgerRepo repository.LedgerRepository,
+	webhooksRepo repository.WebhooksRepository,
 	dispatch *dispatcher.Dispatcher,
 	lane model.SMSType,
-	priceNormal, priceExpress int64,
+	pricer *pricing.Service,
 ) *SenderKafka {
 	return &SenderKafka{
-		DB:           db,
-		Consumer:     consumer,
-		Messages:     msgRepo,
-		Wallet:       walletRepo,
-		Ledger:       ledgerRepo,
-		Dispatch:     dispatch,
-		Type:         lane,
-		Workers:      64,
-		BatchSize:    200,
-		BatchWait:    300 * time.Millisecond,
-		PriceNormal:  priceNormal,
-		PriceExpress: priceExpress,
+		DB:        db,
+		Consumer:  consumer,
+		Messages:  msgRepo,
+		Wallet:    walletRepo,
+		Ledger:    ledgerRepo,
+		Webhooks:  webhooksRepo,
+		Dispatch:  dispatch,
+		Pricing:   pricer,
+		Type:      lane,
+		Workers:   64,
+		BatchSize: 200,
+		BatchWait: 300 * time.Millisecond,
 	}
 }
 
-func (w *SenderKafka) priceOf(t model.SMSType) int64 {
-	if t == model.SMSTypeExpress {
-		return w.PriceExpress
+// priceOf is the amount reserved for env. Envelopes carry it since destination pricing;
+// older ones are repriced (and were reserved as one segment when they carry no count).
+// Repricing waits for the price table rather than settling from lane defaults.
+func (w *SenderKafka) priceOf(ctx context.Context, env model.Envelope) (int64, error) {
+	if env.Price > 0 {
+		return env.Price, nil
+	}
+	segments := env.Segments
+	if segments <= 0 {
+		segments = 1
+	}
+	for {
+		price, err := w.Pricing.PriceOf(ctx, env.UserID, env.SMS.Phone, env.SMS.Type, segments)
+		if !errors.Is(err, pricing.ErrUnavailable) {
+			return price, err
+		}
+		log.Printf("[sender] %s: waiting for prices to load", env.ID)
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(time.Second):
+		}
 	}
-	return w.PriceNormal
 }
 
 // Run starts the worker and blocks until ctx is cancelled.
@@ -85,8 +112,8 @@ func (w *SenderKafka) Run(ctx context.Context) error {
 	if w.BatchWait <= 0 {
 		w.BatchWait = 300 * time.Millisecond
 	}
-	if w.PriceNormal <= 0 || w.PriceExpress <= 0 {
-		return errors.New("sender-kafka: invalid pricing")
+	if w.Pricing == nil {
+		return errors.New("sender-kafka: missing pricing")
 	}
 
 	// Channel for worker results → batch writer
@@ -132,10 +159,12 @@ func (w *SenderKafka) Run(ctx context.Context) error {
 }
 
 type updateItem struct {
-	id         string
-	customerID int64
-	amount     int64
-	status     model.MessageStatus // sent | failed
+	id            string
+	customerID    int64
+	amount        int64
+	status        model.MessageStatus // sent | failed | retrying
+	provider      string              // provider that accepted the message (sent only)
+	providerMsgID string              // provider-side id for DLR matching (sent only)
 }
 
 // runProcessor parses envelope, dispatches SMS, emits update, commits Kafka.
@@ -157,12 +186,16 @@ func (w *SenderKafka) processOne(ctx context.Context, m kafka.Message, out chan<
 	// Parse envelope: { id, user_id, sms:{phone,text,type} }
 	var env model.Envelope
 	if err := json.Unmarshal(m.Value, &env); err != nil || env.ID == "" {
-		_ = w.Consumer.Commit(ctx, m) // poison → commit, skip
-		if err != nil {
-			log.Printf("[sender] bad envelope json: %v", err)
+		if err == nil {
+			err = errors.New("envelope missing id")
+		}
+		// poison → dead-letter, commit, skip
+		if w.DLQ != nil {
+			w.DLQ.Publish(ctx, m, err)
 		} else {
-			log.Printf("[sender] envelope missing id")
+			log.Printf("[sender] bad envelope: %v", err)
 		}
+		_ = w.Consumer.Commit(ctx, m)
 		return
 	}
 
@@ -170,21 +203,37 @@ func (w *SenderKafka) processOne(ctx context.Context, m kafka.Message, out chan<
 	if !env.SMS.Type.Valid() {
 		env.SMS.Type = w.Type
 	}
-	// Compute price
-	price := w.priceOf(env.SMS.Type)
+	// Capture/refund exactly what was reserved at enqueue time
+	price, err := w.priceOf(ctx, env)
+	if err != nil {
+		return // shutting down; not committed, so the envelope is redelivered
+	}
 
 	// Dispatch (providers handle their own internal strategy)
-	var derr error
+	var (
+		res  dispatcher.SendResult
+		derr error
+	)
 	switch env.SMS.Type {
 	case model.SMSTypeExpress:
-		derr = w.Dispatch.SendExpress(ctx, env.SMS)
+		res, derr = w.Dispatch.SendExpress(ctx, env.SMS)
 	default:
-		derr = w.Dispatch.SendNormal(ctx, env.SMS)
+		res, derr = w.Dispatch.SendNormal(ctx, env.SMS)
 	}
 
 	if derr == nil {
 		metrics.MessagesTotal.WithLabelValues("sent", env.SMS.Type.String()).Inc()
-		out <- updateItem{id: env.ID, customerID: env.UserID, amount: price, status: model.StatusSent}
+		out <- updateItem{
+			id:            env.ID,
+			customerID:    env.UserID,
+			amount:        price,
+			status:        model.StatusSent,
+			provider:      res.Provider,
+			providerMsgID: res.MessageID,
+		}
+	} else if w.scheduleRetry(ctx, env, derr) {
+		metrics.MessagesTotal.WithLabelValues("retrying", env.SMS.Type.String()).Inc()
+		out <- updateItem{id: env.ID, customerID: env.UserID, amount: price, status: model.StatusRetrying}
 	} else {
 		metrics.MessagesTotal.WithLabelValues("failed", env.SMS.Type.String()).Inc()
 		out <- updateItem{id: env.ID, customerID: env.UserID, amount: price, status: model.StatusFailed}
@@ -196,20 +245,51 @@ func (w *SenderKafka) processOne(ctx context.Context, m kafka.Message, out chan<
 	}
 }
 
+// scheduleRetry publishes env to its next retry tier. It returns false when retries are
+// disabled or exhausted, the provider rejected the message outright, or the publish failed;
+// the message is then failed and refunded.
+func (w *SenderKafka) scheduleRetry(ctx context.Context, env model.Envelope, cause error) bool {
+	if w.Producer == nil || dispatcher.Final(cause) {
+		return false
+	}
+	now := time.Now()
+	delay, ok := w.Retry.Next(env, now)
+	if !ok {
+		return false
+	}
+
+	env.Attempt++
+	env.NotBefore = now.Add(delay).UTC()
+	payload, err := json.Marshal(env)
+	if err != nil {
+		log.Printf("[sender] marshal retry envelope %s: %v", env.ID, err)
+		return false
+	}
+
+	topic := RetryTopic(queue.TopicOf(env.SMS.Type), delay)
+	if err := w.Producer.Publish(ctx, topic, []byte(env.ID), payload); err != nil {
+		log.Printf("[sender] publish retry %s to %s: %v", env.ID, topic, err)
+		return false
+	}
+	log.Printf("[sender] %s attempt %d failed (%v); retry in %s", env.ID, env.Attempt, cause, delay)
+	return true
+}
+
 // runBatchWriter does size/time-based flush of DB updates (wallet + ledger + messages) atomically.
 func (w *SenderKafka) runBatchWriter(ctx context.Context, in <-chan updateItem) {
 	tick := time.NewTicker(w.BatchWait)
 	defer tick.Stop()
 
-	var success, failed []updateItem
+	var success, failed, retrying []updateItem
 
 	reset := func() {
 		success = success[:0]
 		failed = failed[:0]
+		retrying = retrying[:0]
 	}
 
 	flush := func() {
-		if len(success) == 0 && len(failed) == 0 {
+		if len(success) == 0 && len(failed) == 0 && len(retrying) == 0 {
 			return
 		}
 
@@ -253,17 +333,25 @@ func (w *SenderKafka) runBatchWriter(ctx context.Context, in <-chan updateItem)
 			})
 		}
 
-		// Gather message IDs
-		sentIDs := make([]string, 0, len(success))
+		// Gather message IDs (sent rows carry provider details for DLR matching)
+		sentRows := make([]repository.SentRow, 0, len(success))
 		for _, it := range success {
-			sentIDs = append(sentIDs, it.id)
+			sentRows = append(sentRows, repository.SentRow{
+				ID:                it.id,
+				Provider:          it.provider,
+				ProviderMessageID: it.providerMsgID,
+			})
 		}
 		failedIDs := make([]string, 0, len(failed))
 		for _, it := range failed {
 			failedIDs = append(failedIDs, it.id)
 		}
+		retryIDs := make([]string, 0, len(retrying))
+		for _, it := range retrying {
+			retryIDs = append(retryIDs, it.id)
+		}
 
-		// Single TX: ledger (cap/ref) + wallet deltas + messages status
+		// Single TX: ledger (cap/ref) + wallet deltas + usage + messages status
 		tx, err := w.DB.BeginTxx(ctx, nil)
 		if err != nil {
 			log.Printf("[sender] begin tx err: %v", err)
@@ -288,9 +376,18 @@ func (w *SenderKafka) runBatchWriter(ctx context.Context, in <-chan updateItem)
 			return
 		}
 
-		// 3) Messages status updates
-		if len(sentIDs) > 0 {
-			if err := w.Messages.BatchUpdateStatus(ctx, tx, sentIDs, model.StatusSent); err != nil {
+		// 3) Usage: refunded messages no longer count against the caps (before the status
+		// change, which makes a repeat a no-op)
+		if w.Usage != nil {
+			if err := w.Usage.Refund(ctx, tx, failedIDs); err != nil {
+				log.Printf("[sender] usage refund err: %v", err)
+				return
+			}
+		}
+
+		// 4) Messages status updates
+		if len(sentRows) > 0 {
+			if err := w.Messages.BatchMarkSent(ctx, tx, sentRows); err != nil {
 				log.Printf("[sender] batch update sent err: %v", err)
 				return
 			}
@@ -301,14 +398,35 @@ func (w *SenderKafka) runBatchWriter(ctx context.Context, in <-chan updateItem)
 				return
 			}
 		}
+		// retrying keeps its reservation; only the status changes
+		if len(retryIDs) > 0 {
+			if err := w.Messages.BatchUpdateStatus(ctx, tx, retryIDs, model.StatusRetrying); err != nil {
+				log.Printf("[sender] batch update retrying err: %v", err)
+				return
+			}
+		}
+
+		// 5) Status webhooks (same TX: only committed changes are announced)
+		if w.Webhooks != nil {
+			changed := make([]string, 0, len(sentRows)+len(failedIDs)+len(retryIDs))
+			for _, r := range sentRows {
+				changed = append(changed, r.ID)
+			}
+			changed = append(changed, failedIDs...)
+			changed = append(changed, retryIDs...) // announced once: the event key is (message, status)
+			if err := w.Webhooks.EnqueueForMessages(ctx, tx, changed); err != nil {
+				log.Printf("[sender] enqueue webhooks err: %v", err)
+				return
+			}
+		}
 
 		if err := tx.Commit(); err != nil {
 			log.Printf("[sender] tx commit err: %v", err)
 			return
 		}
 
-		log.Printf("[sender:%s] flushed: sent=%d failed=%d customers=%d",
-			w.Type, len(sentIDs), len(failedIDs), len(deltas))
+		log.Printf("[sender:%s] flushed: sent=%d failed=%d retrying=%d customers=%d",
+			w.Type, len(sentRows), len(failedIDs), len(retryIDs), len(deltas))
 
 		reset()
 	}
@@ -328,9 +446,11 @@ func (w *SenderKafka) runBatchWriter(ctx context.Context, in <-chan updateItem)
 				success = append(success, u)
 			} else if u.status == model.StatusFailed {
 				failed = append(failed, u)
+			} else if u.status == model.StatusRetrying {
+				retrying = append(retrying, u)
 			}
 
-			if len(success)+len(failed) >= w.BatchSize {
+			if len(success)+len(failed)+len(retrying) >= w.BatchSize {
 				flush()
 			}
 
@@ -0,0 +1,227 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmehdipour/sms-gateway/internal/metrics"
+	"github.com/jmehdipour/sms-gateway/internal/model"
+	"github.com/jmehdipour/sms-gateway/internal/repository"
+	"github.com/jmehdipour/sms-gateway/internal/util"
+)
+
+var errNoSecret = errors.New("no signing secret")
+
+// WebhookSender:
+// - claims due deliveries from the durable webhook_deliveries queue,
+// - POSTs the signed JSON event to the customer URL,
+// - records every attempt and reschedules failures with exponential backoff.
+type WebhookSender struct {
+	// Dependencies
+	Webhooks repository.WebhooksRepository
+	Client   *http.Client
+
+	// Behavior
+	Workers      int           // concurrent deliveries
+	BatchSize    int           // deliveries claimed per poll
+	PollInterval time.Duration // wait between empty polls
+	MaxAttempts  int           // attempts before a delivery is marked failed
+	BackoffBase  time.Duration // delay after the first failure
+	BackoffMax   time.Duration // backoff cap
+}
+
+// NewWebhookSender builds a webhook worker with sane defaults.
+func NewWebhookSender(repo repository.WebhooksRepository, timeout time.Duration) *WebhookSender {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &WebhookSender{
+		Webhooks:     repo,
+		Client:       &http.Client{Timeout: timeout, Transport: publicTransport()},
+		Workers:      16,
+		BatchSize:    100,
+		PollInterval: time.Second,
+		MaxAttempts:  8,
+		BackoffBase:  10 * time.Second,
+		BackoffMax:   time.Hour,
+	}
+}
+
+// publicTransport only connects to public addresses. Webhook URLs are customer input and
+// were checked when saved, but DNS can change since; the check here is on the address
+// actually dialed, redirects included. No proxy, so the dialed address is the target's.
+func publicTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = (&net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   util.DenyInternalDial,
+	}).DialContext
+	return t
+}
+
+// Run polls the queue until ctx is cancelled.
+func (w *WebhookSender) Run(ctx context.Context) error {
+	if w.Workers <= 0 {
+		w.Workers = 16
+	}
+	if w.BatchSize <= 0 {
+		w.BatchSize = 100
+	}
+	if w.PollInterval <= 0 {
+		w.PollInterval = time.Second
+	}
+	if w.MaxAttempts <= 0 {
+		w.MaxAttempts = 8
+	}
+
+	// lease long enough for a full batch to drain through the worker pool
+	lease := w.Client.Timeout*time.Duration(w.BatchSize/w.Workers+1) + 30*time.Second
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		batch, err := w.Webhooks.ClaimDue(ctx, w.BatchSize, lease)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("[webhooks] claim err: %v", err)
+		}
+		if len(batch) == 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(w.PollInterval):
+			}
+			continue
+		}
+
+		sem := make(chan struct{}, w.Workers)
+		var wg sync.WaitGroup
+		for _, d := range batch {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(d repository.ClaimedDelivery) {
+				defer func() { <-sem; wg.Done() }()
+				w.deliver(ctx, d)
+			}(d)
+		}
+		wg.Wait()
+	}
+}
+
+func (w *WebhookSender) deliver(ctx context.Context, d repository.ClaimedDelivery) {
+	attempt := model.WebhookAttempt{DeliveryID: d.ID, Attempt: d.Attempts + 1}
+
+	// the account webhook, and its secret, may have been removed since the event was queued;
+	// deliveries are never sent unsigned
+	unsigned := d.Secret == nil || *d.Secret == ""
+
+	start := time.Now()
+	code, body, err := 0, "", errNoSecret
+	if !unsigned {
+		code, body, err = w.post(ctx, d)
+	}
+	attempt.DurationMs = int(time.Since(start).Milliseconds())
+	if code > 0 {
+		attempt.ResponseCode = &code
+		attempt.ResponseBody = &body
+	}
+	if err != nil {
+		msg := truncate(err.Error(), 255)
+		attempt.Error = &msg
+	}
+
+	state := model.WebhookDelivered
+	next := time.Now()
+	switch {
+	case err == nil && code/100 == 2:
+		metrics.WebhookDeliveriesTotal.WithLabelValues("delivered").Inc()
+	case unsigned || attempt.Attempt >= w.MaxAttempts:
+		state = model.WebhookFailed
+		metrics.WebhookDeliveriesTotal.WithLabelValues("failed").Inc()
+	default:
+		state = model.WebhookPending
+		next = next.Add(w.backoff(attempt.Attempt))
+		metrics.WebhookDeliveriesTotal.WithLabelValues("retry").Inc()
+	}
+
+	// record with a fresh context so shutdown doesn't lose the outcome of a finished call
+	recCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := w.Webhooks.RecordAttempt(recCtx, attempt, state, next); err != nil {
+		log.Printf("[webhooks] record attempt delivery=%d err: %v", d.ID, err)
+	}
+}
+
+// post sends the event and returns the response code and a truncated body.
+func (w *WebhookSender) post(ctx context.Context, d repository.ClaimedDelivery) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "sms-gateway-webhooks/1")
+	req.Header.Set("X-SMSGW-Event-ID", strconv.FormatInt(d.ID, 10))
+	req.Header.Set("X-SMSGW-Timestamp", ts)
+	req.Header.Set("X-SMSGW-Signature", "v1="+SignWebhook(*d.Secret, ts, d.Payload))
+
+	res, err := w.Client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer res.Body.Close()
+
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	return res.StatusCode, strings.ToValidUTF8(string(b), ""), nil
+}
+
+// backoff is BackoffBase * 2^(attempt-1), capped at BackoffMax, with ±20% jitter.
+func (w *WebhookSender) backoff(attempt int) time.Duration {
+	d := w.BackoffBase
+	for i := 1; i < attempt && d < w.BackoffMax; i++ {
+		d *= 2
+	}
+	if w.BackoffMax > 0 && d > w.BackoffMax {
+		d = w.BackoffMax
+	}
+	jitter := time.Duration(rand.Int64N(int64(d)/5+1)) - d/10
+	return d + jitter
+}
+
+// SignWebhook returns hex(HMAC-SHA256(secret, timestamp + "." + body)).
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	_ "time/tzdata" // customer timezones (usage caps) must load without a system zoneinfo
+
 	"github.com/jmehdipour/sms-gateway/cmd"
 )
 
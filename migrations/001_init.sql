@@ -1,5 +1,13 @@
 SET
 FOREIGN_KEY_CHECKS = 0;
+DROP TABLE IF EXISTS admin_audit_log;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS customer_usage;
+DROP TABLE IF EXISTS routing_rules;
+DROP TABLE IF EXISTS price_rules;
+DROP TABLE IF EXISTS templates;
+DROP TABLE IF EXISTS webhook_attempts;
+DROP TABLE IF EXISTS webhook_deliveries;
 DROP TABLE IF EXISTS outbox;
 DROP TABLE IF EXISTS messages;
 DROP TABLE IF EXISTS wallet_accounts;
@@ -11,13 +19,20 @@ FOREIGN_KEY_CHECKS = 1;
 -- customers
 CREATE TABLE customers
 (
-    id             BIGINT AUTO_INCREMENT PRIMARY KEY,
-    name           VARCHAR(120) NOT NULL,
-    api_key        CHAR(32)     NOT NULL UNIQUE,
-    status         ENUM('active','suspended') NOT NULL DEFAULT 'active',
-    rate_limit_rps INT NULL,
-    created_at     DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    updated_at     DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+    id                  BIGINT AUTO_INCREMENT PRIMARY KEY,
+    name                VARCHAR(120) NOT NULL,
+    status              ENUM('active','suspended') NOT NULL DEFAULT 'active',
+    rate_limit_rps      INT NULL,
+    rate_limit_burst    INT NULL,
+    webhook_url         VARCHAR(512) NULL,
+    webhook_secret      VARCHAR(64)  NULL,
+    timezone            VARCHAR(64)  NOT NULL DEFAULT 'UTC', -- caps reset at midnight here
+    daily_message_cap   BIGINT NULL,
+    monthly_message_cap BIGINT NULL,
+    daily_spend_cap     BIGINT NULL,
+    monthly_spend_cap   BIGINT NULL,
+    created_at          DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at          DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
 -- wallet_accounts
@@ -41,17 +56,33 @@ CREATE TABLE messages
     phone       VARCHAR(32) NOT NULL,
     text        TEXT        NOT NULL,
     type        ENUM('normal','express') NOT NULL DEFAULT 'normal',
-    status      ENUM('queued','sent','failed') NOT NULL DEFAULT 'queued',
+    status      ENUM('queued','sent','failed','delivered','undelivered','expired','scheduled','canceled','retrying') NOT NULL DEFAULT 'queued',
+    encoding    ENUM('gsm7','ucs2') NOT NULL DEFAULT 'gsm7',
+    segments    SMALLINT    NOT NULL DEFAULT 1, -- SMS parts; price is per segment
+    price       BIGINT      NOT NULL DEFAULT 0, -- total reserved at enqueue (per-segment price x segments)
+    provider            VARCHAR(32) NULL, -- provider that accepted the message
+    provider_message_id VARCHAR(64) NULL, -- provider-side id, matched by DLR callbacks
+    dlr_at      DATETIME    NULL,         -- delivery receipt time
+    callback_url VARCHAR(512) NULL,       -- per-message status webhook
+    batch_id    CHAR(26)    NULL,         -- bulk request id (ULID)
+    send_at     DATETIME    NULL,         -- release time of a scheduled message
+    client_ref  VARCHAR(128) NULL,        -- Idempotency-Key, unique per customer
+    request_hash CHAR(64)   NULL,         -- sha256 of the request sent with client_ref
+    usage_day   DATE        NOT NULL,     -- customer's calendar day it was counted in (customer_usage)
     created_at  DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at  DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     CONSTRAINT fk_messages_customer
         FOREIGN KEY (customer_id) REFERENCES customers (id)
             ON UPDATE RESTRICT ON DELETE RESTRICT,
     KEY         idx_customer_created (customer_id, created_at),
-    KEY         idx_status (status)
+    KEY         idx_status (status),
+    KEY         idx_provider_msg (provider, provider_message_id),
+    KEY         idx_batch (batch_id),
+    KEY         idx_scheduled (status, send_at),
+    UNIQUE KEY  uq_customer_client_ref (customer_id, client_ref)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
--- Minimal outbox for Debezium Outbox SMT
+-- Minimal outbox, published by Debezium Outbox SMT or by `worker outbox-relay`
 CREATE TABLE outbox
 (
     id           BIGINT AUTO_INCREMENT PRIMARY KEY,
@@ -59,8 +90,12 @@ CREATE TABLE outbox
     aggregate_id CHAR(26)     NOT NULL, -- ULID
     topic        VARCHAR(120) NOT NULL, -- sms.normal | sms.express
     payload      JSON         NOT NULL,
+    attempts     INT          NOT NULL DEFAULT 0, -- failed relay publishes
+    published_at DATETIME     NULL,               -- set by the relay when not deleting
     created_at   DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    KEY          idx_created (created_at)
+    updated_at   DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    KEY          idx_created (created_at),
+    KEY          idx_unpublished (published_at, id)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
 -- wallet_ledger
@@ -78,3 +113,135 @@ CREATE TABLE wallet_ledger
     KEY             idx_cust_time (customer_id, created_at),
     KEY             idx_msg (message_id)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- webhook_deliveries: durable queue of customer status webhooks
+CREATE TABLE webhook_deliveries
+(
+    id               BIGINT       NOT NULL AUTO_INCREMENT,
+    customer_id      BIGINT       NOT NULL,
+    message_id       CHAR(26)     NOT NULL,
+    status_event     VARCHAR(16)  NOT NULL, -- message status that triggered the event
+    url              VARCHAR(512) NOT NULL,
+    payload          JSON         NOT NULL,
+    state            ENUM('pending','delivered','failed') NOT NULL DEFAULT 'pending',
+    attempts         INT          NOT NULL DEFAULT 0,
+    next_attempt_at  DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_status_code INT          NULL,
+    last_error       VARCHAR(255) NULL,
+    created_at       DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at       DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_msg_event (message_id, status_event),
+    KEY              idx_due (state, next_attempt_at),
+    KEY              idx_cust_id (customer_id, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- webhook_attempts: one row per HTTP call
+CREATE TABLE webhook_attempts
+(
+    id            BIGINT        NOT NULL AUTO_INCREMENT,
+    delivery_id   BIGINT        NOT NULL,
+    attempt       INT           NOT NULL,
+    response_code INT           NULL,
+    response_body VARCHAR(1024) NULL,
+    error         VARCHAR(255)  NULL,
+    duration_ms   INT           NOT NULL,
+    created_at    DATETIME      NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    KEY           idx_delivery (delivery_id, attempt)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- templates: per-customer message texts with {{placeholders}}
+CREATE TABLE templates
+(
+    id          BIGINT       NOT NULL AUTO_INCREMENT,
+    customer_id BIGINT       NOT NULL,
+    name        VARCHAR(120) NOT NULL,
+    body        TEXT         NOT NULL,
+    created_at  DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_customer_name (customer_id, name),
+    CONSTRAINT fk_templates_customer
+        FOREIGN KEY (customer_id) REFERENCES customers (id)
+            ON UPDATE RESTRICT ON DELETE RESTRICT
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- price_rules: per-segment price by destination prefix and lane (longest prefix wins);
+-- customer_id 0 is the global table, other values are per-customer overrides
+CREATE TABLE price_rules
+(
+    id          BIGINT      NOT NULL AUTO_INCREMENT,
+    customer_id BIGINT      NOT NULL DEFAULT 0,
+    prefix      VARCHAR(16) NOT NULL, -- E.164 prefix, e.g. +98912
+    lane        ENUM('normal','express') NOT NULL,
+    price       BIGINT      NOT NULL,
+    created_at  DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_customer_prefix_lane (customer_id, prefix, lane)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- routing_rules: providers allowed for a destination prefix and lane (longest prefix wins),
+-- tried in the listed order; destinations without a rule use the lane's routing strategy
+CREATE TABLE routing_rules
+(
+    id         BIGINT       NOT NULL AUTO_INCREMENT,
+    prefix     VARCHAR(16)  NOT NULL, -- E.164 prefix, e.g. +98935
+    lane       ENUM('normal','express') NOT NULL,
+    providers  VARCHAR(255) NOT NULL, -- comma-separated provider names
+    created_at DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_prefix_lane (prefix, lane)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- customer_usage: messages and spend per customer and calendar day/month (customer's timezone),
+-- counted at enqueue, given back on refund and checked against the customer's caps
+CREATE TABLE customer_usage
+(
+    customer_id  BIGINT                NOT NULL,
+    period       ENUM('day','month')   NOT NULL,
+    period_start DATE                  NOT NULL,
+    messages     BIGINT                NOT NULL DEFAULT 0,
+    spend        BIGINT                NOT NULL DEFAULT 0,
+    updated_at   DATETIME              NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (customer_id, period, period_start)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- api_keys: customer credentials. Only the SHA-256 of the full key is stored; the
+-- visible prefix ("sk_" + 12 hex) is the lookup key.
+CREATE TABLE api_keys
+(
+    id           BIGINT        NOT NULL AUTO_INCREMENT,
+    customer_id  BIGINT        NOT NULL,
+    prefix       VARCHAR(16)   NOT NULL,
+    secret_hash  CHAR(64)      NOT NULL,
+    label        VARCHAR(64)   NOT NULL DEFAULT '',
+    scopes       VARCHAR(255)  NOT NULL,      -- comma-separated: sms:send,wallet:topup,reports:read
+    allowed_ips  VARCHAR(1024) NULL,          -- comma-separated CIDRs; NULL = any
+    expires_at   DATETIME      NULL,
+    last_used_at DATETIME      NULL,
+    revoked_at   DATETIME      NULL,
+    created_at   DATETIME      NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_prefix (prefix),
+    KEY idx_customer (customer_id),
+    CONSTRAINT fk_api_keys_customer
+        FOREIGN KEY (customer_id) REFERENCES customers (id)
+            ON UPDATE RESTRICT ON DELETE RESTRICT
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- admin_audit_log: every change made through /admin/v1 (who, from where, what)
+CREATE TABLE admin_audit_log
+(
+    id          BIGINT      NOT NULL AUTO_INCREMENT,
+    actor       VARCHAR(64) NOT NULL,
+    remote_ip   VARCHAR(45) NOT NULL,
+    action      VARCHAR(64) NOT NULL, -- customer.*, api_key.create|rotate|revoke
+    customer_id BIGINT      NULL,
+    details     JSON        NULL,
+    created_at  DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    KEY idx_customer (customer_id, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;